@@ -2,8 +2,9 @@ package main
 
 import "github.com/danilevy1212/self-updater/internal/models"
 
-func getCurrentServerFileName(am models.ApplicationMeta) string {
-	res := "current"
+// Name of the server executable within a release directory it wasn't bundled in.
+func getServerFileName(am models.ApplicationMeta) string {
+	res := "server"
 
 	if am.OS == "windows" {
 		res = res + ".exe"
@@ -12,26 +13,17 @@ func getCurrentServerFileName(am models.ApplicationMeta) string {
 	return res
 }
 
-func getNewServerFileName(am models.ApplicationMeta) string {
-	res := "new"
-
-	if am.OS == "windows" {
-		res = res + ".exe"
-	}
+// Release directories the launcher swaps between, numbered in the order they were swapped in.
+func getReleasesDirName() string {
+	return "releases"
+}
 
-	return res
+// Release directory staged by the server, for the launcher to swap in.
+func getNewReleaseDirName() string {
+	return "new"
 }
 
-// NOTE  Due to time concerns, I'm not doing health checks or rollbacks, however, these
-//       could be implemented by simply coping the current binary to a backup file and
-//       doing a health check against the new executable (GET /health). If it passes, great,
-//       if not, rollback.
-// func getBackupServerFileName(am models.ApplicationMeta) string {
-// 	res := "backup"
-//
-// 	if am.OS == "windows" {
-// 		res = res + ".exe"
-// 	}
-//
-// 	return res
-// }
+// Metadata of the staged release, written by the server next to the new release directory.
+func getStagedReleaseFileName() string {
+	return "new.json"
+}
@@ -2,29 +2,84 @@ package main
 
 import (
 	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"os"
 	"path/filepath"
+	"strconv"
+	"time"
 
+	"github.com/rs/zerolog"
+
+	"github.com/danilevy1212/self-updater/internal/backoff"
+	"github.com/danilevy1212/self-updater/internal/digest"
 	"github.com/danilevy1212/self-updater/internal/launcher"
 	"github.com/danilevy1212/self-updater/internal/launcher/utils"
+	"github.com/danilevy1212/self-updater/internal/logger"
 	"github.com/danilevy1212/self-updater/internal/models"
 	"github.com/danilevy1212/self-updater/internal/models/exitcodes"
 )
 
+// launcherSession supervises the server process for the lifetime of the launcher.
+type launcherSession struct {
+	ctx          context.Context
+	orchestrator *launcher.Launcher
+	meta         models.ApplicationMeta
+	logger       zerolog.Logger
+
+	releasesPath      string
+	newPath           string
+	stagedReleasePath string
+	// Release the server runs from, and the known good one it replaced, if any
+	current  release
+	backup   *release
+	releases int
+
+	server    *launcher.ServerProcess
+	startedAt time.Time
+
+	crashes *launcher.CrashTracker
+	restart backoff.Exponential
+	// Version swapped in by the last update, the backup release is its known good predecessor.
+	// Cleared once the new binary proves stable, or after rolling back.
+	freshVersion string
+	isFresh      bool
+}
+
+// release is a directory holding the server executable, along with whatever was bundled with it.
+type release struct {
+	dir        string
+	executable string
+}
+
+func (r release) executablePath() string {
+	return filepath.Join(r.dir, r.executable)
+}
+
+// fallbackLogger logs what happens before the launcher's own logger is configured.
+var fallbackLogger = logger.New(false).
+	With().
+	Str("app", "launcher").
+	Logger()
+
 func runLauncher(ctx context.Context, am models.ApplicationMeta) {
 	launcherOrchestrator, err := launcher.New(ctx, am)
-	logger := launcherOrchestrator.Logger.With().
-		Str("handler", "runLauncher").
-		Logger()
-
 	if err != nil {
-		logger.Error().
+		// Without a launcher there is no configured logger either, e.g. when its config is invalid.
+		fallbackLogger.Error().
 			Err(err).
+			Str("handler", "runLauncher").
 			Msg("Failed to create launcher orchestrator")
 
 		return
 	}
 
+	logger := launcherOrchestrator.Logger.With().
+		Str("handler", "runLauncher").
+		Logger()
+
 	// Copy this binary to temp file.
 	err = launcherOrchestrator.CreateSessionDir()
 	if err != nil {
@@ -34,27 +89,49 @@ func runLauncher(ctx context.Context, am models.ApplicationMeta) {
 
 		return
 	}
-	currentName := getCurrentServerFileName(am)
-	newName := getNewServerFileName(am)
 
-	currentPath := filepath.Join(launcherOrchestrator.SessionDirectory, currentName)
-	newPath := filepath.Join(launcherOrchestrator.SessionDirectory, newName)
+	if err := launcherOrchestrator.Listen(); err != nil {
+		logger.Error().
+			Err(err).
+			Msg("Failed to open listening socket for the server")
+
+		return
+	}
+	conf := launcherOrchestrator.Config
+	s := &launcherSession{
+		ctx:               ctx,
+		orchestrator:      launcherOrchestrator,
+		meta:              am,
+		logger:            logger,
+		releasesPath:      filepath.Join(launcherOrchestrator.SessionDirectory, getReleasesDirName()),
+		newPath:           filepath.Join(launcherOrchestrator.SessionDirectory, getNewReleaseDirName()),
+		stagedReleasePath: filepath.Join(launcherOrchestrator.SessionDirectory, getStagedReleaseFileName()),
+		crashes:           launcher.NewCrashTracker(conf.CrashLoopWindow),
+		restart: backoff.Exponential{
+			Initial: conf.RestartBackoffInitial,
+			Max:     conf.RestartBackoffMax,
+			Jitter:  conf.RestartBackoffJitter,
+		},
+	}
 
-	err = utils.CopyFile(am.ExecutablePath, currentPath)
+	s.current = s.nextRelease(getServerFileName(am))
+	err = os.MkdirAll(s.current.dir, 0o700)
+	if err == nil {
+		err = utils.CopyFile(am.ExecutablePath, s.current.executablePath())
+	}
 	if err != nil {
 		logger.Error().
 			Err(err).
-			Str("currentPath", currentPath).
+			Str("currentPath", s.current.executablePath()).
 			Msg("Failed to copy current binary to session directory")
 
 		return
 	}
 
-	cmd, err := launcherOrchestrator.LaunchServer(ctx, currentPath)
-	if err != nil {
+	if err := s.launch(); err != nil {
 		logger.Error().
 			Err(err).
-			Str("currentPath", currentPath).
+			Str("currentPath", s.current.executablePath()).
 			Msg("Failed to launch server process")
 
 		return
@@ -62,64 +139,363 @@ func runLauncher(ctx context.Context, am models.ApplicationMeta) {
 
 	for {
 		logger.Info().
-			Msg("Waiting for server to signal update ready")
+			Msg("Waiting for server to exit or signal update ready")
 
-		_ = cmd.Wait()
-		code := cmd.ProcessState.ExitCode()
+		select {
+		case <-s.server.Exited:
+		case <-s.server.UpdateReady:
+			// The server keeps draining while the new version starts on the same socket.
+			s.retire(s.server)
+			if !s.applyUpdate() {
+				return
+			}
 
-		if code != exitcodes.ExitUpdateReady {
-			logger.Error().
+			continue
+		}
+		code := s.server.Cmd.ProcessState.ExitCode()
+
+		if ctx.Err() != nil {
+			logger.Info().
 				Int("exitCode", code).
-				Msg("Server exited with unexpected code, not an update ready signal")
+				Msg("Server stopped, shutting down launcher")
 
 			return
 		}
 
-		if _, err := os.Stat(newPath); err != nil {
-			if !os.IsNotExist(err) {
-				logger.Error().
-					Err(err).
-					Str("newPath", newPath).
-					Msg("Failed to stat new binary")
-
+		if code == exitcodes.ExitUpdateReady {
+			if !s.applyUpdate() {
 				return
 			}
-		}
 
-		if err := os.Chmod(newPath, 0o700); err != nil {
-			logger.Error().
-				Err(err).
-				Str("newPath", newPath).
-				Msg("Failed to set permissions on new binary")
+			continue
+		}
 
+		if !s.restartAfterExit(code) {
 			return
 		}
+	}
+}
 
-		// In windows, there is no atomic rename, we need to delete current first.
-		if am.OS == "windows" {
-			_ = os.Remove(currentPath)
-		}
+func (s *launcherSession) launch() error {
+	server, err := s.orchestrator.LaunchServer(s.ctx, s.current.executablePath())
+	if err != nil {
+		return err
+	}
 
-		// Swap!
-		if err := os.Rename(newPath, currentPath); err != nil {
-			logger.Error().
-				Err(err).
-				Str("currentPath", currentPath).
-				Str("newPath", newPath).
-				Msg("Failed to swap new binary into place")
+	s.server = server
+	s.startedAt = time.Now()
 
-			// NOTE  If we were doing backups, it would be here where we do the rollback
-			return
-		}
+	return nil
+}
+
+// retire stops supervising a server that is draining after handing over to a new version.
+func (s *launcherSession) retire(server *launcher.ServerProcess) {
+	pid := server.Cmd.Process.Pid
+
+	s.logger.Info().
+		Int("pid", pid).
+		Msg("Server staged an update, letting it drain while the new version starts")
+
+	go func() {
+		<-server.Exited
+
+		s.logger.Info().
+			Int("pid", pid).
+			Int("exitCode", server.Cmd.ProcessState.ExitCode()).
+			Msg("Retired server exited")
+	}()
+}
+
+// restartAfterExit applies the restart policy to a server that exited on its own.
+// Returns false when the launcher should stop.
+func (s *launcherSession) restartAfterExit(code int) bool {
+	conf := s.orchestrator.Config
+	logger := s.logger.With().
+		Int("exitCode", code).
+		Str("restartPolicy", string(conf.RestartPolicy)).
+		Logger()
+
+	if !conf.RestartPolicy.ShouldRestart(code) {
+		logger.Error().
+			Msg("Server exited and restart policy does not allow restarting it")
+
+		return false
+	}
+
+	// A fresh binary that stayed up longer than the crash loop window has proven itself.
+	if s.isFresh && time.Since(s.startedAt) > conf.CrashLoopWindow {
+		s.isFresh = false
+	}
+
+	attempt := 0
+	if code != exitcodes.ExitOK {
+		crashes := s.crashes.Record(time.Now())
+		attempt = crashes - 1
+
+		logger.Warn().
+			Int("crashesInWindow", crashes).
+			Dur("crashLoopWindow", conf.CrashLoopWindow).
+			Msg("Server crashed")
+
+		if crashes >= conf.CrashLoopThreshold {
+			if s.isFresh {
+				logger.Error().
+					Int("crashesInWindow", crashes).
+					Str("version", s.freshVersion).
+					Msg("Crash loop detected in freshly updated binary, rolling back to previous binary")
+
+				s.crashes.Reset()
+				if err := s.rollback(); err != nil {
+					logger.Error().
+						Err(err).
+						Str("currentPath", s.current.executablePath()).
+						Msg("Failed to roll back to backup binary")
+
+					return false
+				}
+
+				return true
+			}
 
-		cmd, err = launcherOrchestrator.LaunchServer(ctx, currentPath)
-		if err != nil {
 			logger.Error().
-				Err(err).
-				Str("currentPath", currentPath).
-				Msg("Failed to relaunch server process after update")
+				Int("crashesInWindow", crashes).
+				Msg("Crash loop detected, but there is no known good binary to fall back to")
+		}
+	}
 
-			return
+	delay := s.restart.Delay(attempt)
+	logger.Info().
+		Dur("delay", delay).
+		Msg("Restarting server")
+
+	select {
+	case <-time.After(delay):
+	case <-s.ctx.Done():
+		return false
+	}
+
+	if err := s.launch(); err != nil {
+		logger.Error().
+			Err(err).
+			Str("currentPath", s.current.executablePath()).
+			Msg("Failed to restart server process")
+
+		return false
+	}
+
+	return true
+}
+
+// applyUpdate swaps the staged release in and health checks it, rolling back on failure.
+// Returns false when the launcher should stop.
+func (s *launcherSession) applyUpdate() bool {
+	logger := s.logger
+	next := s.nextRelease(getServerFileName(s.meta))
+
+	staged, err := readStagedRelease(s.stagedReleasePath)
+	if err != nil {
+		logger.Warn().
+			Err(err).
+			Str("stagedReleasePath", s.stagedReleasePath).
+			Msg("Failed to read staged release metadata, health check will only verify the digest")
+	} else if staged.Entrypoint != "" {
+		next.executable = filepath.FromSlash(staged.Entrypoint)
+	}
+	_ = os.Remove(s.stagedReleasePath)
+
+	if !filepath.IsLocal(next.executable) {
+		logger.Error().
+			Str("entrypoint", next.executable).
+			Msg("Staged release entrypoint is outside of its directory, discarding it")
+
+		return s.discardStaged(staged)
+	}
+	newExecutablePath := filepath.Join(s.newPath, next.executable)
+
+	if err := os.Chmod(newExecutablePath, 0o700); err != nil {
+		logger.Error().
+			Err(err).
+			Str("newPath", newExecutablePath).
+			Msg("Failed to set permissions on new binary, discarding it")
+
+		return s.discardStaged(staged)
+	}
+
+	newDigest, err := digest.DigestFile(newExecutablePath)
+	if err != nil {
+		logger.Error().
+			Err(err).
+			Str("newPath", newExecutablePath).
+			Msg("Failed to calculate new binary digest, discarding it")
+
+		return s.discardStaged(staged)
+	}
+	expected := launcher.ExpectedHealth{Digest: newDigest}
+	if staged != nil {
+		expected.Version = staged.Version
+	}
+
+	if staged != nil && staged.Digest != hex.EncodeToString(newDigest) {
+		logger.Error().
+			Str("newPath", newExecutablePath).
+			Str("stagedDigest", staged.Digest).
+			Str("actualDigest", hex.EncodeToString(newDigest)).
+			Msg("New binary does not match the staged release, discarding it")
+
+		return s.discardStaged(staged)
+	}
+
+	// Swap! The release directory moves into place with a single rename, the current one stays
+	// untouched as the known good backup, in case the new one turns out to be unhealthy.
+	if err := os.Rename(s.newPath, next.dir); err != nil {
+		logger.Error().
+			Err(err).
+			Str("newPath", s.newPath).
+			Str("releasePath", next.dir).
+			Msg("Failed to move new release into place")
+
+		return s.discardStaged(nil)
+	}
+	s.dropBackup()
+	previous := s.current
+	s.backup = &previous
+	s.current = next
+	s.freshVersion = expected.Version
+	s.isFresh = true
+	s.crashes.Reset()
+
+	err = s.launch()
+	if err == nil {
+		err = s.orchestrator.WaitForHealthy(s.ctx, s.server.Exited, expected)
+		if err != nil {
+			_ = s.server.Cmd.Process.Kill()
+			<-s.server.Exited
 		}
 	}
+
+	if s.ctx.Err() != nil {
+		logger.Info().
+			Msg("Launcher is shutting down, not checking the new server any further")
+
+		return false
+	}
+
+	if err == nil {
+		logger.Info().
+			Str("version", expected.Version).
+			Str("digest", hex.EncodeToString(expected.Digest)).
+			Str("currentPath", s.current.executablePath()).
+			Msg("Update applied, new server is healthy")
+
+		return true
+	}
+
+	logger.Error().
+		Err(err).
+		Str("currentPath", s.current.executablePath()).
+		Str("version", expected.Version).
+		Msg("New server failed to start or is unhealthy, rolling back")
+
+	if err := s.rollback(); err != nil {
+		logger.Error().
+			Err(err).
+			Str("currentPath", s.current.executablePath()).
+			Msg("Failed to roll back to backup binary")
+
+		return false
+	}
+
+	return true
+}
+
+// nextRelease names the directory of the next release, so that a swap never replaces a release in use.
+func (s *launcherSession) nextRelease(executable string) release {
+	r := release{
+		dir:        filepath.Join(s.releasesPath, strconv.Itoa(s.releases)),
+		executable: executable,
+	}
+	s.releases++
+
+	return r
+}
+
+// discardStaged drops a staged release that won't be swapped in, and relaunches the current one.
+// Returns false when the launcher should stop.
+func (s *launcherSession) discardStaged(staged *models.StagedRelease) bool {
+	_ = os.RemoveAll(s.newPath)
+	if staged != nil {
+		s.orchestrator.RejectedVersions = append(s.orchestrator.RejectedVersions, staged.Version)
+	}
+
+	if err := s.launch(); err != nil {
+		s.logger.Error().
+			Err(err).
+			Str("currentPath", s.current.executablePath()).
+			Msg("Failed to relaunch server process")
+
+		return false
+	}
+
+	return true
+}
+
+// dropBackup removes the backup release, once a newer known good release replaces it.
+func (s *launcherSession) dropBackup() {
+	if s.backup == nil {
+		return
+	}
+
+	// On Windows, a server still draining from it keeps it from being removed, it goes with the session directory then.
+	if err := os.RemoveAll(s.backup.dir); err != nil {
+		s.logger.Warn().
+			Err(err).
+			Str("backupPath", s.backup.dir).
+			Msg("Failed to remove previous backup release")
+	}
+	s.backup = nil
+}
+
+// rollback switches back to the backup release and relaunches it.
+// The failed version is remembered, so that the server does not stage it again.
+func (s *launcherSession) rollback() error {
+	if s.backup == nil {
+		return errors.New("there is no backup release to roll back to")
+	}
+
+	failedVersion := s.freshVersion
+	if failedVersion != "" {
+		s.orchestrator.RejectedVersions = append(s.orchestrator.RejectedVersions, failedVersion)
+	}
+	s.freshVersion = ""
+	s.isFresh = false
+
+	failed := s.current
+	s.current = *s.backup
+	s.backup = nil
+	_ = os.RemoveAll(failed.dir)
+
+	if err := s.launch(); err != nil {
+		return err
+	}
+
+	s.logger.Info().
+		Str("rejectedVersion", failedVersion).
+		Str("currentPath", s.current.executablePath()).
+		Msg("Rolled back to previous binary")
+
+	return nil
+}
+
+func readStagedRelease(path string) (*models.StagedRelease, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read staged release: %w", err)
+	}
+
+	var staged models.StagedRelease
+	if err := json.Unmarshal(data, &staged); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal staged release: %w", err)
+	}
+
+	return &staged, nil
 }
@@ -5,6 +5,8 @@ import (
 	"flag"
 	"fmt"
 	"os"
+	"os/signal"
+	"syscall"
 
 	"github.com/danilevy1212/self-updater/internal/digest"
 	"github.com/danilevy1212/self-updater/internal/models"
@@ -37,10 +39,13 @@ func main() {
 		Commit,
 		currentExecutablePath,
 	)
-	ctx := context.Background()
+	// Both the launcher and the server shut down gracefully on termination signals.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
 	if *asServer {
 		code := runServer(ctx, am)
+		stop()
 		os.Exit(code)
 	} else {
 		runLauncher(ctx, am)
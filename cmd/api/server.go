@@ -2,14 +2,15 @@ package main
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"os"
 	"path/filepath"
 	"sync/atomic"
-	"time"
 
 	"github.com/rs/zerolog"
 
+	"github.com/danilevy1212/self-updater/internal/launcher"
 	"github.com/danilevy1212/self-updater/internal/models"
 	"github.com/danilevy1212/self-updater/internal/models/exitcodes"
 	"github.com/danilevy1212/self-updater/internal/server"
@@ -31,33 +32,59 @@ func runServer(ctx context.Context, am models.ApplicationMeta) int {
 	var exitCode atomic.Int32
 	exitCode.Store(int32(exitcodes.ExitOK))
 
-	updater, err := updater.New(ctx, am, func(newVersion *os.File, logger *zerolog.Logger) {
-		defer newVersion.Close()
-		newPath := filepath.Join(*sessionDirectory, getNewServerFileName(am))
+	updater, err := updater.New(ctx, am, func(newVersion *os.File, release models.StagedRelease, logger *zerolog.Logger) {
+		_ = newVersion.Close()
+		newPath := filepath.Join(*sessionDirectory, getNewReleaseDirName())
+		stagedReleasePath := filepath.Join(*sessionDirectory, getStagedReleaseFileName())
+
+		// Bundles come extracted, a bare executable makes a release directory of its own.
+		isBundle := release.Entrypoint != ""
+		if !isBundle {
+			release.Entrypoint = getServerFileName(am)
+		}
 
 		logger.Info().
 			Str("new_version_path", newPath).
+			Str("new_version", release.Version).
+			Str("entrypoint", release.Entrypoint).
 			Msg("New version ready to be applied")
 
-		if err := os.Rename(newVersion.Name(), newPath); err != nil {
+		if err := writeStagedRelease(stagedReleasePath, release); err != nil {
+			logger.Error().
+				Err(err).
+				Str("staged_release_path", stagedReleasePath).
+				Msg("Failed to write staged release metadata")
+
+			exitCode.Store(int32(exitcodes.ExitFatal))
+		} else if err := moveRelease(newVersion.Name(), newPath, release.Entrypoint, isBundle); err != nil {
 			logger.Error().
 				Err(err).
 				Str("new_version_path", newPath).
-				Msg("Failed to rename new version file")
+				Msg("Failed to move new version into the session directory")
 
 			exitCode.Store(int32(exitcodes.ExitFatal))
 		} else {
 			logger.Info().
 				Str("new_version_path", newPath).
-				Msg("New version file renamed successfully")
+				Msg("New version moved into the session directory successfully")
 
 			exitCode.Store(int32(exitcodes.ExitUpdateReady))
+
+			// Lets the launcher start the new version while this one drains its connections.
+			if notified, err := launcher.NotifyUpdateReady(); err != nil {
+				logger.Warn().
+					Err(err).
+					Msg("Failed to notify launcher, it will wait for this server to exit")
+			} else if notified {
+				logger.Info().
+					Msg("Launcher notified, new version starts while this server drains")
+			}
 		}
 
 		logger.Info().
 			Msg("Shutting down server after update")
 
-		c, cancel := context.WithTimeout(ctx, 10*time.Second)
+		c, cancel := context.WithTimeout(context.WithoutCancel(ctx), app.Config.ShutdownTimeout)
 		defer cancel()
 		_ = app.Shutdown(c)
 	})
@@ -66,6 +93,8 @@ func runServer(ctx context.Context, am models.ApplicationMeta) int {
 		return exitcodes.ExitFatal
 	}
 
+	app.DownloadProgress = updater.DownloadProgress
+
 	if updater.Config.RunAtBoot {
 		updater.Run()
 	}
@@ -75,6 +104,33 @@ func runServer(ctx context.Context, am models.ApplicationMeta) int {
 		return exitcodes.ExitFatal
 	}
 
+	go func() {
+		<-ctx.Done()
+		logger := app.Logger.With().
+			Str("handler", "runServer").
+			Logger()
+
+		logger.Info().
+			Dur("shutdown_timeout", app.Config.ShutdownTimeout).
+			Msg("Termination signal received, shutting down")
+
+		c, cancel := context.WithTimeout(context.WithoutCancel(ctx), app.Config.ShutdownTimeout)
+		defer cancel()
+
+		select {
+		case <-updater.Stop().Done():
+		case <-c.Done():
+			logger.Warn().
+				Msg("Updater job did not finish before the shutdown timeout")
+		}
+
+		if err := app.Shutdown(c); err != nil {
+			logger.Error().
+				Err(err).
+				Msg("Failed to gracefully shut down server")
+		}
+	}()
+
 	app.RegisterGlobalMiddleware()
 	app.RegisterRoutes()
 
@@ -85,3 +141,34 @@ func runServer(ctx context.Context, am models.ApplicationMeta) int {
 
 	return int(exitCode.Load())
 }
+
+// moveRelease moves what the updater staged to dst, as a release directory for the launcher to swap in.
+func moveRelease(src, dst, entrypoint string, isBundle bool) error {
+	// Left over by a server that staged a release but never got swapped out, e.g. because it crashed.
+	if err := os.RemoveAll(dst); err != nil {
+		return fmt.Errorf("failed to remove previously staged release: %w", err)
+	}
+
+	if isBundle {
+		return os.Rename(src, dst)
+	}
+
+	if err := os.Mkdir(dst, 0o700); err != nil {
+		return fmt.Errorf("failed to create release directory: %w", err)
+	}
+
+	return os.Rename(src, filepath.Join(dst, entrypoint))
+}
+
+func writeStagedRelease(path string, release models.StagedRelease) error {
+	data, err := json.Marshal(release)
+	if err != nil {
+		return fmt.Errorf("failed to marshal staged release: %w", err)
+	}
+
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		return fmt.Errorf("failed to write staged release: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/danilevy1212/self-updater/internal/delta"
+)
+
+func main() {
+	if len(os.Args) != 4 {
+		fmt.Fprintln(os.Stderr, "Usage: delta <old-file> <new-file> <patch-file>")
+		os.Exit(1)
+	}
+
+	oldData, err := os.ReadFile(os.Args[1])
+	if err != nil {
+		panic(err)
+	}
+	newData, err := os.ReadFile(os.Args[2])
+	if err != nil {
+		panic(err)
+	}
+
+	patch, err := delta.Diff(oldData, newData)
+	if err != nil {
+		panic(err)
+	}
+
+	if err := os.WriteFile(os.Args[3], patch, 0o644); err != nil {
+		panic(err)
+	}
+}
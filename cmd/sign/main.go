@@ -5,20 +5,31 @@ import (
 	"crypto/x509"
 	"encoding/base64"
 	"encoding/pem"
+	"flag"
 	"fmt"
 	"os"
 
+	"github.com/danilevy1212/self-updater/internal/audit"
 	"github.com/danilevy1212/self-updater/internal/digest"
 )
 
+// Installations predating key IDs can only read bare signatures, envelopes are opt-in until none are left.
+var withKeyID = flag.Bool("key-id", false, "print the signature in an envelope naming the key that made it")
+
 func main() {
-	if len(os.Args) != 3 {
-		fmt.Fprintln(os.Stderr, "Usage: sign <private.pem> <file-to-sign>")
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: sign [-key-id] <private.pem> <file-to-sign>")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() != 2 {
+		flag.Usage()
 		os.Exit(1)
 	}
 
-	keyPath := os.Args[1]
-	filePath := os.Args[2]
+	keyPath := flag.Arg(0)
+	filePath := flag.Arg(1)
 
 	keyData, err := os.ReadFile(keyPath)
 	if err != nil {
@@ -39,6 +50,21 @@ func main() {
 		panic(err)
 	}
 
-	sig := ed25519.Sign(privKey, payload)
-	fmt.Println(base64.StdEncoding.EncodeToString(sig))
+	sig := base64.StdEncoding.EncodeToString(ed25519.Sign(privKey, payload))
+	if !*withKeyID {
+		fmt.Println(sig)
+		return
+	}
+
+	publicKeyDER, err := x509.MarshalPKIXPublicKey(privKey.Public())
+	if err != nil {
+		panic(err)
+	}
+	keyID, err := audit.KeyID(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicKeyDER}))
+	if err != nil {
+		panic(err)
+	}
+
+	// The envelope names the key, so that installations trusting several keys know which one to verify with.
+	fmt.Println(audit.Envelope(keyID, sig))
 }
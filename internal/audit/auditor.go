@@ -2,16 +2,16 @@ package audit
 
 import (
 	"crypto/ed25519"
-	"crypto/x509"
 	"encoding/base64"
 	"encoding/hex"
-	"encoding/pem"
 	"errors"
 	"fmt"
 )
 
 type VerifySignatureFunc func(publicKey []byte, messageHex, signature string) (bool, error)
 
+// verifyED22519Signature verifies signature against the trusted keys, publicKey holds one or more PEM blocks.
+// A signature in an envelope is only checked against the key it names, a bare one against every key.
 func verifyED22519Signature(publicKey []byte, messageHex, signature string) (bool, error) {
 	if len(publicKey) == 0 {
 		return false, errors.New("public key is empty")
@@ -21,17 +21,14 @@ func verifyED22519Signature(publicKey []byte, messageHex, signature string) (boo
 		return false, errors.New("signature is empty")
 	}
 
-	block, _ := pem.Decode(publicKey)
-	if block == nil {
-		return false, errors.New("failed to decode PEM block")
-	}
+	keyID, encodedSignature, hasKeyID := parseEnvelope(signature)
 
-	pubKey, err := x509.ParsePKIXPublicKey(block.Bytes)
+	keys, err := parsePublicKeys(publicKey)
 	if err != nil {
-		return false, fmt.Errorf("failed to parse public key: %w", err)
+		return false, err
 	}
 
-	rawSignature, err := base64.StdEncoding.DecodeString(signature)
+	rawSignature, err := base64.StdEncoding.DecodeString(encodedSignature)
 	if err != nil {
 		return false, fmt.Errorf("failed to decode base64 signature: %w", err)
 	}
@@ -41,11 +38,39 @@ func verifyED22519Signature(publicKey []byte, messageHex, signature string) (boo
 		return false, fmt.Errorf("failed to decode hex digest: %w", err)
 	}
 
-	switch pub := pubKey.(type) {
+	if hasKeyID {
+		for _, key := range keys {
+			if key.id == keyID {
+				return verifyWithKey(key, messageBytes, rawSignature)
+			}
+		}
+
+		return false, fmt.Errorf("signature key %s is not trusted", keyID)
+	}
+
+	// A key that can't verify doesn't stop the others from being tried, its error is only reported when
+	// none of them verifies the signature.
+	var errs []error
+	for _, key := range keys {
+		isVerified, err := verifyWithKey(key, messageBytes, rawSignature)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if isVerified {
+			return true, nil
+		}
+	}
+
+	return false, errors.Join(errs...)
+}
+
+func verifyWithKey(key publicKey, message, signature []byte) (bool, error) {
+	switch pub := key.key.(type) {
 	case ed25519.PublicKey:
-		return ed25519.Verify(pub, messageBytes, rawSignature), nil
+		return ed25519.Verify(pub, message, signature), nil
 	default:
-		return false, fmt.Errorf("unsupported public key type: %T", pubKey)
+		return false, fmt.Errorf("unsupported public key type: %T", key.key)
 	}
 }
 
@@ -47,6 +47,14 @@ aGVsbG8gd29ybGQgdGhpcyBpcyBub3QgYSB2YWxpZCBwdWJsaWMga2V5
 		assert.Contains(t, err.Error(), "unsupported public key type")
 	})
 
+	t.Run("should try the keys after one that can't verify a bare signature", func(t *testing.T) {
+		keys := append(append([]byte{}, fixtures.RSAPublicKey...), publicKey...)
+
+		validSignature, err := verifyED22519Signature(keys, message, signature)
+		assert.NoError(t, err)
+		assert.True(t, validSignature)
+	})
+
 	t.Run("should error if signature is not base64 encoded", func(t *testing.T) {
 		_, err := verifyED22519Signature(publicKey, message, "invalidBase64Signature")
 		assert.Error(t, err)
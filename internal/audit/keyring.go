@@ -0,0 +1,188 @@
+package audit
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/rs/zerolog"
+
+	"github.com/danilevy1212/self-updater/internal/models"
+)
+
+// KeyRing is the set of keys trusted to sign releases. It starts with the keys embedded in the binary
+// and follows the key rotations published in manifests, so that a compromised or lost key can be
+// replaced without shipping every installation a new binary by hand.
+type KeyRing struct {
+	// Persists the key ring whenever a rotation is applied
+	Save func(models.KeyRingState) error
+
+	mu    sync.Mutex
+	state models.KeyRingState
+}
+
+// NewKeyRing trusts the embedded PEM keys, or the keys of state once it applied a rotation.
+// Revoked keys are never trusted, even when embedded.
+func NewKeyRing(embedded []byte, state models.KeyRingState, save func(models.KeyRingState) error) *KeyRing {
+	keys := splitPEM(embedded)
+	if state.Version > 0 {
+		keys = state.Keys
+	}
+	state.Keys = withoutRevoked(keys, state.Revoked)
+
+	return &KeyRing{
+		Save:  save,
+		state: state,
+	}
+}
+
+// State returns the keys trusted so far.
+func (k *KeyRing) State() models.KeyRingState {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	return k.state
+}
+
+// Clone copies the trusted keys, e.g. to apply the key rotations of a manifest not verified yet.
+// The copy is never persisted, see Adopt.
+func (k *KeyRing) Clone() *KeyRing {
+	return &KeyRing{state: k.State()}
+}
+
+// Adopt trusts the keys of a copy, once the manifest whose key rotations were applied to it is verified.
+func (k *KeyRing) Adopt(logger *zerolog.Logger, rotated *KeyRing) {
+	state := rotated.State()
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	if state.Version <= k.state.Version {
+		return
+	}
+	k.set(logger, state)
+}
+
+// PEM returns the trusted keys as PEM blocks, to verify signatures with VerifySignature.
+func (k *KeyRing) PEM() []byte {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	return []byte(strings.Join(k.state.Keys, ""))
+}
+
+// Trusts tells if the PEM encoded key is one of the trusted keys.
+func (k *KeyRing) Trusts(publicKeyPEM string) bool {
+	id, err := KeyID([]byte(publicKeyPEM))
+	if err != nil {
+		return false
+	}
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	return slices.ContainsFunc(k.state.Keys, func(key string) bool {
+		trustedID, err := KeyID([]byte(key))
+		return err == nil && trustedID == id
+	})
+}
+
+// Rotate applies the rotations newer than the trusted keys, in order. Each one must be signed by a key
+// trusted before it, others are skipped: they were forged, or signed by a key older than the embedded ones.
+func (k *KeyRing) Rotate(logger *zerolog.Logger, rotations []models.SignedKeyRotation) error {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	state := k.state
+	for _, signed := range rotations {
+		document, err := base64.StdEncoding.DecodeString(signed.Document)
+		if err != nil {
+			return fmt.Errorf("failed to decode key rotation document: %w", err)
+		}
+
+		var rotation models.KeyRotation
+		if err := json.Unmarshal(document, &rotation); err != nil {
+			return fmt.Errorf("failed to unmarshal key rotation: %w", err)
+		}
+
+		if rotation.Version <= state.Version {
+			continue
+		}
+
+		digest := sha256.Sum256(document)
+		isVerified, err := VerifySignature([]byte(strings.Join(state.Keys, "")), hex.EncodeToString(digest[:]), signed.Signature)
+		if err != nil || !isVerified {
+			logger.Warn().
+				Err(err).
+				Int64("key_rotation_version", rotation.Version).
+				Msg("Skipping key rotation not signed by a trusted key")
+
+			continue
+		}
+
+		var keys []string
+		for _, key := range rotation.Keys {
+			if _, err := KeyID([]byte(key)); err != nil {
+				return fmt.Errorf("key rotation %d has an invalid key: %w", rotation.Version, err)
+			}
+			keys = append(keys, splitPEM([]byte(key))...)
+		}
+
+		revoked := slices.Clone(state.Revoked)
+		for _, id := range rotation.Revoked {
+			if !slices.Contains(revoked, id) {
+				revoked = append(revoked, id)
+			}
+		}
+
+		keys = withoutRevoked(keys, revoked)
+		if len(keys) == 0 {
+			return fmt.Errorf("key rotation %d leaves no trusted keys", rotation.Version)
+		}
+
+		state = models.KeyRingState{
+			Version: rotation.Version,
+			Keys:    keys,
+			Revoked: revoked,
+		}
+
+		logger.Info().
+			Int64("key_rotation_version", rotation.Version).
+			Int("trusted_keys", len(keys)).
+			Strs("revoked_keys", rotation.Revoked).
+			Msg("Applied key rotation")
+	}
+
+	if state.Version == k.state.Version {
+		return nil
+	}
+	k.set(logger, state)
+
+	return nil
+}
+
+// set trusts the keys of state and persists them, k.mu must be held.
+func (k *KeyRing) set(logger *zerolog.Logger, state models.KeyRingState) {
+	k.state = state
+
+	if k.Save != nil {
+		if err := k.Save(state); err != nil {
+			logger.Warn().
+				Err(err).
+				Msg("Failed to persist key ring, rotations will be applied again after restart")
+		}
+	}
+}
+
+// withoutRevoked drops the keys with a revoked ID.
+func withoutRevoked(keys, revoked []string) []string {
+	return slices.DeleteFunc(slices.Clone(keys), func(key string) bool {
+		id, err := KeyID([]byte(key))
+		return err == nil && slices.Contains(revoked, id)
+	})
+}
@@ -0,0 +1,139 @@
+package audit
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/danilevy1212/self-updater/internal/models"
+)
+
+func signedRotation(t *testing.T, signer testSigner, rotation models.KeyRotation) models.SignedKeyRotation {
+	t.Helper()
+
+	document, err := json.Marshal(rotation)
+	assert.NoError(t, err)
+
+	return models.SignedKeyRotation{
+		Document:  base64.StdEncoding.EncodeToString(document),
+		Signature: signer.sign(document),
+	}
+}
+
+func Test_KeyRing(t *testing.T) {
+	logger := zerolog.Nop()
+	old := newTestSigner(t)
+	current := newTestSigner(t)
+	next := newTestSigner(t)
+
+	t.Run("should trust the embedded keys until a rotation is applied", func(t *testing.T) {
+		keys := NewKeyRing([]byte(old.publicKeyPEM+current.publicKeyPEM), models.KeyRingState{}, nil)
+
+		assert.True(t, keys.Trusts(old.publicKeyPEM))
+		assert.True(t, keys.Trusts(current.publicKeyPEM))
+		assert.False(t, keys.Trusts(next.publicKeyPEM))
+		assert.False(t, keys.Trusts("not a key"))
+	})
+
+	t.Run("should trust the persisted keys over the embedded ones", func(t *testing.T) {
+		keys := NewKeyRing([]byte(old.publicKeyPEM), models.KeyRingState{Version: 1, Keys: []string{current.publicKeyPEM}}, nil)
+
+		assert.False(t, keys.Trusts(old.publicKeyPEM))
+		assert.True(t, keys.Trusts(current.publicKeyPEM))
+	})
+
+	t.Run("should never trust revoked keys, even when embedded", func(t *testing.T) {
+		keys := NewKeyRing([]byte(old.publicKeyPEM+current.publicKeyPEM), models.KeyRingState{Revoked: []string{old.keyID}}, nil)
+
+		assert.False(t, keys.Trusts(old.publicKeyPEM))
+		assert.Equal(t, current.publicKeyPEM, string(keys.PEM()))
+	})
+
+	t.Run("should apply rotations signed by a key trusted before them and save them", func(t *testing.T) {
+		var saved []models.KeyRingState
+		keys := NewKeyRing([]byte(old.publicKeyPEM), models.KeyRingState{}, func(state models.KeyRingState) error {
+			saved = append(saved, state)
+			return nil
+		})
+
+		err := keys.Rotate(&logger, []models.SignedKeyRotation{
+			signedRotation(t, old, models.KeyRotation{Version: 1, Keys: []string{current.publicKeyPEM}, Revoked: []string{old.keyID}}),
+			signedRotation(t, current, models.KeyRotation{Version: 2, Keys: []string{current.publicKeyPEM, next.publicKeyPEM}}),
+		})
+		assert.NoError(t, err)
+
+		want := models.KeyRingState{Version: 2, Keys: []string{current.publicKeyPEM, next.publicKeyPEM}, Revoked: []string{old.keyID}}
+		assert.Equal(t, want, keys.State())
+		assert.Equal(t, []models.KeyRingState{want}, saved)
+		assert.False(t, keys.Trusts(old.publicKeyPEM))
+	})
+
+	t.Run("should skip rotations it already applied", func(t *testing.T) {
+		saved := false
+		keys := NewKeyRing(nil, models.KeyRingState{Version: 1, Keys: []string{current.publicKeyPEM}}, func(models.KeyRingState) error {
+			saved = true
+			return nil
+		})
+
+		err := keys.Rotate(&logger, []models.SignedKeyRotation{
+			signedRotation(t, old, models.KeyRotation{Version: 1, Keys: []string{old.publicKeyPEM}}),
+		})
+		assert.NoError(t, err)
+		assert.True(t, keys.Trusts(current.publicKeyPEM))
+		assert.False(t, keys.Trusts(old.publicKeyPEM))
+		assert.False(t, saved)
+	})
+
+	t.Run("should skip rotations not signed by a trusted key", func(t *testing.T) {
+		attacker := newTestSigner(t)
+		keys := NewKeyRing([]byte(current.publicKeyPEM), models.KeyRingState{}, nil)
+
+		forged := signedRotation(t, attacker, models.KeyRotation{Version: 1, Keys: []string{attacker.publicKeyPEM}})
+		// A signature of the trusted key, lifted from another document
+		tampered := signedRotation(t, current, models.KeyRotation{Version: 1, Keys: []string{current.publicKeyPEM}})
+		tampered.Document = forged.Document
+
+		err := keys.Rotate(&logger, []models.SignedKeyRotation{forged, tampered})
+		assert.NoError(t, err)
+		assert.Equal(t, int64(0), keys.State().Version)
+		assert.False(t, keys.Trusts(attacker.publicKeyPEM))
+	})
+
+	t.Run("should catch up from rotations older than its embedded keys", func(t *testing.T) {
+		keys := NewKeyRing([]byte(current.publicKeyPEM), models.KeyRingState{}, nil)
+
+		err := keys.Rotate(&logger, []models.SignedKeyRotation{
+			signedRotation(t, old, models.KeyRotation{Version: 1, Keys: []string{current.publicKeyPEM}}),
+			signedRotation(t, current, models.KeyRotation{Version: 2, Keys: []string{next.publicKeyPEM}}),
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, int64(2), keys.State().Version)
+		assert.True(t, keys.Trusts(next.publicKeyPEM))
+	})
+
+	t.Run("should refuse a rotation that leaves no trusted keys", func(t *testing.T) {
+		keys := NewKeyRing([]byte(current.publicKeyPEM), models.KeyRingState{}, nil)
+
+		err := keys.Rotate(&logger, []models.SignedKeyRotation{
+			signedRotation(t, current, models.KeyRotation{Version: 1, Keys: []string{current.publicKeyPEM}, Revoked: []string{current.keyID}}),
+		})
+		assert.EqualError(t, err, "key rotation 1 leaves no trusted keys")
+		assert.True(t, keys.Trusts(current.publicKeyPEM))
+	})
+
+	t.Run("should keep applied rotations when they can't be saved", func(t *testing.T) {
+		keys := NewKeyRing([]byte(current.publicKeyPEM), models.KeyRingState{}, func(models.KeyRingState) error {
+			return errors.New("disk full")
+		})
+
+		err := keys.Rotate(&logger, []models.SignedKeyRotation{
+			signedRotation(t, current, models.KeyRotation{Version: 1, Keys: []string{next.publicKeyPEM}}),
+		})
+		assert.NoError(t, err)
+		assert.True(t, keys.Trusts(next.publicKeyPEM))
+	})
+}
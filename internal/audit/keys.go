@@ -0,0 +1,102 @@
+package audit
+
+import (
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// publicKey is a trusted key along with its key ID.
+type publicKey struct {
+	id  string
+	key any
+}
+
+// KeyID identifies a PEM encoded public key, it is the hex SHA256 digest of the DER encoded key.
+// The same as `openssl pkey -pubin -in public.pem -outform DER | sha256sum`.
+func KeyID(publicKeyPEM []byte) (string, error) {
+	block, _ := pem.Decode(publicKeyPEM)
+	if block == nil {
+		return "", errors.New("failed to decode PEM block")
+	}
+
+	if _, err := x509.ParsePKIXPublicKey(block.Bytes); err != nil {
+		return "", fmt.Errorf("failed to parse public key: %w", err)
+	}
+
+	return keyID(block.Bytes), nil
+}
+
+func keyID(der []byte) string {
+	digest := sha256.Sum256(der)
+	return hex.EncodeToString(digest[:])
+}
+
+// Envelope names the key a base64 signature was made with, as `<key id>:<signature>`.
+func Envelope(keyID, signature string) string {
+	return keyID + ":" + signature
+}
+
+// parseEnvelope splits a signature envelope, base64 has no colons so a bare signature has no key ID.
+func parseEnvelope(signature string) (string, string, bool) {
+	keyID, encodedSignature, hasKeyID := strings.Cut(strings.TrimSpace(signature), ":")
+	if !hasKeyID {
+		return "", signature, false
+	}
+
+	return keyID, encodedSignature, true
+}
+
+// parsePublicKeys parses every PEM block of a key set.
+func parsePublicKeys(publicKeys []byte) ([]publicKey, error) {
+	var keys []publicKey
+
+	rest := publicKeys
+	for {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+
+		key, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse public key: %w", err)
+		}
+
+		keys = append(keys, publicKey{id: keyID(block.Bytes), key: key})
+	}
+
+	if len(keys) == 0 {
+		return nil, errors.New("failed to decode PEM block")
+	}
+
+	return keys, nil
+}
+
+// splitPEM splits a key set into its PEM blocks. Data without any is kept as is, so that verifying
+// with it reports why it isn't a key.
+func splitPEM(data []byte) []string {
+	var blocks []string
+
+	rest := data
+	for {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+
+		blocks = append(blocks, string(pem.EncodeToMemory(block)))
+	}
+
+	if len(blocks) == 0 && len(strings.TrimSpace(string(data))) > 0 {
+		return []string{string(data)}
+	}
+
+	return blocks
+}
@@ -0,0 +1,112 @@
+package audit
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testSigner struct {
+	publicKeyPEM string
+	keyID        string
+	privateKey   ed25519.PrivateKey
+}
+
+func newTestSigner(t *testing.T) testSigner {
+	t.Helper()
+
+	publicKey, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	assert.NoError(t, err)
+
+	der, err := x509.MarshalPKIXPublicKey(publicKey)
+	assert.NoError(t, err)
+
+	return testSigner{
+		publicKeyPEM: string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})),
+		keyID:        keyID(der),
+		privateKey:   privateKey,
+	}
+}
+
+// sign returns the enveloped signature of data's digest, like cmd/sign.
+func (s testSigner) sign(data []byte) string {
+	digest := sha256.Sum256(data)
+	return Envelope(s.keyID, base64.StdEncoding.EncodeToString(ed25519.Sign(s.privateKey, digest[:])))
+}
+
+func digestHex(data []byte) string {
+	digest := sha256.Sum256(data)
+	return hex.EncodeToString(digest[:])
+}
+
+func Test_KeyID(t *testing.T) {
+	t.Run("should identify a key by the digest of its DER encoding", func(t *testing.T) {
+		// openssl pkey -pubin -in public.pem -outform DER | sha256sum
+		id, err := KeyID([]byte(releaseFixture.PublicKey))
+		assert.NoError(t, err)
+		assert.Len(t, id, 64)
+
+		signer := newTestSigner(t)
+		id, err = KeyID([]byte(signer.publicKeyPEM))
+		assert.NoError(t, err)
+		assert.Equal(t, signer.keyID, id)
+	})
+
+	t.Run("should return error if the key is not in pem format", func(t *testing.T) {
+		_, err := KeyID([]byte("not a key"))
+		assert.EqualError(t, err, "failed to decode PEM block")
+	})
+}
+
+func Test_verifyED22519Signature_keySet(t *testing.T) {
+	message := []byte("release.json")
+	first := newTestSigner(t)
+	second := newTestSigner(t)
+	keySet := []byte(first.publicKeyPEM + second.publicKeyPEM)
+
+	t.Run("should verify with the key named by the envelope", func(t *testing.T) {
+		isVerified, err := verifyED22519Signature(keySet, digestHex(message), second.sign(message))
+		assert.NoError(t, err)
+		assert.True(t, isVerified)
+	})
+
+	t.Run("should accept a trailing newline after the envelope", func(t *testing.T) {
+		isVerified, err := verifyED22519Signature(keySet, digestHex(message), first.sign(message)+"\n")
+		assert.NoError(t, err)
+		assert.True(t, isVerified)
+	})
+
+	t.Run("should return error if the envelope names a key that is not trusted", func(t *testing.T) {
+		untrusted := newTestSigner(t)
+
+		_, err := verifyED22519Signature(keySet, digestHex(message), untrusted.sign(message))
+		assert.EqualError(t, err, "signature key "+untrusted.keyID+" is not trusted")
+	})
+
+	t.Run("should not verify a signature made by another key than the one named", func(t *testing.T) {
+		_, signature, _ := parseEnvelope(first.sign(message))
+
+		isVerified, err := verifyED22519Signature(keySet, digestHex(message), Envelope(second.keyID, signature))
+		assert.NoError(t, err)
+		assert.False(t, isVerified)
+	})
+
+	t.Run("should check a bare signature against every key", func(t *testing.T) {
+		_, signature, _ := parseEnvelope(second.sign(message))
+
+		isVerified, err := verifyED22519Signature(keySet, digestHex(message), signature)
+		assert.NoError(t, err)
+		assert.True(t, isVerified)
+
+		isVerified, err = verifyED22519Signature([]byte(first.publicKeyPEM), digestHex(message), signature)
+		assert.NoError(t, err)
+		assert.False(t, isVerified)
+	})
+}
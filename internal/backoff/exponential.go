@@ -0,0 +1,48 @@
+package backoff
+
+import (
+	"math"
+	"math/rand/v2"
+	"time"
+)
+
+// For testing sake
+type RandFunc func() float64
+
+var RandFloat64 RandFunc = rand.Float64
+
+// Exponential computes delays that grow by Multiplier on every attempt, capped at Max.
+// Jitter is the fraction of the delay that is randomized, 0.2 means +/- 20%.
+type Exponential struct {
+	Initial    time.Duration
+	Max        time.Duration
+	Multiplier float64
+	Jitter     float64
+}
+
+// Delay returns the delay before the given attempt, attempts start at 0.
+func (e Exponential) Delay(attempt int) time.Duration {
+	if attempt < 0 {
+		attempt = 0
+	}
+
+	multiplier := e.Multiplier
+	if multiplier < 1 {
+		multiplier = 2
+	}
+
+	delay := float64(e.Initial) * math.Pow(multiplier, float64(attempt))
+	if e.Max > 0 && delay > float64(e.Max) {
+		delay = float64(e.Max)
+	}
+
+	if e.Jitter > 0 {
+		delay += delay * e.Jitter * (2*RandFloat64() - 1)
+	}
+
+	if e.Max > 0 && delay > float64(e.Max) {
+		delay = float64(e.Max)
+	}
+
+	return time.Duration(delay)
+}
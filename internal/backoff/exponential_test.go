@@ -0,0 +1,68 @@
+package backoff
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Exponential_Delay(t *testing.T) {
+	t.Run("should grow exponentially until max", func(t *testing.T) {
+		b := Exponential{
+			Initial: time.Second,
+			Max:     10 * time.Second,
+		}
+
+		assert.Equal(t, time.Second, b.Delay(0))
+		assert.Equal(t, 2*time.Second, b.Delay(1))
+		assert.Equal(t, 4*time.Second, b.Delay(2))
+		assert.Equal(t, 8*time.Second, b.Delay(3))
+		assert.Equal(t, 10*time.Second, b.Delay(4))
+		assert.Equal(t, 10*time.Second, b.Delay(100))
+	})
+
+	t.Run("should use custom multiplier", func(t *testing.T) {
+		b := Exponential{
+			Initial:    time.Second,
+			Multiplier: 3,
+		}
+
+		assert.Equal(t, 9*time.Second, b.Delay(2))
+	})
+
+	t.Run("should apply jitter within bounds", func(t *testing.T) {
+		old := RandFloat64
+		defer func() {
+			RandFloat64 = old
+		}()
+
+		b := Exponential{
+			Initial: 10 * time.Second,
+			Max:     time.Minute,
+			Jitter:  0.5,
+		}
+
+		RandFloat64 = func() float64 { return 0 }
+		assert.Equal(t, 5*time.Second, b.Delay(0), "lowest jitter should halve the delay")
+
+		RandFloat64 = func() float64 { return 1 }
+		assert.Equal(t, 15*time.Second, b.Delay(0), "highest jitter should add half the delay")
+	})
+
+	t.Run("should never exceed max with jitter", func(t *testing.T) {
+		old := RandFloat64
+		defer func() {
+			RandFloat64 = old
+		}()
+		RandFloat64 = func() float64 { return 1 }
+
+		b := Exponential{
+			Initial: time.Minute,
+			Max:     time.Minute,
+			Jitter:  0.5,
+		}
+
+		assert.Equal(t, time.Minute, b.Delay(3))
+	})
+}
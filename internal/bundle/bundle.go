@@ -0,0 +1,314 @@
+package bundle
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	FormatTarGz = "tar.gz"
+	FormatZip   = "zip"
+
+	// Symlink targets stored as the contents of zip entries are never longer than this
+	maxLinkTarget = 4096
+)
+
+// Limits bound what a bundle may extract to.
+type Limits struct {
+	MaxFiles int
+	// Total size of the extracted files, in bytes
+	MaxSize int64
+}
+
+// Supported tells whether bundles in the given format can be extracted.
+func Supported(format string) bool {
+	return format == FormatTarGz || format == FormatZip
+}
+
+// entry is a file, directory or symlink in an archive, whatever its format.
+type entry struct {
+	name     string
+	mode     fs.FileMode
+	linkname string
+	open     func() (io.ReadCloser, error)
+}
+
+// Extract extracts archive into dir, which should be empty. Entries can't end up outside of dir, neither
+// through their names nor through symlinks, and are bounded by limits.
+func Extract(archive *os.File, format, dir string, limits Limits) error {
+	root, err := os.OpenRoot(dir)
+	if err != nil {
+		return fmt.Errorf("failed to open bundle directory: %w", err)
+	}
+	defer root.Close()
+
+	x := &extractor{
+		root:   root,
+		dir:    dir,
+		limits: limits,
+	}
+
+	switch format {
+	case FormatTarGz:
+		return walkTarGz(archive, x.extract)
+	case FormatZip:
+		return walkZip(archive, x.extract)
+	default:
+		return fmt.Errorf("unsupported bundle format `%s`", format)
+	}
+}
+
+func walkTarGz(archive *os.File, fn func(entry) error) error {
+	gz, err := gzip.NewReader(archive)
+	if err != nil {
+		return fmt.Errorf("failed to read gzip stream: %w", err)
+	}
+	defer gz.Close()
+
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read tar entry: %w", err)
+		}
+
+		e := entry{
+			name:     hdr.Name,
+			linkname: hdr.Linkname,
+			open: func() (io.ReadCloser, error) {
+				return io.NopCloser(tr), nil
+			},
+		}
+		switch hdr.Typeflag {
+		case tar.TypeReg:
+			e.mode = fs.FileMode(hdr.Mode).Perm()
+		case tar.TypeDir:
+			e.mode = fs.ModeDir
+		case tar.TypeSymlink:
+			e.mode = fs.ModeSymlink
+		case tar.TypeXGlobalHeader:
+			continue
+		default:
+			// Hard links, devices and the like have no place in a release.
+			return fmt.Errorf("entry %q has unsupported type %q", hdr.Name, hdr.Typeflag)
+		}
+
+		if err := fn(e); err != nil {
+			return err
+		}
+	}
+}
+
+func walkZip(archive *os.File, fn func(entry) error) error {
+	info, err := archive.Stat()
+	if err != nil {
+		return fmt.Errorf("failed to stat zip archive: %w", err)
+	}
+
+	zr, err := zip.NewReader(archive, info.Size())
+	if err != nil {
+		return fmt.Errorf("failed to read zip archive: %w", err)
+	}
+
+	for _, f := range zr.File {
+		e := entry{
+			name: f.Name,
+			mode: f.Mode(),
+			open: f.Open,
+		}
+
+		if e.mode&fs.ModeSymlink != 0 {
+			rc, err := f.Open()
+			if err != nil {
+				return fmt.Errorf("failed to open zip entry %q: %w", f.Name, err)
+			}
+			target, err := io.ReadAll(io.LimitReader(rc, maxLinkTarget))
+			_ = rc.Close()
+			if err != nil {
+				return fmt.Errorf("failed to read zip entry %q: %w", f.Name, err)
+			}
+			e.linkname = string(target)
+		}
+
+		if err := fn(e); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+type extractor struct {
+	root   *os.Root
+	dir    string
+	limits Limits
+	files  int
+	size   int64
+}
+
+func (x *extractor) extract(e entry) error {
+	// Archives use forward slashes, a backslash is either a mistake or an attempt to confuse Windows.
+	if strings.Contains(e.name, `\`) {
+		return fmt.Errorf("entry %q has a backslash in its name", e.name)
+	}
+	name := filepath.Clean(filepath.FromSlash(e.name))
+	if !filepath.IsLocal(name) {
+		return fmt.Errorf("entry %q is outside of the bundle", e.name)
+	}
+	if name == "." {
+		return nil
+	}
+
+	x.files++
+	if x.files > x.limits.MaxFiles {
+		return fmt.Errorf("bundle has more than %d entries", x.limits.MaxFiles)
+	}
+
+	if err := x.mkdirParents(name); err != nil {
+		return err
+	}
+
+	switch {
+	case e.mode.IsDir():
+		return x.mkdir(name)
+	case e.mode&fs.ModeSymlink != 0:
+		return x.symlink(name, e.linkname)
+	case e.mode.IsRegular():
+		return x.writeFile(name, e)
+	default:
+		return fmt.Errorf("entry %q has unsupported mode %s", e.name, e.mode)
+	}
+}
+
+// mkdirParents creates the missing parents of name. Entries can't be nested under symlinks, a symlink that
+// stays within the bundle may still place what is nested under it elsewhere.
+func (x *extractor) mkdirParents(name string) error {
+	parts := strings.Split(filepath.Dir(name), string(filepath.Separator))
+	for i := range parts {
+		parent := filepath.Join(parts[:i+1]...)
+		if parent == "." {
+			continue
+		}
+
+		info, err := x.root.Lstat(parent)
+		switch {
+		case errors.Is(err, fs.ErrNotExist):
+			if err := x.root.Mkdir(parent, 0o700); err != nil {
+				return fmt.Errorf("failed to create directory %q: %w", parent, err)
+			}
+		case err != nil:
+			return fmt.Errorf("failed to stat %q: %w", parent, err)
+		case info.Mode()&fs.ModeSymlink != 0:
+			return fmt.Errorf("entry %q is nested under symlink %q", name, parent)
+		case !info.IsDir():
+			return fmt.Errorf("entry %q is nested under file %q", name, parent)
+		}
+	}
+
+	return nil
+}
+
+func (x *extractor) mkdir(name string) error {
+	err := x.root.Mkdir(name, 0o700)
+	if errors.Is(err, fs.ErrExist) {
+		if info, statErr := x.root.Lstat(name); statErr == nil && info.IsDir() {
+			return nil
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("failed to create directory %q: %w", name, err)
+	}
+
+	return nil
+}
+
+// symlink creates a symlink whose target is walked the way it will be resolved. `..` can't leave the bundle,
+// and the directories the target goes through must already be extracted, as a symlink in their place, now
+// or created later, could take it anywhere. The symlink the target may end on stays within the bundle too.
+func (x *extractor) symlink(name, target string) error {
+	if target == "" || filepath.IsAbs(target) || strings.Contains(target, `\`) {
+		return fmt.Errorf("symlink %q has invalid target %q", name, target)
+	}
+
+	var resolved []string
+	if dir := filepath.Dir(name); dir != "." {
+		resolved = strings.Split(dir, string(filepath.Separator))
+	}
+	components := strings.Split(target, "/")
+	for i, c := range components {
+		switch c {
+		case "", ".":
+			continue
+		case "..":
+			if len(resolved) == 0 {
+				return fmt.Errorf("symlink %q points outside of the bundle", name)
+			}
+			resolved = resolved[:len(resolved)-1]
+			continue
+		}
+
+		resolved = append(resolved, c)
+		if i == len(components)-1 {
+			break
+		}
+
+		through := filepath.Join(resolved...)
+		info, err := x.root.Lstat(through)
+		switch {
+		case errors.Is(err, fs.ErrNotExist):
+			return fmt.Errorf("symlink %q goes through %q, which must be extracted before it", name, through)
+		case err != nil:
+			return fmt.Errorf("failed to stat %q: %w", through, err)
+		case info.Mode()&fs.ModeSymlink != 0:
+			return fmt.Errorf("symlink %q goes through symlink %q", name, through)
+		case !info.IsDir():
+			return fmt.Errorf("symlink %q goes through file %q", name, through)
+		}
+	}
+
+	// Parents were checked not to be symlinks, so dir joined with name is within the bundle.
+	if err := os.Symlink(filepath.FromSlash(target), filepath.Join(x.dir, name)); err != nil {
+		return fmt.Errorf("failed to create symlink %q: %w", name, err)
+	}
+
+	return nil
+}
+
+func (x *extractor) writeFile(name string, e entry) error {
+	// Only the owner may use the extracted files, executables stay executable.
+	perm := 0o600 | e.mode.Perm()&0o100
+	f, err := x.root.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_EXCL, perm)
+	if err != nil {
+		return fmt.Errorf("failed to create file %q: %w", name, err)
+	}
+	defer f.Close()
+
+	rc, err := e.open()
+	if err != nil {
+		return fmt.Errorf("failed to open entry %q: %w", e.name, err)
+	}
+	defer rc.Close()
+
+	// One byte past what is left is enough to know the bundle is too large.
+	n, err := io.Copy(f, io.LimitReader(rc, x.limits.MaxSize-x.size+1))
+	x.size += n
+	if err != nil {
+		return fmt.Errorf("failed to extract %q: %w", e.name, err)
+	}
+	if x.size > x.limits.MaxSize {
+		return fmt.Errorf("bundle is larger than %d bytes once extracted", x.limits.MaxSize)
+	}
+
+	return nil
+}
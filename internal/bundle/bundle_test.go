@@ -0,0 +1,245 @@
+package bundle
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var limits = Limits{MaxFiles: 100, MaxSize: 1 << 20}
+
+type testEntry struct {
+	name     string
+	body     string
+	mode     int64
+	typeflag byte
+	linkname string
+}
+
+func tarGz(t *testing.T, entries ...testEntry) *os.File {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for _, e := range entries {
+		typeflag := e.typeflag
+		if typeflag == 0 {
+			typeflag = tar.TypeReg
+		}
+		mode := e.mode
+		if mode == 0 {
+			mode = 0o644
+		}
+
+		err := tw.WriteHeader(&tar.Header{
+			Name:     e.name,
+			Mode:     mode,
+			Size:     int64(len(e.body)),
+			Typeflag: typeflag,
+			Linkname: e.linkname,
+		})
+		assert.NoError(t, err)
+		_, err = tw.Write([]byte(e.body))
+		assert.NoError(t, err)
+	}
+	assert.NoError(t, tw.Close())
+	assert.NoError(t, gz.Close())
+
+	return archiveFile(t, buf.Bytes())
+}
+
+func zipFile(t *testing.T, entries ...testEntry) *os.File {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, e := range entries {
+		header := &zip.FileHeader{Name: e.name}
+		body := e.body
+		switch e.typeflag {
+		case tar.TypeDir:
+			header.SetMode(fs.ModeDir | 0o755)
+		case tar.TypeSymlink:
+			header.SetMode(fs.ModeSymlink | 0o777)
+			body = e.linkname
+		default:
+			header.SetMode(0o644)
+		}
+
+		w, err := zw.CreateHeader(header)
+		assert.NoError(t, err)
+		_, err = w.Write([]byte(body))
+		assert.NoError(t, err)
+	}
+	assert.NoError(t, zw.Close())
+
+	return archiveFile(t, buf.Bytes())
+}
+
+func archiveFile(t *testing.T, data []byte) *os.File {
+	f, err := os.CreateTemp(t.TempDir(), "bundle")
+	assert.NoError(t, err)
+	_, err = f.Write(data)
+	assert.NoError(t, err)
+	_, err = f.Seek(0, 0)
+	assert.NoError(t, err)
+	t.Cleanup(func() { _ = f.Close() })
+
+	return f
+}
+
+func Test_Extract(t *testing.T) {
+	t.Run("should extract files, directories and symlinks from a tar.gz bundle", func(t *testing.T) {
+		archive := tarGz(t,
+			testEntry{name: "api", body: "binary", mode: 0o755},
+			testEntry{name: "web/", typeflag: tar.TypeDir},
+			testEntry{name: "web/index.html", body: "<html></html>"},
+			testEntry{name: "migrations/001.sql", body: "create table t;"},
+			testEntry{name: "index.html", typeflag: tar.TypeSymlink, linkname: "web/index.html"},
+			testEntry{name: "migrations/web", typeflag: tar.TypeSymlink, linkname: "../web/"},
+		)
+		dir := t.TempDir()
+
+		err := Extract(archive, FormatTarGz, dir, limits)
+		assert.NoError(t, err)
+
+		info, err := os.Stat(filepath.Join(dir, "api"))
+		assert.NoError(t, err)
+		assert.Equal(t, fs.FileMode(0o700), info.Mode().Perm(), "should keep the executable bit for the owner only")
+
+		contents, err := os.ReadFile(filepath.Join(dir, "index.html"))
+		assert.NoError(t, err)
+		assert.Equal(t, "<html></html>", string(contents))
+
+		contents, err = os.ReadFile(filepath.Join(dir, "migrations", "001.sql"))
+		assert.NoError(t, err)
+		assert.Equal(t, "create table t;", string(contents))
+
+		contents, err = os.ReadFile(filepath.Join(dir, "migrations", "web", "index.html"))
+		assert.NoError(t, err)
+		assert.Equal(t, "<html></html>", string(contents))
+	})
+
+	t.Run("should extract zip bundles", func(t *testing.T) {
+		var buf bytes.Buffer
+		zw := zip.NewWriter(&buf)
+		header := &zip.FileHeader{Name: "api"}
+		header.SetMode(0o755)
+		w, err := zw.CreateHeader(header)
+		assert.NoError(t, err)
+		_, _ = w.Write([]byte("binary"))
+		w, err = zw.Create("config/app.toml.tmpl")
+		assert.NoError(t, err)
+		_, _ = w.Write([]byte("port = {{.Port}}"))
+		assert.NoError(t, zw.Close())
+		dir := t.TempDir()
+
+		err = Extract(archiveFile(t, buf.Bytes()), FormatZip, dir, limits)
+		assert.NoError(t, err)
+
+		contents, err := os.ReadFile(filepath.Join(dir, "config", "app.toml.tmpl"))
+		assert.NoError(t, err)
+		assert.Equal(t, "port = {{.Port}}", string(contents))
+	})
+
+	for _, tc := range []struct {
+		should  string
+		entries []testEntry
+		err     string
+	}{
+		{
+			should:  "should refuse entries outside of the bundle",
+			entries: []testEntry{{name: "../../.bashrc", body: "evil"}},
+			err:     "is outside of the bundle",
+		},
+		{
+			should:  "should refuse absolute entries",
+			entries: []testEntry{{name: "/etc/cron.d/evil", body: "evil"}},
+			err:     "is outside of the bundle",
+		},
+		{
+			should:  "should refuse symlinks pointing outside of the bundle",
+			entries: []testEntry{{name: "web/escape", typeflag: tar.TypeSymlink, linkname: "../../etc"}},
+			err:     "points outside of the bundle",
+		},
+		{
+			should:  "should refuse absolute symlinks",
+			entries: []testEntry{{name: "passwd", typeflag: tar.TypeSymlink, linkname: "/etc/passwd"}},
+			err:     "has invalid target",
+		},
+		{
+			should: "should refuse entries nested under symlinks",
+			entries: []testEntry{
+				{name: "web/", typeflag: tar.TypeDir},
+				{name: "link", typeflag: tar.TypeSymlink, linkname: "web"},
+				{name: "link/file", body: "evil"},
+			},
+			err: "is nested under symlink",
+		},
+		{
+			should:  "should refuse hard links",
+			entries: []testEntry{{name: "passwd", typeflag: tar.TypeLink, linkname: "/etc/passwd"}},
+			err:     "has unsupported type",
+		},
+		{
+			should:  "should refuse duplicate entries",
+			entries: []testEntry{{name: "api", body: "binary"}, {name: "api", body: "other binary"}},
+			err:     "failed to create file",
+		},
+	} {
+		t.Run(tc.should, func(t *testing.T) {
+			dir := t.TempDir()
+
+			err := Extract(tarGz(t, tc.entries...), FormatTarGz, dir, limits)
+			assert.ErrorContains(t, err, tc.err)
+		})
+	}
+
+	// Each target stays within the bundle on its own, a/t only escapes through a/s.
+	chained := []testEntry{
+		{name: "a/", typeflag: tar.TypeDir},
+		{name: "a/s", typeflag: tar.TypeSymlink, linkname: ".."},
+		{name: "a/t", typeflag: tar.TypeSymlink, linkname: "s/../.."},
+	}
+	for format, archive := range map[string]func(*testing.T, ...testEntry) *os.File{FormatTarGz: tarGz, FormatZip: zipFile} {
+		t.Run("should refuse symlinks going through symlinks in a "+format+" bundle", func(t *testing.T) {
+			err := Extract(archive(t, chained...), format, t.TempDir(), limits)
+			assert.ErrorContains(t, err, `symlink "a/t" goes through symlink "a/s"`)
+		})
+	}
+
+	t.Run("should refuse symlinks going through directories yet to be extracted", func(t *testing.T) {
+		archive := tarGz(t,
+			testEntry{name: "t", typeflag: tar.TypeSymlink, linkname: "s/x"},
+			testEntry{name: "s", typeflag: tar.TypeSymlink, linkname: "."},
+		)
+
+		err := Extract(archive, FormatTarGz, t.TempDir(), limits)
+		assert.ErrorContains(t, err, "which must be extracted before it")
+	})
+
+	t.Run("should stop at the size limit", func(t *testing.T) {
+		archive := tarGz(t,
+			testEntry{name: "a", body: "0123456789"},
+			testEntry{name: "b", body: "0123456789"},
+		)
+
+		err := Extract(archive, FormatTarGz, t.TempDir(), Limits{MaxFiles: 10, MaxSize: 15})
+		assert.ErrorContains(t, err, "bundle is larger than 15 bytes")
+	})
+
+	t.Run("should stop at the entry limit", func(t *testing.T) {
+		archive := tarGz(t,
+			testEntry{name: "a"},
+			testEntry{name: "b"},
+			testEntry{name: "c"},
+		)
+
+		err := Extract(archive, FormatTarGz, t.TempDir(), Limits{MaxFiles: 2, MaxSize: 15})
+		assert.ErrorContains(t, err, "bundle has more than 2 entries")
+	})
+}
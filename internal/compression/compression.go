@@ -0,0 +1,77 @@
+package compression
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/ulikunitz/xz"
+)
+
+const (
+	None = ""
+	Gzip = "gzip"
+	Zstd = "zstd"
+	XZ   = "xz"
+)
+
+// Decompressor wraps a compressed stream into one that reads it decompressed.
+type Decompressor func(r io.Reader) (io.ReadCloser, error)
+
+// Decompressors by compression type.
+var Decompressors = map[string]Decompressor{
+	Gzip: func(r io.Reader) (io.ReadCloser, error) {
+		return gzip.NewReader(r)
+	},
+	Zstd: func(r io.Reader) (io.ReadCloser, error) {
+		// One artifact at a time, a single goroutine decodes as fast as it is downloaded.
+		d, err := zstd.NewReader(r, zstd.WithDecoderConcurrency(1))
+		if err != nil {
+			return nil, err
+		}
+
+		return d.IOReadCloser(), nil
+	},
+	XZ: func(r io.Reader) (io.ReadCloser, error) {
+		xr, err := xz.NewReader(r)
+		if err != nil {
+			return nil, err
+		}
+
+		return io.NopCloser(xr), nil
+	},
+}
+
+// Extensions of the files compressed with each compression type.
+var Extensions = map[string]string{
+	Gzip: ".gz",
+	Zstd: ".zst",
+	XZ:   ".xz",
+}
+
+// Supported tells whether streams compressed with the given compression type can be read.
+func Supported(compression string) bool {
+	_, ok := Decompressors[compression]
+
+	return compression == None || ok
+}
+
+// NewReader reads r decompressed, r is read as is when compression is None.
+func NewReader(compression string, r io.Reader) (io.ReadCloser, error) {
+	if compression == None {
+		return io.NopCloser(r), nil
+	}
+
+	decompressor, ok := Decompressors[compression]
+	if !ok {
+		return nil, fmt.Errorf("unsupported compression `%s`", compression)
+	}
+
+	rc, err := decompressor(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s stream: %w", compression, err)
+	}
+
+	return rc, nil
+}
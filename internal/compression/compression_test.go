@@ -0,0 +1,75 @@
+package compression
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_NewReader(t *testing.T) {
+	t.Run("should read gzip streams decompressed", func(t *testing.T) {
+		var buf bytes.Buffer
+		zw := gzip.NewWriter(&buf)
+		_, _ = zw.Write([]byte("binary"))
+		_ = zw.Close()
+
+		r, err := NewReader(Gzip, &buf)
+		assert.NoError(t, err)
+		got, err := io.ReadAll(r)
+		assert.NoError(t, err)
+		assert.Equal(t, "binary", string(got))
+	})
+
+	t.Run("should read uncompressed streams as is", func(t *testing.T) {
+		r, err := NewReader(None, bytes.NewBufferString("binary"))
+		assert.NoError(t, err)
+		got, err := io.ReadAll(r)
+		assert.NoError(t, err)
+		assert.Equal(t, "binary", string(got))
+	})
+
+	t.Run("should fail on corrupt streams", func(t *testing.T) {
+		_, err := NewReader(Gzip, bytes.NewBufferString("not gzip"))
+		assert.ErrorContains(t, err, "failed to read gzip stream")
+	})
+
+	// Compressed with the gzip, zstd and xz command line tools, like releases are.
+	binary, err := os.ReadFile(filepath.Join("testdata", "binary"))
+	assert.NoError(t, err)
+	for _, compression := range []string{Gzip, Zstd, XZ} {
+		t.Run("should read "+compression+" files decompressed", func(t *testing.T) {
+			assert.True(t, Supported(compression))
+
+			f, err := os.Open(filepath.Join("testdata", "binary"+Extensions[compression]))
+			assert.NoError(t, err)
+			defer f.Close()
+
+			r, err := NewReader(compression, f)
+			assert.NoError(t, err)
+			defer r.Close()
+			got, err := io.ReadAll(r)
+			assert.NoError(t, err)
+			assert.Equal(t, binary, got)
+		})
+
+		t.Run("should fail on corrupt "+compression+" streams", func(t *testing.T) {
+			r, err := NewReader(compression, bytes.NewBufferString("not "+compression))
+			if err == nil {
+				_, err = io.ReadAll(r)
+			}
+			assert.Error(t, err)
+		})
+	}
+
+	t.Run("should fail on compressions without a decompressor", func(t *testing.T) {
+		assert.False(t, Supported("brotli"))
+
+		_, err := NewReader("brotli", bytes.NewBufferString("binary"))
+		assert.ErrorContains(t, err, "unsupported compression `brotli`")
+	})
+}
@@ -0,0 +1,265 @@
+// Package delta creates and applies binary patches between two versions of a file.
+//
+// Patches follow the bsdiff layout: a list of controls, each adding a run of diff bytes to the old
+// file, then inserting a run of extra bytes, then seeking in the old file. Diff bytes are mostly
+// zeros for code that only moved around, so the three sections compress well.
+package delta
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+const (
+	magic      = "SUDELTA1"
+	headerSize = len(magic) + 3*8
+	controlLen = 3 * 8
+
+	// Length of the blocks used to find matches between the old and new file
+	blockSize = 32
+	// Only every indexStep-th block of the old file is indexed, to keep the index small
+	indexStep = 8
+	// Bytes a match must gain over the current alignment to be worth a new control
+	minGain  = 8
+	hashBase = 0x100000001b3
+)
+
+// Diff creates a patch that turns old into new.
+func Diff(old, new []byte) ([]byte, error) {
+	var ctrl, diff, extra bytes.Buffer
+
+	// emit covers new[newStart:newEnd], adding diff bytes against old[oldStart:] as far as they are worth
+	// it, then extra bytes, and seeks to nextOld in the old file. The tail of the region that is worth
+	// diffing against old[:nextOld] instead is left out, returns its length.
+	emit := func(newStart, oldStart, newEnd, nextOld int) int {
+		forward, best, eq := 0, 0, 0
+		for k := 0; newStart+k < newEnd && oldStart+k < len(old); k++ {
+			if new[newStart+k] == old[oldStart+k] {
+				eq++
+			}
+			if score := 2*eq - (k + 1); score > best {
+				best, forward = score, k+1
+			}
+		}
+
+		backward, best, eq := 0, 0, 0
+		for k := 1; newEnd-k >= newStart+forward && nextOld-k >= 0; k++ {
+			if new[newEnd-k] == old[nextOld-k] {
+				eq++
+			}
+			if score := 2*eq - k; score > best {
+				best, backward = score, k
+			}
+		}
+
+		for k := range forward {
+			diff.WriteByte(new[newStart+k] - old[oldStart+k])
+		}
+		extra.Write(new[newStart+forward : newEnd-backward])
+
+		var c [controlLen]byte
+		binary.BigEndian.PutUint64(c[0:], uint64(forward))
+		binary.BigEndian.PutUint64(c[8:], uint64(newEnd-backward-newStart-forward))
+		binary.BigEndian.PutUint64(c[16:], uint64(int64(nextOld-backward-oldStart-forward)))
+		ctrl.Write(c[:])
+
+		return backward
+	}
+
+	index := make(map[uint64]int, len(old)/indexStep+1)
+	for o := 0; o+blockSize <= len(old); o += indexStep {
+		h := hash(old[o : o+blockSize])
+		if _, ok := index[h]; !ok {
+			index[h] = o
+		}
+	}
+
+	// Out-shifting a byte from the rolling hash takes off its weight, hashBase^blockSize.
+	outWeight := uint64(1)
+	for range blockSize {
+		outWeight *= hashBase
+	}
+
+	lastNew, lastOld, matchEnd := 0, 0, 0
+	var h uint64
+	if len(new) >= blockSize {
+		h = hash(new[:blockSize])
+	}
+	for i := 0; i+blockSize <= len(new); {
+		o, ok := index[h]
+		if !ok || !bytes.Equal(old[o:o+blockSize], new[i:i+blockSize]) {
+			if i+blockSize < len(new) {
+				h = h*hashBase + uint64(new[i+blockSize]) - uint64(new[i])*outWeight
+			}
+			i++
+			continue
+		}
+
+		end, oldEnd := i+blockSize, o+blockSize
+		for end < len(new) && oldEnd < len(old) && new[end] == old[oldEnd] {
+			end++
+			oldEnd++
+		}
+
+		// Common runs of bytes, e.g. padding, match all over the old file. Keep diffing at the current
+		// alignment unless the match does clearly better, like bsdiff does.
+		if aligned := i + lastOld - lastNew; o != aligned && aligned >= 0 && aligned+end-i <= len(old) {
+			eq := 0
+			for k := i; k < end; k++ {
+				if new[k] == old[aligned+k-i] {
+					eq++
+				}
+			}
+			if end-i <= eq+minGain {
+				i = end
+				if i+blockSize <= len(new) {
+					h = hash(new[i : i+blockSize])
+				}
+				continue
+			}
+		}
+
+		start, oldStart := i, o
+		for start > matchEnd && oldStart > 0 && new[start-1] == old[oldStart-1] {
+			start--
+			oldStart--
+		}
+
+		backward := emit(lastNew, lastOld, start, oldStart)
+		lastNew, lastOld, matchEnd = start-backward, oldStart-backward, end
+
+		i = end
+		if i+blockSize <= len(new) {
+			h = hash(new[i : i+blockSize])
+		}
+	}
+	emit(lastNew, lastOld, len(new), 0)
+
+	var patch bytes.Buffer
+	patch.WriteString(magic)
+	sections := make([][]byte, 0, 3)
+	for _, section := range []*bytes.Buffer{&ctrl, &diff, &extra} {
+		compressed, err := compress(section.Bytes())
+		if err != nil {
+			return nil, err
+		}
+		sections = append(sections, compressed)
+	}
+
+	var header [3 * 8]byte
+	binary.BigEndian.PutUint64(header[0:], uint64(len(sections[0])))
+	binary.BigEndian.PutUint64(header[8:], uint64(len(sections[1])))
+	binary.BigEndian.PutUint64(header[16:], uint64(len(new)))
+	patch.Write(header[:])
+	for _, section := range sections {
+		patch.Write(section)
+	}
+
+	return patch.Bytes(), nil
+}
+
+// Apply writes the result of applying patch to old into w.
+// The result is only as trustworthy as the patch and old file, verify its digest before using it.
+func Apply(old io.ReaderAt, patch []byte, w io.Writer) error {
+	if len(patch) < headerSize || string(patch[:len(magic)]) != magic {
+		return errors.New("not a delta patch")
+	}
+
+	ctrlLen := binary.BigEndian.Uint64(patch[len(magic):])
+	diffLen := binary.BigEndian.Uint64(patch[len(magic)+8:])
+	newSize := int64(binary.BigEndian.Uint64(patch[len(magic)+16:]))
+	body := patch[headerSize:]
+	if ctrlLen > uint64(len(body)) || diffLen > uint64(len(body))-ctrlLen || newSize < 0 {
+		return errors.New("corrupt delta patch header")
+	}
+
+	ctrl, err := gzip.NewReader(bytes.NewReader(body[:ctrlLen]))
+	if err != nil {
+		return fmt.Errorf("failed to read patch controls: %w", err)
+	}
+	diff, err := gzip.NewReader(bytes.NewReader(body[ctrlLen : ctrlLen+diffLen]))
+	if err != nil {
+		return fmt.Errorf("failed to read patch diff: %w", err)
+	}
+	extra, err := gzip.NewReader(bytes.NewReader(body[ctrlLen+diffLen:]))
+	if err != nil {
+		return fmt.Errorf("failed to read patch extra: %w", err)
+	}
+
+	diffBuf := make([]byte, 32*1024)
+	oldBuf := make([]byte, len(diffBuf))
+
+	var oldPos, written int64
+	for written < newSize {
+		var c [controlLen]byte
+		if _, err := io.ReadFull(ctrl, c[:]); err != nil {
+			return fmt.Errorf("failed to read patch control: %w", err)
+		}
+		add := int64(binary.BigEndian.Uint64(c[0:]))
+		insert := int64(binary.BigEndian.Uint64(c[8:]))
+		seek := int64(binary.BigEndian.Uint64(c[16:]))
+		if add < 0 || insert < 0 || add > newSize-written || insert > newSize-written-add {
+			return errors.New("corrupt delta patch control")
+		}
+
+		for add > 0 {
+			n := min(add, int64(len(diffBuf)))
+			if _, err := io.ReadFull(diff, diffBuf[:n]); err != nil {
+				return fmt.Errorf("failed to read patch diff: %w", err)
+			}
+			if oldPos < 0 {
+				return errors.New("delta patch reads before the start of the old file")
+			}
+			if _, err := old.ReadAt(oldBuf[:n], oldPos); err != nil {
+				return fmt.Errorf("failed to read old file at %d: %w", oldPos, err)
+			}
+			for k := range n {
+				oldBuf[k] += diffBuf[k]
+			}
+			if _, err := w.Write(oldBuf[:n]); err != nil {
+				return fmt.Errorf("failed to write patched file: %w", err)
+			}
+
+			oldPos += n
+			written += n
+			add -= n
+		}
+
+		if _, err := io.CopyN(w, extra, insert); err != nil {
+			return fmt.Errorf("failed to copy patch extra: %w", err)
+		}
+		written += insert
+		oldPos += seek
+	}
+
+	return nil
+}
+
+func hash(block []byte) uint64 {
+	var h uint64
+	for _, b := range block {
+		h = h*hashBase + uint64(b)
+	}
+
+	return h
+}
+
+func compress(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	zw, err := gzip.NewWriterLevel(&buf, gzip.BestCompression)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create gzip writer: %w", err)
+	}
+	if _, err := zw.Write(data); err != nil {
+		return nil, fmt.Errorf("failed to compress patch: %w", err)
+	}
+	if err := zw.Close(); err != nil {
+		return nil, fmt.Errorf("failed to compress patch: %w", err)
+	}
+
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,85 @@
+package delta
+
+import (
+	"bytes"
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func apply(t *testing.T, old, patch []byte) []byte {
+	var out bytes.Buffer
+	err := Apply(bytes.NewReader(old), patch, &out)
+	assert.NoError(t, err)
+
+	return out.Bytes()
+}
+
+func Test_Diff(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	old := make([]byte, 256*1024)
+	rng.Read(old)
+
+	t.Run("should create a small patch between close versions", func(t *testing.T) {
+		// Something inserted, something removed, and a few bytes changed, like a rebuilt binary.
+		new := append([]byte{}, old[:1000]...)
+		new = append(new, []byte("a brand new function")...)
+		new = append(new, old[1000:100_000]...)
+		new = append(new, old[120_000:]...)
+		for i := 5000; i < len(new); i += 4096 {
+			new[i]++
+		}
+
+		patch, err := Diff(old, new)
+		assert.NoError(t, err)
+		assert.Less(t, len(patch), len(new)/10)
+		assert.Equal(t, new, apply(t, old, patch))
+	})
+
+	t.Run("should handle unrelated files", func(t *testing.T) {
+		new := make([]byte, 10_000)
+		rng.Read(new)
+
+		patch, err := Diff(old, new)
+		assert.NoError(t, err)
+		assert.Equal(t, new, apply(t, old, patch))
+	})
+
+	t.Run("should handle empty files", func(t *testing.T) {
+		patch, err := Diff(nil, []byte("hello"))
+		assert.NoError(t, err)
+		assert.Equal(t, []byte("hello"), apply(t, nil, patch))
+
+		patch, err = Diff(old, nil)
+		assert.NoError(t, err)
+		assert.Empty(t, apply(t, old, patch))
+	})
+}
+
+func Test_Apply(t *testing.T) {
+	t.Run("should reject files that are not patches", func(t *testing.T) {
+		err := Apply(bytes.NewReader(nil), []byte("definitely not a patch, but long enough"), &bytes.Buffer{})
+		assert.ErrorContains(t, err, "not a delta patch")
+	})
+
+	t.Run("should fail when the old file is shorter than the patch expects", func(t *testing.T) {
+		old := bytes.Repeat([]byte("0123456789abcdef"), 1024)
+		new := append(append([]byte{}, old...), 'x')
+
+		patch, err := Diff(old, new)
+		assert.NoError(t, err)
+
+		err = Apply(bytes.NewReader(old[:len(old)/2]), patch, &bytes.Buffer{})
+		assert.ErrorContains(t, err, "failed to read old file")
+	})
+
+	t.Run("should fail on truncated patches", func(t *testing.T) {
+		old := bytes.Repeat([]byte("0123456789abcdef"), 1024)
+		patch, err := Diff(old, append([]byte("prefix"), old...))
+		assert.NoError(t, err)
+
+		err = Apply(bytes.NewReader(old), patch[:len(patch)/2], &bytes.Buffer{})
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,119 @@
+package downloader
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/danilevy1212/self-updater/internal/compression"
+)
+
+type ctxKeyCompression struct{}
+
+// WithCompression makes downloads with ctx of files compressed with the given compression type be
+// decompressed as they are written, see File.CompressedSHA256. compression.None is ignored.
+func WithCompression(ctx context.Context, c string) context.Context {
+	if c == compression.None {
+		return ctx
+	}
+
+	return context.WithValue(ctx, ctxKeyCompression{}, c)
+}
+
+func compressionOf(ctx context.Context) string {
+	c, _ := ctx.Value(ctxKeyCompression{}).(string)
+
+	return c
+}
+
+// CopyToTemporaryFile copies r to a temporary file like a download, e.g. an artifact on a mounted volume.
+func CopyToTemporaryFile(ctx context.Context, r io.Reader, pattern string) (*File, error) {
+	return writeTemporaryFile(ctx, "", pattern, func(w io.Writer) error {
+		if _, err := io.Copy(w, r); err != nil {
+			return fmt.Errorf("error writing to temp file: %w", err)
+		}
+
+		return nil
+	})
+}
+
+// writeTemporaryFile creates a temporary file in dir with what write writes, hashing it on the way
+// and decompressing it when ctx has a compression.
+func writeTemporaryFile(ctx context.Context, dir, pattern string, write func(io.Writer) error) (*File, error) {
+	result, err := os.CreateTemp(dir, pattern)
+	if err != nil {
+		return nil, fmt.Errorf("error creating temp file: %w", err)
+	}
+
+	// Hashing while writing spares reading the file again to verify it.
+	hash := sha256.New()
+	var compressedSum []byte
+	if c := compressionOf(ctx); c != compression.None {
+		compressedHash := sha256.New()
+		err = decompressTo(c, io.MultiWriter(result, hash), func(w io.Writer) error {
+			return write(io.MultiWriter(w, compressedHash))
+		})
+		compressedSum = compressedHash.Sum(nil)
+	} else {
+		err = write(io.MultiWriter(result, hash))
+	}
+	if err != nil {
+		_ = result.Close()
+		_ = os.Remove(result.Name())
+		return nil, err
+	}
+
+	if _, err := result.Seek(0, io.SeekStart); err != nil {
+		_ = result.Close()
+		_ = os.Remove(result.Name())
+		return nil, fmt.Errorf("error seeking to start of temp file: %w", err)
+	}
+
+	return &File{File: result, SHA256: hash.Sum(nil), CompressedSHA256: compressedSum}, nil
+}
+
+// errStopDecompressing tells write that the decompressed stream was read to its end or can't be read.
+var errStopDecompressing = errors.New("stopped decompressing")
+
+// decompressTo writes what write writes, compressed with c, decompressed to dst.
+// Errors of write come first, a download cut short is retried rather than taken for a corrupt stream.
+func decompressTo(c string, dst io.Writer, write func(io.Writer) error) error {
+	pr, pw := io.Pipe()
+	writeErr := make(chan error, 1)
+	go func() {
+		err := write(pw)
+		_ = pw.CloseWithError(err)
+		writeErr <- err
+	}()
+
+	err := decompressFrom(c, dst, pr)
+	_ = pr.CloseWithError(errStopDecompressing)
+
+	if err := <-writeErr; err != nil && !errors.Is(err, errStopDecompressing) {
+		return err
+	}
+
+	return err
+}
+
+func decompressFrom(c string, dst io.Writer, src io.Reader) error {
+	r, err := compression.NewReader(c, src)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	if _, err := io.Copy(dst, r); err != nil {
+		return fmt.Errorf("failed to decompress %s stream: %w", c, err)
+	}
+
+	// Bytes past the end of the stream are part of the download, and of its digest.
+	if _, err := io.Copy(io.Discard, src); err != nil {
+		return fmt.Errorf("failed to read past %s stream: %w", c, err)
+	}
+
+	return nil
+}
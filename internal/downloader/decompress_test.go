@@ -0,0 +1,125 @@
+package downloader
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/danilevy1212/self-updater/internal/compression"
+)
+
+func gzipped(t *testing.T, contents []byte) []byte {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	_, err := zw.Write(contents)
+	assert.NoError(t, err)
+	assert.NoError(t, zw.Close())
+
+	return buf.Bytes()
+}
+
+func Test_WithCompression(t *testing.T) {
+	binary := bytes.Repeat([]byte("0123456789"), 1000)
+	compressed := gzipped(t, binary)
+	compressedSum := sha256.Sum256(compressed)
+	ctx := WithCompression(context.Background(), compression.Gzip)
+
+	t.Run("should decompress downloads as they are written", func(t *testing.T) {
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, _ = w.Write(compressed)
+		}))
+		defer ts.Close()
+
+		f, err := defaultDownloadToTemporaryFile(ctx, ts.URL, "dltest.*")
+		assert.NoError(t, err)
+		assert.Equal(t, compressedSum[:], f.CompressedSHA256)
+		assert.Equal(t, compressedSum[:], f.DownloadSHA256())
+		assert.Equal(t, string(binary), readAndRemove(t, f))
+	})
+
+	for _, c := range []string{compression.Zstd, compression.XZ} {
+		t.Run("should decompress "+c+" downloads", func(t *testing.T) {
+			binary, err := os.ReadFile(filepath.Join("..", "compression", "testdata", "binary"))
+			assert.NoError(t, err)
+			compressed, err := os.ReadFile(filepath.Join("..", "compression", "testdata", "binary"+compression.Extensions[c]))
+			assert.NoError(t, err)
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				_, _ = w.Write(compressed)
+			}))
+			defer ts.Close()
+
+			f, err := defaultDownloadToTemporaryFile(WithCompression(context.Background(), c), ts.URL, "dltest.*")
+			assert.NoError(t, err)
+			compressedSum := sha256.Sum256(compressed)
+			assert.Equal(t, compressedSum[:], f.CompressedSHA256)
+			assert.Equal(t, string(binary), readAndRemove(t, f))
+		})
+	}
+
+	t.Run("should decompress resumed downloads once complete", func(t *testing.T) {
+		etag := `"v1"`
+		var ranges []string
+		ts := flakyServer(t, &compressed, &etag, &ranges)
+		dir := t.TempDir()
+
+		_, err := defaultDownloadResumable(ctx, dir, ts.URL, "dltest.*")
+		assert.Error(t, err, "should fail when the connection drops")
+
+		f, err := defaultDownloadResumable(ctx, dir, ts.URL, "dltest.*")
+		assert.NoError(t, err)
+		assert.Equal(t, compressedSum[:], f.CompressedSHA256)
+		assert.Equal(t, string(binary), readAndRemove(t, f))
+
+		leftovers, _ := filepath.Glob(filepath.Join(dir, "*"))
+		assert.Empty(t, leftovers, "should not keep the compressed download")
+	})
+
+	t.Run("should report downloads cut short as such", func(t *testing.T) {
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Length", strconv.Itoa(len(compressed)))
+			_, _ = w.Write(compressed[:len(compressed)/2])
+			w.(http.Flusher).Flush()
+			panic(http.ErrAbortHandler)
+		}))
+		defer ts.Close()
+
+		_, err := defaultDownloadToTemporaryFile(ctx, ts.URL, "dltest.*")
+		assert.Error(t, err)
+		assert.True(t, IsRetryable(err), "should retry rather than take it for a corrupt stream")
+	})
+
+	t.Run("should fail on corrupt streams", func(t *testing.T) {
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, _ = w.Write([]byte("not gzip"))
+		}))
+		defer ts.Close()
+
+		_, err := defaultDownloadToTemporaryFile(ctx, ts.URL, "dltest.*")
+		assert.ErrorContains(t, err, "failed to read gzip stream")
+		assert.False(t, IsRetryable(err))
+	})
+
+	t.Run("should decompress copies too", func(t *testing.T) {
+		f, err := CopyToTemporaryFile(ctx, bytes.NewReader(compressed), "dltest.*")
+		assert.NoError(t, err)
+		assert.Equal(t, compressedSum[:], f.CompressedSHA256)
+		assert.Equal(t, string(binary), readAndRemove(t, f))
+	})
+
+	t.Run("should leave downloads without a compression as they are", func(t *testing.T) {
+		f, err := CopyToTemporaryFile(WithCompression(context.Background(), compression.None), bytes.NewReader(compressed), "dltest.*")
+		assert.NoError(t, err)
+		assert.Nil(t, f.CompressedSHA256)
+		assert.Equal(t, compressedSum[:], f.DownloadSHA256())
+		assert.Equal(t, string(compressed), readAndRemove(t, f))
+	})
+}
@@ -2,48 +2,118 @@ package downloader
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"os"
+
+	"github.com/danilevy1212/self-updater/internal/httpclient"
 )
 
-type DownloadToTemporaryFileFunc func(ctx context.Context, url, pattern string) (*os.File, error)
+// File is a complete download, with the SHA-256 digest of its contents computed while it was written.
+// SHA256 is nil when the digest is not known, e.g. for files that were not downloaded.
+type File struct {
+	*os.File
+	SHA256 []byte
+	// Digest of the download before it was decompressed, nil when it was not, see WithCompression
+	CompressedSHA256 []byte
+}
+
+// DownloadSHA256 is the digest of the file as it was downloaded, compressed or not.
+func (f *File) DownloadSHA256() []byte {
+	if f.CompressedSHA256 != nil {
+		return f.CompressedSHA256
+	}
+
+	return f.SHA256
+}
+
+type DownloadToTemporaryFileFunc func(ctx context.Context, url, pattern string) (*File, error)
 
 var DownloadToTemporaryFile DownloadToTemporaryFileFunc = defaultDownloadToTemporaryFile
 
-func defaultDownloadToTemporaryFile(ctx context.Context, url, pattern string) (*os.File, error) {
+// For requests that need more than a URL, e.g. signed requests to private buckets
+type DownloadRequestToTemporaryFileFunc func(req *http.Request, pattern string) (*File, error)
+
+var DownloadRequestToTemporaryFile DownloadRequestToTemporaryFileFunc = defaultDownloadRequestToTemporaryFile
+
+func defaultDownloadToTemporaryFile(ctx context.Context, url, pattern string) (*File, error) {
 	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
 	if err != nil {
 		return nil, fmt.Errorf("error building request: %w", err)
 	}
 
-	resp, err := http.DefaultClient.Do(req)
+	return defaultDownloadRequestToTemporaryFile(req, pattern)
+}
+
+func defaultDownloadRequestToTemporaryFile(req *http.Request, pattern string) (*File, error) {
+	url := req.URL.Redacted()
+
+	resp, err := httpclient.FromContext(req.Context()).Do(req)
 	if err != nil {
-		return nil, fmt.Errorf("error making request: %w", err)
+		return nil, &NetworkError{URL: url, Err: err}
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+		return nil, newStatusError(url, resp)
 	}
 
-	result, err := os.CreateTemp("", pattern)
-	if err != nil {
-		return nil, fmt.Errorf("error creating temp file: %w", err)
+	return writeTemporaryFile(req.Context(), "", pattern, func(w io.Writer) error {
+		return copyBody(req.Context(), w, resp, url, expectedSize(req.Context()), 0)
+	})
+}
+
+// bodyReader remembers read errors, to tell them apart from errors writing the body out.
+type bodyReader struct {
+	r   io.Reader
+	err error
+}
+
+func (br *bodyReader) Read(p []byte) (int, error) {
+	n, err := br.r.Read(p)
+	if err != nil && err != io.EOF {
+		br.err = err
+	}
+
+	return n, err
+}
+
+// copyBody writes the response body to dst, expecting exactly size bytes when size is positive.
+// The body continues a download offset bytes in, when resuming.
+func copyBody(ctx context.Context, dst io.Writer, resp *http.Response, url string, size, offset int64) error {
+	if size > 0 && resp.ContentLength > size {
+		return &TooLargeError{URL: url, Limit: size}
+	}
+
+	total := int64(-1)
+	if size > 0 {
+		total = offset + size
+	} else if resp.ContentLength >= 0 {
+		total = offset + resp.ContentLength
 	}
 
-	if _, err := io.Copy(result, resp.Body); err != nil {
-		_ = result.Close()
-		_ = os.Remove(result.Name())
-		return nil, fmt.Errorf("error writing to temp file: %w", err)
+	body := &bodyReader{r: newMeteredReader(ctx, resp.Body, offset, total)}
+	var src io.Reader = body
+	if size > 0 {
+		// One byte past the expected size is enough to know the body is too large.
+		src = io.LimitReader(body, size+1)
 	}
 
-	if _, err := result.Seek(0, io.SeekStart); err != nil {
-		_ = result.Close()
-		_ = os.Remove(result.Name())
-		return nil, fmt.Errorf("error seeking to start of temp file: %w", err)
+	n, err := io.Copy(dst, src)
+	switch {
+	case err != nil && body.err == nil:
+		return fmt.Errorf("error writing to temp file: %w", err)
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		return &TruncatedError{URL: url, Err: err}
+	case err != nil:
+		return &NetworkError{URL: url, Err: err}
+	case size > 0 && n > size:
+		return &TooLargeError{URL: url, Limit: size}
+	case size > 0 && n < size:
+		return &TruncatedError{URL: url, Err: fmt.Errorf("received %d of %d bytes", n, size)}
 	}
 
-	return result, nil
+	return nil
 }
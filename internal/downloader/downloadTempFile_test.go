@@ -2,6 +2,7 @@ package downloader
 
 import (
 	"context"
+	"crypto/sha256"
 	"io"
 	"net/http"
 	"net/http/httptest"
@@ -40,6 +41,9 @@ func Test_defaultDownloadTempFile(t *testing.T) {
 		got, err := io.ReadAll(f)
 		assert.NoError(t, err)
 		assert.Equal(t, payload, string(got), "should read the correct content from the file")
+
+		sum := sha256.Sum256([]byte(payload))
+		assert.Equal(t, sum[:], f.SHA256, "should hash the content while downloading")
 	})
 
 	t.Run("should return error with non 200 status code", func(t *testing.T) {
@@ -55,4 +59,68 @@ func Test_defaultDownloadTempFile(t *testing.T) {
 		assert.Error(t, err, "should return an error for non-200 status code")
 		assert.Nil(t, f, "file should be nil on error")
 	})
+
+	t.Run("should classify client errors as permanent", func(t *testing.T) {
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusForbidden)
+		}))
+		defer ts.Close()
+
+		_, err := defaultDownloadToTemporaryFile(context.Background(), ts.URL, "dltest.*")
+
+		var statusErr *StatusError
+		assert.ErrorAs(t, err, &statusErr)
+		assert.Equal(t, http.StatusForbidden, statusErr.StatusCode)
+		assert.Equal(t, "client", Kind(err))
+		assert.False(t, IsRetryable(err))
+	})
+
+	t.Run("should read Retry-After when rate limited", func(t *testing.T) {
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Retry-After", "2")
+			w.WriteHeader(http.StatusTooManyRequests)
+		}))
+		defer ts.Close()
+
+		_, err := defaultDownloadToTemporaryFile(context.Background(), ts.URL, "dltest.*")
+
+		var statusErr *StatusError
+		assert.ErrorAs(t, err, &statusErr)
+		assert.Equal(t, 2*time.Second, statusErr.RetryAfter)
+		assert.Equal(t, "rate_limited", Kind(err))
+		assert.True(t, IsRetryable(err))
+	})
+
+	t.Run("should classify unreachable hosts as network errors", func(t *testing.T) {
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+		ts.Close()
+
+		_, err := defaultDownloadToTemporaryFile(context.Background(), ts.URL, "dltest.*")
+		assert.Equal(t, "network", Kind(err))
+		assert.True(t, IsRetryable(err))
+	})
+
+	t.Run("should abandon bodies larger than expected", func(t *testing.T) {
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, _ = w.Write([]byte("hello world"))
+		}))
+		defer ts.Close()
+
+		f, err := defaultDownloadToTemporaryFile(WithExpectedSize(context.Background(), 5), ts.URL, "dltest.*")
+		assert.Nil(t, f)
+		assert.Equal(t, "too_large", Kind(err))
+		assert.False(t, IsRetryable(err))
+	})
+
+	t.Run("should report bodies shorter than expected as truncated", func(t *testing.T) {
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, _ = w.Write([]byte("hello"))
+		}))
+		defer ts.Close()
+
+		f, err := defaultDownloadToTemporaryFile(WithExpectedSize(context.Background(), 11), ts.URL, "dltest.*")
+		assert.Nil(t, f)
+		assert.Equal(t, "truncated", Kind(err))
+		assert.True(t, IsRetryable(err))
+	})
 }
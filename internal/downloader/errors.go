@@ -0,0 +1,154 @@
+package downloader
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// NetworkError is returned when the request never got a response, e.g. the host is unreachable.
+type NetworkError struct {
+	URL string
+	Err error
+}
+
+func (e *NetworkError) Error() string {
+	return fmt.Sprintf("network error fetching %s: %v", e.URL, e.Err)
+}
+
+func (e *NetworkError) Unwrap() error {
+	return e.Err
+}
+
+// StatusError is returned when the server answered with an unexpected status code.
+type StatusError struct {
+	URL        string
+	StatusCode int
+	// How long the server asked to wait before trying again, zero when it didn't say
+	RetryAfter time.Duration
+}
+
+func (e *StatusError) Error() string {
+	if e.RetryAfter > 0 {
+		return fmt.Sprintf("unexpected status code: %d fetching %s, retry after %s", e.StatusCode, e.URL, e.RetryAfter)
+	}
+
+	return fmt.Sprintf("unexpected status code: %d fetching %s", e.StatusCode, e.URL)
+}
+
+// Temporary tells if the same request may succeed later, i.e. server errors, timeouts and rate limiting.
+func (e *StatusError) Temporary() bool {
+	return e.StatusCode >= http.StatusInternalServerError ||
+		e.StatusCode == http.StatusTooManyRequests ||
+		e.StatusCode == http.StatusRequestTimeout
+}
+
+// TruncatedError is returned when the body ended before all of it was received.
+type TruncatedError struct {
+	URL string
+	Err error
+}
+
+func (e *TruncatedError) Error() string {
+	return fmt.Sprintf("truncated body fetching %s: %v", e.URL, e.Err)
+}
+
+func (e *TruncatedError) Unwrap() error {
+	return e.Err
+}
+
+// TooLargeError is returned when the body is bigger than expected, the download is abandoned right away.
+type TooLargeError struct {
+	URL   string
+	Limit int64
+}
+
+func (e *TooLargeError) Error() string {
+	return fmt.Sprintf("body fetching %s is larger than the expected %d bytes", e.URL, e.Limit)
+}
+
+// IsRetryable tells if a download that failed with err may succeed when tried again.
+func IsRetryable(err error) bool {
+	var (
+		networkErr   *NetworkError
+		statusErr    *StatusError
+		truncatedErr *TruncatedError
+	)
+
+	switch {
+	case errors.Is(err, context.Canceled):
+		return false
+	case errors.As(err, &networkErr), errors.As(err, &truncatedErr):
+		return true
+	case errors.As(err, &statusErr):
+		return statusErr.Temporary()
+	default:
+		return false
+	}
+}
+
+// newStatusError reads Retry-After, either in seconds or as an HTTP date.
+func newStatusError(url string, resp *http.Response) *StatusError {
+	e := &StatusError{URL: url, StatusCode: resp.StatusCode}
+
+	retryAfter := resp.Header.Get("Retry-After")
+	if retryAfter == "" {
+		return e
+	}
+
+	if seconds, err := strconv.Atoi(retryAfter); err == nil && seconds > 0 {
+		e.RetryAfter = time.Duration(seconds) * time.Second
+	} else if at, err := http.ParseTime(retryAfter); err == nil {
+		e.RetryAfter = max(time.Until(at), 0)
+	}
+
+	return e
+}
+
+type ctxKeyExpectedSize struct{}
+
+// WithExpectedSize makes downloads with ctx fail once the body grows past size bytes,
+// e.g. the size of an artifact in the manifest. Sizes of zero or less are ignored.
+func WithExpectedSize(ctx context.Context, size int64) context.Context {
+	if size <= 0 {
+		return ctx
+	}
+
+	return context.WithValue(ctx, ctxKeyExpectedSize{}, size)
+}
+
+func expectedSize(ctx context.Context) int64 {
+	size, _ := ctx.Value(ctxKeyExpectedSize{}).(int64)
+
+	return size
+}
+
+// Kind names the class of a download error, e.g. for logs and metrics.
+func Kind(err error) string {
+	var (
+		networkErr   *NetworkError
+		statusErr    *StatusError
+		truncatedErr *TruncatedError
+		tooLargeErr  *TooLargeError
+	)
+
+	switch {
+	case errors.As(err, &tooLargeErr):
+		return "too_large"
+	case errors.As(err, &truncatedErr):
+		return "truncated"
+	case errors.As(err, &networkErr):
+		return "network"
+	case errors.As(err, &statusErr) && statusErr.StatusCode == http.StatusTooManyRequests:
+		return "rate_limited"
+	case errors.As(err, &statusErr) && statusErr.StatusCode >= http.StatusInternalServerError:
+		return "server"
+	case errors.As(err, &statusErr):
+		return "client"
+	default:
+		return "other"
+	}
+}
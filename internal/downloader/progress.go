@@ -0,0 +1,130 @@
+package downloader
+
+import (
+	"context"
+	"io"
+	"time"
+)
+
+// Progress of a download in flight. Total is -1 while the size is unknown, ETA is zero then too.
+type Progress struct {
+	Done  int64
+	Total int64
+	// Bytes per second since this attempt started
+	Rate float64
+	ETA  time.Duration
+}
+
+// ProgressFunc is called as a download makes progress, and once more when it ends.
+type ProgressFunc func(Progress)
+
+type ctxKeyProgress struct{}
+
+// WithProgress reports the progress of downloads with ctx to fn.
+func WithProgress(ctx context.Context, fn ProgressFunc) context.Context {
+	return context.WithValue(ctx, ctxKeyProgress{}, fn)
+}
+
+func progressFunc(ctx context.Context) ProgressFunc {
+	fn, _ := ctx.Value(ctxKeyProgress{}).(ProgressFunc)
+
+	return fn
+}
+
+type ctxKeyRateLimit struct{}
+
+// WithRateLimit throttles downloads with ctx to bytesPerSecond, e.g. to spare metered links.
+// Limits of zero or less are ignored.
+func WithRateLimit(ctx context.Context, bytesPerSecond int64) context.Context {
+	if bytesPerSecond <= 0 {
+		return ctx
+	}
+
+	return context.WithValue(ctx, ctxKeyRateLimit{}, bytesPerSecond)
+}
+
+func rateLimit(ctx context.Context) int64 {
+	limit, _ := ctx.Value(ctxKeyRateLimit{}).(int64)
+
+	return limit
+}
+
+// meteredReader throttles reads to a rate limit and reports progress.
+type meteredReader struct {
+	ctx      context.Context
+	r        io.Reader
+	limit    int64
+	progress ProgressFunc
+	// Downloaded by earlier attempts, when resuming
+	offset int64
+	total  int64
+
+	start time.Time
+	read  int64
+}
+
+func newMeteredReader(ctx context.Context, r io.Reader, offset, total int64) io.Reader {
+	limit, progress := rateLimit(ctx), progressFunc(ctx)
+	if limit <= 0 && progress == nil {
+		return r
+	}
+
+	return &meteredReader{
+		ctx:      ctx,
+		r:        r,
+		limit:    limit,
+		progress: progress,
+		offset:   offset,
+		total:    total,
+		start:    time.Now(),
+	}
+}
+
+func (mr *meteredReader) Read(p []byte) (int, error) {
+	// Small reads keep the throttled rate smooth, rather than bursting a whole buffer every few seconds.
+	if chunk := max(mr.limit/4, 512); mr.limit > 0 && int64(len(p)) > chunk {
+		p = p[:chunk]
+	}
+
+	n, err := mr.r.Read(p)
+	mr.read += int64(n)
+
+	if mr.limit > 0 && n > 0 {
+		due := time.Duration(float64(mr.read) / float64(mr.limit) * float64(time.Second))
+		if wait := due - time.Since(mr.start); wait > 0 {
+			select {
+			case <-time.After(wait):
+			case <-mr.ctx.Done():
+				return n, mr.ctx.Err()
+			}
+		}
+	}
+
+	if err == io.EOF && mr.total < 0 {
+		// Now the size is known.
+		mr.total = mr.offset + mr.read
+	}
+
+	if mr.progress != nil {
+		mr.progress(mr.snapshot())
+	}
+
+	return n, err
+}
+
+func (mr *meteredReader) snapshot() Progress {
+	p := Progress{
+		Done:  mr.offset + mr.read,
+		Total: mr.total,
+	}
+
+	if elapsed := time.Since(mr.start).Seconds(); elapsed > 0 {
+		p.Rate = float64(mr.read) / elapsed
+	}
+
+	if p.Total >= p.Done && p.Rate > 0 {
+		p.ETA = time.Duration(float64(p.Total-p.Done) / p.Rate * float64(time.Second))
+	}
+
+	return p
+}
@@ -0,0 +1,65 @@
+package downloader
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_meteredDownloads(t *testing.T) {
+	payload := bytes.Repeat([]byte("x"), 8*1024)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write(payload)
+	}))
+	t.Cleanup(ts.Close)
+
+	t.Run("should report progress until the download completes", func(t *testing.T) {
+		var reports []Progress
+		ctx := WithProgress(context.Background(), func(p Progress) {
+			reports = append(reports, p)
+		})
+
+		f, err := defaultDownloadToTemporaryFile(ctx, ts.URL, "dltest.*")
+		assert.NoError(t, err)
+		defer func() {
+			_ = f.Close()
+			_ = os.Remove(f.Name())
+		}()
+
+		assert.NotEmpty(t, reports)
+		last := reports[len(reports)-1]
+		assert.Equal(t, int64(len(payload)), last.Done)
+		assert.Equal(t, int64(len(payload)), last.Total)
+		assert.Equal(t, time.Duration(0), last.ETA)
+	})
+
+	t.Run("should throttle downloads to the rate limit", func(t *testing.T) {
+		ctx := WithRateLimit(context.Background(), 16*1024)
+
+		start := time.Now()
+		f, err := defaultDownloadToTemporaryFile(ctx, ts.URL, "dltest.*")
+		assert.NoError(t, err)
+		defer func() {
+			_ = f.Close()
+			_ = os.Remove(f.Name())
+		}()
+
+		assert.GreaterOrEqual(t, time.Since(start), 450*time.Millisecond, "8KiB at 16KiB/s should take about half a second")
+	})
+
+	t.Run("should stop waiting out the rate limit once the context is done", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(WithRateLimit(context.Background(), 1024), 100*time.Millisecond)
+		defer cancel()
+
+		f, err := defaultDownloadToTemporaryFile(ctx, ts.URL, "dltest.*")
+		assert.Nil(t, f)
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+		assert.True(t, IsRetryable(err), "should resume on the next attempt")
+	})
+}
@@ -0,0 +1,285 @@
+package downloader
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/danilevy1212/self-updater/internal/compression"
+	"github.com/danilevy1212/self-updater/internal/httpclient"
+)
+
+// For downloads that must survive being cut short, e.g. large binaries over slow links.
+// Partial downloads are kept in dir and resumed by the next call for the same url.
+type DownloadResumableFunc func(ctx context.Context, dir, url, pattern string) (*File, error)
+
+var DownloadResumable DownloadResumableFunc = defaultDownloadResumable
+
+// NewRequestFunc builds the request of each attempt, header holds what resumes the download and must be
+// sent too, e.g. signed along with the rest of the request.
+type NewRequestFunc func(ctx context.Context, header http.Header) (*http.Request, error)
+
+// For resumable downloads of requests that can't be built from their url alone, e.g. signed ones.
+// Partial downloads are kept in dir and resumed by the next call for the same key.
+type DownloadRequestResumableFunc func(ctx context.Context, dir, key string, newRequest NewRequestFunc, pattern string) (*File, error)
+
+var DownloadRequestResumable DownloadRequestResumableFunc = defaultDownloadRequestResumable
+
+// partialMeta tells whether a partial download can be resumed, it is kept next to it.
+// URL is the key of the download, the url unless the request is built otherwise.
+type partialMeta struct {
+	URL          string `json:"url"`
+	ETag         string `json:"etag,omitempty"`
+	LastModified string `json:"lastModified,omitempty"`
+}
+
+// validator is what the server must still match for the partial download to be resumed.
+// Weak ETags can't be used with If-Range, Last-Modified is the fallback.
+func (pm *partialMeta) validator() string {
+	if pm.ETag != "" && !strings.HasPrefix(pm.ETag, "W/") {
+		return pm.ETag
+	}
+
+	return pm.LastModified
+}
+
+type partialDownload struct {
+	path     string
+	metaPath string
+}
+
+func newPartialDownload(dir, key string) partialDownload {
+	sum := sha256.Sum256([]byte(key))
+	name := hex.EncodeToString(sum[:8])
+
+	return partialDownload{
+		path:     filepath.Join(dir, name+".part"),
+		metaPath: filepath.Join(dir, name+".part.json"),
+	}
+}
+
+// resumeFrom returns how much of key was already downloaded, and the validator to resume it with.
+func (pd partialDownload) resumeFrom(key string) (int64, string) {
+	data, err := os.ReadFile(pd.metaPath)
+	if err != nil {
+		return 0, ""
+	}
+
+	var meta partialMeta
+	if err := json.Unmarshal(data, &meta); err != nil || meta.URL != key || meta.validator() == "" {
+		return 0, ""
+	}
+
+	info, err := os.Stat(pd.path)
+	if err != nil {
+		return 0, ""
+	}
+
+	return info.Size(), meta.validator()
+}
+
+func (pd partialDownload) saveMeta(meta partialMeta) error {
+	data, err := json.Marshal(meta)
+	if err != nil {
+		return fmt.Errorf("failed to marshal partial download metadata: %w", err)
+	}
+
+	if err := os.WriteFile(pd.metaPath, data, 0o600); err != nil {
+		return fmt.Errorf("failed to write partial download metadata: %w", err)
+	}
+
+	return nil
+}
+
+func (pd partialDownload) remove() {
+	_ = os.Remove(pd.path)
+	_ = os.Remove(pd.metaPath)
+}
+
+// errRestartDownload is returned when the partial download can't be resumed and must start over.
+var errRestartDownload = errors.New("partial download can't be resumed")
+
+func defaultDownloadResumable(ctx context.Context, dir, url, pattern string) (*File, error) {
+	newRequest := func(ctx context.Context, header http.Header) (*http.Request, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			return nil, fmt.Errorf("error building request: %w", err)
+		}
+		req.Header = header
+
+		return req, nil
+	}
+
+	return defaultDownloadRequestResumable(ctx, dir, url, newRequest, pattern)
+}
+
+func defaultDownloadRequestResumable(ctx context.Context, dir, key string, newRequest NewRequestFunc, pattern string) (*File, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("error creating download directory: %w", err)
+	}
+
+	pd := newPartialDownload(dir, key)
+
+	sum, err := pd.download(ctx, key, newRequest)
+	if errors.Is(err, errRestartDownload) {
+		pd.remove()
+		sum, err = pd.download(ctx, key, newRequest)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// Only a complete download gets a name the caller will see.
+	result, err := os.CreateTemp(dir, pattern)
+	if err != nil {
+		return nil, fmt.Errorf("error creating temp file: %w", err)
+	}
+	_ = result.Close()
+
+	if err := os.Rename(pd.path, result.Name()); err != nil {
+		_ = os.Remove(result.Name())
+		return nil, fmt.Errorf("error moving complete download: %w", err)
+	}
+	_ = os.Remove(pd.metaPath)
+
+	f, err := os.Open(result.Name())
+	if err != nil {
+		return nil, fmt.Errorf("error opening complete download: %w", err)
+	}
+
+	if compressionOf(ctx) == compression.None {
+		return &File{File: f, SHA256: sum}, nil
+	}
+
+	// A download pieced together from several attempts is only decompressed once complete.
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	return writeTemporaryFile(ctx, dir, pattern, func(w io.Writer) error {
+		if _, err := io.Copy(w, f); err != nil {
+			return fmt.Errorf("error reading complete download: %w", err)
+		}
+
+		return nil
+	})
+}
+
+// download fetches key into the partial download, resuming it when the server still has the same file.
+// Returns the digest of the complete download.
+func (pd partialDownload) download(ctx context.Context, key string, newRequest NewRequestFunc) ([]byte, error) {
+	offset, validator := pd.resumeFrom(key)
+	if size := expectedSize(ctx); size > 0 && offset >= size {
+		// Can't be a prefix of the expected body.
+		pd.remove()
+		offset = 0
+	}
+
+	header := http.Header{}
+	if offset > 0 {
+		header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+		header.Set("If-Range", validator)
+	}
+
+	req, err := newRequest(ctx, header)
+	if err != nil {
+		return nil, err
+	}
+	url := req.URL.String()
+
+	resp, err := httpclient.FromContext(ctx).Do(req)
+	if err != nil {
+		return nil, &NetworkError{URL: url, Err: err}
+	}
+	defer resp.Body.Close()
+
+	flags := os.O_CREATE | os.O_RDWR
+	remaining := expectedSize(ctx)
+	resumedAt := int64(0)
+	switch resp.StatusCode {
+	case http.StatusPartialContent:
+		if start, ok := contentRangeStart(resp.Header.Get("Content-Range")); !ok || start != offset {
+			return nil, fmt.Errorf("%w: unexpected content range `%s`", errRestartDownload, resp.Header.Get("Content-Range"))
+		}
+		flags |= os.O_APPEND
+		resumedAt = offset
+		if remaining > 0 {
+			remaining -= offset
+		}
+	case http.StatusOK:
+		// The server ignored the range or the file changed since, start over.
+		flags |= os.O_TRUNC
+
+		err := pd.saveMeta(partialMeta{
+			URL:          key,
+			ETag:         resp.Header.Get("ETag"),
+			LastModified: resp.Header.Get("Last-Modified"),
+		})
+		if err != nil {
+			return nil, err
+		}
+	case http.StatusRequestedRangeNotSatisfiable:
+		return nil, fmt.Errorf("%w: requested range not satisfiable", errRestartDownload)
+	default:
+		return nil, newStatusError(url, resp)
+	}
+
+	part, err := os.OpenFile(pd.path, flags, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("error opening partial download: %w", err)
+	}
+	defer part.Close()
+
+	// The digest covers what earlier attempts downloaded too, reading that is all the hashing costs.
+	hash := sha256.New()
+	if resp.StatusCode == http.StatusPartialContent {
+		if _, err := io.CopyN(hash, part, offset); err != nil {
+			return nil, fmt.Errorf("error hashing partial download: %w", err)
+		}
+	}
+
+	// Whatever made it to disk is kept for the next attempt, unless it turns out to be too large.
+	if err := copyBody(ctx, io.MultiWriter(part, hash), resp, url, remaining, resumedAt); err != nil {
+		var tooLarge *TooLargeError
+		if errors.As(err, &tooLarge) {
+			_ = part.Close()
+			pd.remove()
+		}
+
+		return nil, err
+	}
+
+	if err := part.Close(); err != nil {
+		return nil, fmt.Errorf("error closing partial download: %w", err)
+	}
+
+	return hash.Sum(nil), nil
+}
+
+// contentRangeStart parses the first byte position of a `bytes start-end/size` Content-Range.
+func contentRangeStart(contentRange string) (int64, bool) {
+	rest, ok := strings.CutPrefix(contentRange, "bytes ")
+	if !ok {
+		return 0, false
+	}
+
+	start, _, ok := strings.Cut(rest, "-")
+	if !ok {
+		return 0, false
+	}
+
+	n, err := strconv.ParseInt(start, 10, 64)
+	if err != nil {
+		return 0, false
+	}
+
+	return n, true
+}
@@ -0,0 +1,120 @@
+package downloader
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// flakyServer cuts the first response short, and serves contents with Range support after that.
+func flakyServer(t *testing.T, contents *[]byte, etag *string, ranges *[]string) *httptest.Server {
+	requests := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		*ranges = append(*ranges, r.Header.Get("Range"))
+		w.Header().Set("ETag", *etag)
+
+		if requests == 1 {
+			w.Header().Set("Content-Length", strconv.Itoa(len(*contents)))
+			_, _ = w.Write((*contents)[:len(*contents)/2])
+			w.(http.Flusher).Flush()
+			panic(http.ErrAbortHandler)
+		}
+
+		http.ServeContent(w, r, "artifact", time.Time{}, bytes.NewReader(*contents))
+	}))
+	t.Cleanup(ts.Close)
+
+	return ts
+}
+
+// readAndRemove also checks the digest computed while downloading matches the contents.
+func readAndRemove(t *testing.T, f *File) string {
+	defer func() {
+		_ = f.Close()
+		_ = os.Remove(f.Name())
+	}()
+
+	got, err := io.ReadAll(f)
+	assert.NoError(t, err)
+
+	sum := sha256.Sum256(got)
+	assert.Equal(t, sum[:], f.SHA256)
+
+	return string(got)
+}
+
+func Test_defaultDownloadResumable(t *testing.T) {
+	t.Run("should resume an interrupted download where it stopped", func(t *testing.T) {
+		contents := bytes.Repeat([]byte("0123456789"), 1000)
+		etag := `"v1"`
+		var ranges []string
+		ts := flakyServer(t, &contents, &etag, &ranges)
+		dir := t.TempDir()
+
+		_, err := defaultDownloadResumable(context.Background(), dir, ts.URL, "dltest.*")
+		assert.Error(t, err, "should fail when the connection drops")
+
+		f, err := defaultDownloadResumable(context.Background(), dir, ts.URL, "dltest.*")
+		assert.NoError(t, err)
+		assert.Equal(t, string(contents), readAndRemove(t, f))
+		assert.Equal(t, []string{"", "bytes=5000-"}, ranges)
+
+		leftovers, _ := filepath.Glob(filepath.Join(dir, "*.part*"))
+		assert.Empty(t, leftovers, "should clean up the partial download once complete")
+	})
+
+	t.Run("should start over when the file changed since", func(t *testing.T) {
+		contents := bytes.Repeat([]byte("a"), 1000)
+		etag := `"v1"`
+		var ranges []string
+		ts := flakyServer(t, &contents, &etag, &ranges)
+		dir := t.TempDir()
+
+		_, err := defaultDownloadResumable(context.Background(), dir, ts.URL, "dltest.*")
+		assert.Error(t, err)
+
+		contents = bytes.Repeat([]byte("b"), 800)
+		etag = `"v2"`
+
+		f, err := defaultDownloadResumable(context.Background(), dir, ts.URL, "dltest.*")
+		assert.NoError(t, err)
+		assert.Equal(t, string(contents), readAndRemove(t, f))
+	})
+
+	t.Run("should not expose incomplete downloads", func(t *testing.T) {
+		contents := bytes.Repeat([]byte("a"), 1000)
+		etag := `"v1"`
+		var ranges []string
+		ts := flakyServer(t, &contents, &etag, &ranges)
+		dir := t.TempDir()
+
+		f, err := defaultDownloadResumable(context.Background(), dir, ts.URL, "dltest.*")
+		assert.Error(t, err)
+		assert.Nil(t, f)
+
+		exposed, _ := filepath.Glob(filepath.Join(dir, "dltest.*"))
+		assert.Empty(t, exposed)
+	})
+
+	t.Run("should return error with non 200 status code", func(t *testing.T) {
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusNotFound)
+		}))
+		defer ts.Close()
+
+		f, err := defaultDownloadResumable(context.Background(), t.TempDir(), ts.URL, "dltest.*")
+		assert.Error(t, err)
+		assert.Nil(t, f)
+	})
+}
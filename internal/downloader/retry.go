@@ -0,0 +1,48 @@
+package downloader
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/danilevy1212/self-updater/internal/backoff"
+)
+
+// RetryPolicy retries failed downloads that may succeed later, see IsRetryable.
+type RetryPolicy struct {
+	Retries int
+	Backoff backoff.Exponential
+}
+
+// OnRetryFunc is told about every failed attempt that is about to be retried.
+type OnRetryFunc func(err error, attempt int, delay time.Duration)
+
+// Retry calls attempt until it succeeds, fails with an error that is not retryable, or the policy runs out of retries.
+// The server asking to retry later than the backoff allows is not retried either, the next check will.
+func Retry[T any](ctx context.Context, policy RetryPolicy, onRetry OnRetryFunc, attempt func(context.Context) (T, error)) (T, error) {
+	for i := 0; ; i++ {
+		result, err := attempt(ctx)
+		if err == nil || i >= policy.Retries || !IsRetryable(err) || ctx.Err() != nil {
+			return result, err
+		}
+
+		delay := policy.Backoff.Delay(i)
+		var statusErr *StatusError
+		if errors.As(err, &statusErr) && statusErr.RetryAfter > delay {
+			if policy.Backoff.Max > 0 && statusErr.RetryAfter > policy.Backoff.Max {
+				return result, err
+			}
+			delay = statusErr.RetryAfter
+		}
+
+		if onRetry != nil {
+			onRetry(err, i+1, delay)
+		}
+
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return result, err
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package downloader
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/danilevy1212/self-updater/internal/backoff"
+)
+
+func Test_Retry(t *testing.T) {
+	policy := RetryPolicy{
+		Retries: 3,
+		Backoff: backoff.Exponential{Initial: time.Millisecond, Max: 10 * time.Millisecond},
+	}
+
+	t.Run("should retry transient failures until one succeeds", func(t *testing.T) {
+		attempts := 0
+		var retried []int
+
+		got, err := Retry(context.Background(), policy, func(err error, attempt int, delay time.Duration) {
+			retried = append(retried, attempt)
+		}, func(ctx context.Context) (string, error) {
+			attempts++
+			if attempts < 3 {
+				return "", &StatusError{URL: "https://example.com", StatusCode: http.StatusBadGateway}
+			}
+
+			return "done", nil
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, "done", got)
+		assert.Equal(t, []int{1, 2}, retried)
+	})
+
+	t.Run("should give up once out of retries", func(t *testing.T) {
+		attempts := 0
+
+		_, err := Retry(context.Background(), policy, nil, func(ctx context.Context) (string, error) {
+			attempts++
+			return "", &NetworkError{URL: "https://example.com", Err: errors.New("connection refused")}
+		})
+		assert.Error(t, err)
+		assert.Equal(t, 4, attempts)
+	})
+
+	t.Run("should not retry permanent failures", func(t *testing.T) {
+		attempts := 0
+
+		_, err := Retry(context.Background(), policy, nil, func(ctx context.Context) (string, error) {
+			attempts++
+			return "", &StatusError{URL: "https://example.com", StatusCode: http.StatusNotFound}
+		})
+		assert.Error(t, err)
+		assert.Equal(t, 1, attempts)
+	})
+
+	t.Run("should wait as long as the server asks to", func(t *testing.T) {
+		attempts := 0
+		var delays []time.Duration
+
+		_, err := Retry(context.Background(), policy, func(err error, attempt int, delay time.Duration) {
+			delays = append(delays, delay)
+		}, func(ctx context.Context) (string, error) {
+			attempts++
+			if attempts == 1 {
+				return "", &StatusError{URL: "https://example.com", StatusCode: http.StatusTooManyRequests, RetryAfter: 5 * time.Millisecond}
+			}
+
+			return "done", nil
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, []time.Duration{5 * time.Millisecond}, delays)
+	})
+
+	t.Run("should leave it to the next check when the server asks to wait longer than the backoff allows", func(t *testing.T) {
+		attempts := 0
+
+		_, err := Retry(context.Background(), policy, nil, func(ctx context.Context) (string, error) {
+			attempts++
+			return "", &StatusError{URL: "https://example.com", StatusCode: http.StatusTooManyRequests, RetryAfter: time.Hour}
+		})
+		assert.Error(t, err)
+		assert.Equal(t, 1, attempts)
+	})
+}
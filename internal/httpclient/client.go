@@ -0,0 +1,142 @@
+package httpclient
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+)
+
+type ctxKeyClient struct{}
+
+// WithClient makes requests to release hosts and registries made with ctx go through client.
+// The launcher's health checks stay on http.DefaultClient, a proxy must not get between it and the server.
+func WithClient(ctx context.Context, client *http.Client) context.Context {
+	return context.WithValue(ctx, ctxKeyClient{}, client)
+}
+
+// FromContext returns the client set with WithClient, http.DefaultClient without one.
+func FromContext(ctx context.Context) *http.Client {
+	if client, _ := ctx.Value(ctxKeyClient{}).(*http.Client); client != nil {
+		return client
+	}
+
+	return http.DefaultClient
+}
+
+// Config of the client for networks that don't let the updater out directly, e.g. behind a corporate proxy.
+type Config struct {
+	// Proxy for all requests, HTTPS_PROXY and friends are honored when empty
+	ProxyURL string
+	// PEM bundle of CAs trusted along with the system ones, e.g. a private CA
+	CABundle string
+	// PEM client certificate and key for servers that require mutual TLS
+	ClientCert string
+	ClientKey  string
+	// Either 1.2 or 1.3
+	TLSMinVersion string
+	// How long connecting and waiting for response headers may take. Reading the body is bounded by the caller.
+	Timeout   time.Duration
+	UserAgent string
+}
+
+var tlsVersions = map[string]uint16{
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// UserAgent identifies this build to servers, e.g. `self-updater/v1.2.3 (abc123; linux/amd64)`.
+func UserAgent(name, version, commit, goos, goarch string) string {
+	return fmt.Sprintf("%s/%s (%s; %s/%s)", name, version, commit, goos, goarch)
+}
+
+func New(conf Config) (*http.Client, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+
+	if conf.ProxyURL != "" {
+		proxyURL, err := url.Parse(conf.ProxyURL)
+		if err != nil || proxyURL.Scheme == "" || proxyURL.Host == "" {
+			return nil, fmt.Errorf("invalid proxy url `%s`, expected e.g. http://proxy.example.com:3128", conf.ProxyURL)
+		}
+		transport.Proxy = http.ProxyURL(proxyURL)
+	}
+
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+	if conf.TLSMinVersion != "" {
+		version, ok := tlsVersions[conf.TLSMinVersion]
+		if !ok {
+			return nil, fmt.Errorf("invalid tls min version `%s`, expected one of 1.2 or 1.3", conf.TLSMinVersion)
+		}
+		tlsConfig.MinVersion = version
+	}
+
+	if conf.CABundle != "" {
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+
+		bundle, err := os.ReadFile(conf.CABundle)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read ca bundle: %w", err)
+		}
+
+		if !pool.AppendCertsFromPEM(bundle) {
+			return nil, fmt.Errorf("no certificates found in ca bundle `%s`", conf.CABundle)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	if (conf.ClientCert == "") != (conf.ClientKey == "") {
+		return nil, errors.New("client certificate and key must be set together")
+	}
+
+	if conf.ClientCert != "" {
+		cert, err := tls.LoadX509KeyPair(conf.ClientCert, conf.ClientKey)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	transport.TLSClientConfig = tlsConfig
+
+	if conf.Timeout > 0 {
+		transport.DialContext = (&net.Dialer{
+			Timeout:   conf.Timeout,
+			KeepAlive: 30 * time.Second,
+		}).DialContext
+		transport.TLSHandshakeTimeout = conf.Timeout
+		transport.ResponseHeaderTimeout = conf.Timeout
+	}
+
+	var rt http.RoundTripper = transport
+	if conf.UserAgent != "" {
+		rt = &userAgentTransport{next: transport, userAgent: conf.UserAgent}
+	}
+
+	return &http.Client{Transport: rt}, nil
+}
+
+// userAgentTransport sets the User-Agent of requests that don't have one.
+type userAgentTransport struct {
+	next      http.RoundTripper
+	userAgent string
+}
+
+func (t *userAgentTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Header.Get("User-Agent") != "" {
+		return t.next.RoundTrip(req)
+	}
+
+	// A RoundTripper must not modify the request it was given.
+	req = req.Clone(req.Context())
+	req.Header.Set("User-Agent", t.userAgent)
+
+	return t.next.RoundTrip(req)
+}
@@ -0,0 +1,164 @@
+package httpclient
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func writePEM(t *testing.T, name, blockType string, der []byte) string {
+	path := filepath.Join(t.TempDir(), name)
+	err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der}), 0o600)
+	assert.NoError(t, err)
+
+	return path
+}
+
+// newClientCertificate issues a client certificate from a throwaway CA, returning the CA pool
+// to verify it with and the paths of the certificate and key.
+func newClientCertificate(t *testing.T) (*x509.CertPool, string, string) {
+	caKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	caTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, caTemplate, caTemplate, &caKey.PublicKey, caKey)
+	assert.NoError(t, err)
+	ca, _ := x509.ParseCertificate(caDER)
+
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "updater"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca, &key.PublicKey, caKey)
+	assert.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	assert.NoError(t, err)
+
+	pool := x509.NewCertPool()
+	pool.AddCert(ca)
+
+	return pool, writePEM(t, "client.pem", "CERTIFICATE", der), writePEM(t, "client-key.pem", "EC PRIVATE KEY", keyDER)
+}
+
+func Test_New(t *testing.T) {
+	t.Run("should identify the build in the user agent", func(t *testing.T) {
+		var got string
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			got = r.UserAgent()
+		}))
+		defer ts.Close()
+
+		c, err := New(Config{UserAgent: UserAgent("self-updater", "v1.2.3", "abc123", "linux", "amd64")})
+		assert.NoError(t, err)
+
+		resp, err := c.Get(ts.URL)
+		assert.NoError(t, err)
+		_ = resp.Body.Close()
+		assert.Equal(t, "self-updater/v1.2.3 (abc123; linux/amd64)", got)
+	})
+
+	t.Run("should send requests through the proxy", func(t *testing.T) {
+		var proxied string
+		proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			proxied = r.URL.String()
+		}))
+		defer proxy.Close()
+
+		c, err := New(Config{ProxyURL: proxy.URL})
+		assert.NoError(t, err)
+
+		resp, err := c.Get("http://releases.example.com/release.json")
+		assert.NoError(t, err)
+		_ = resp.Body.Close()
+		assert.Equal(t, "http://releases.example.com/release.json", proxied)
+	})
+
+	t.Run("should trust servers signed by the ca bundle", func(t *testing.T) {
+		ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+		defer ts.Close()
+
+		untrusting, err := New(Config{})
+		assert.NoError(t, err)
+		_, err = untrusting.Get(ts.URL)
+		assert.Error(t, err, "should not trust the server without the bundle")
+
+		c, err := New(Config{CABundle: writePEM(t, "ca.pem", "CERTIFICATE", ts.Certificate().Raw)})
+		assert.NoError(t, err)
+
+		resp, err := c.Get(ts.URL)
+		assert.NoError(t, err)
+		_ = resp.Body.Close()
+	})
+
+	t.Run("should present the client certificate", func(t *testing.T) {
+		pool, certPath, keyPath := newClientCertificate(t)
+
+		ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, "updater", r.TLS.PeerCertificates[0].Subject.CommonName)
+		}))
+		ts.TLS = &tls.Config{ClientAuth: tls.RequireAndVerifyClientCert, ClientCAs: pool}
+		ts.StartTLS()
+		defer ts.Close()
+
+		c, err := New(Config{
+			CABundle:   writePEM(t, "ca.pem", "CERTIFICATE", ts.Certificate().Raw),
+			ClientCert: certPath,
+			ClientKey:  keyPath,
+		})
+		assert.NoError(t, err)
+
+		resp, err := c.Get(ts.URL)
+		assert.NoError(t, err)
+		_ = resp.Body.Close()
+	})
+
+	t.Run("should reject invalid configurations", func(t *testing.T) {
+		_, err := New(Config{TLSMinVersion: "1.0"})
+		assert.ErrorContains(t, err, "invalid tls min version")
+
+		_, err = New(Config{ClientCert: "client.pem"})
+		assert.ErrorContains(t, err, "must be set together")
+
+		_, err = New(Config{ProxyURL: "proxy:3128"})
+		assert.ErrorContains(t, err, "invalid proxy url")
+	})
+}
+
+func Test_FromContext(t *testing.T) {
+	t.Run("should return the client set on the context", func(t *testing.T) {
+		c, err := New(Config{UserAgent: "self-updater/v1.2.3"})
+		assert.NoError(t, err)
+
+		assert.Same(t, c, FromContext(WithClient(context.Background(), c)))
+	})
+
+	t.Run("should fall back to the default client", func(t *testing.T) {
+		assert.Same(t, http.DefaultClient, FromContext(context.Background()))
+		assert.Same(t, http.DefaultClient, FromContext(WithClient(context.Background(), nil)))
+	})
+}
@@ -0,0 +1,62 @@
+package identity
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+const installationIDFileName = "installation-id"
+
+// LoadOrCreate returns the identifier of this installation persisted in dir, creating it on first use.
+// Both the launcher and the server call it, whoever comes first creates it.
+func LoadOrCreate(dir string) (string, error) {
+	path := filepath.Join(dir, installationIDFileName)
+
+	id, err := read(path)
+	if err == nil {
+		return id, nil
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		return "", err
+	}
+
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return "", fmt.Errorf("failed to create directory for installation id: %w", err)
+	}
+
+	// O_EXCL so that a concurrent creator doesn't get overwritten, the loser reads the winner's id.
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if errors.Is(err, os.ErrExist) {
+		return read(path)
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to create installation id file: %w", err)
+	}
+	defer f.Close()
+
+	id = uuid.NewString()
+	if _, err := f.WriteString(id + "\n"); err != nil {
+		return "", fmt.Errorf("failed to write installation id: %w", err)
+	}
+
+	return id, nil
+}
+
+func read(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read installation id: %w", err)
+	}
+
+	id := strings.TrimSpace(string(data))
+	if _, err := uuid.Parse(id); err != nil {
+		return "", fmt.Errorf("invalid installation id in `%s`: %w", path, err)
+	}
+
+	return id, nil
+}
@@ -0,0 +1,34 @@
+package identity
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadOrCreate(t *testing.T) {
+	t.Run("should create an id on first use and keep it afterwards", func(t *testing.T) {
+		dir := filepath.Join(t.TempDir(), "nested")
+
+		first, err := LoadOrCreate(dir)
+		assert.NoError(t, err)
+		_, err = uuid.Parse(first)
+		assert.NoError(t, err, "should be a valid uuid")
+
+		second, err := LoadOrCreate(dir)
+		assert.NoError(t, err)
+		assert.Equal(t, first, second, "should return the persisted id")
+	})
+
+	t.Run("should error on a corrupted id file", func(t *testing.T) {
+		dir := t.TempDir()
+		assert.NoError(t, os.WriteFile(filepath.Join(dir, installationIDFileName), []byte("garbage"), 0o600))
+
+		_, err := LoadOrCreate(dir)
+		assert.Error(t, err)
+		assert.ErrorContains(t, err, "invalid installation id")
+	})
+}
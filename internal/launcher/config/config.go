@@ -5,13 +5,47 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"time"
 
 	"github.com/sethvargo/go-envconfig"
 )
 
+type RestartPolicy string
+
+const (
+	RestartAlways    RestartPolicy = "always"
+	RestartOnFailure RestartPolicy = "on-failure"
+	RestartNever     RestartPolicy = "never"
+)
+
+// ShouldRestart tells if a server that exited with exitCode should be started again.
+func (p RestartPolicy) ShouldRestart(exitCode int) bool {
+	switch p {
+	case RestartAlways:
+		return true
+	case RestartOnFailure:
+		return exitCode != 0
+	default:
+		return false
+	}
+}
+
 type Config struct {
 	IsDev            bool   `env:"LAUNCHER_IS_DEV,default=false"`
 	SessionDirectory string `env:"LAUNCHER_SESSION_FOLDER,default=self-updater"`
+	// Durable, unlike the session folder in temporary storage, defaults to `self-updater` in the user config directory
+	StateDirectory        string        `env:"LAUNCHER_STATE_DIRECTORY"`
+	ServerPort            uint          `env:"SERVER_PORT,default=3000"`
+	HealthCheckTimeout    time.Duration `env:"LAUNCHER_HEALTH_CHECK_TIMEOUT,default=30s"`
+	HealthCheckInterval   time.Duration `env:"LAUNCHER_HEALTH_CHECK_INTERVAL,default=1s"`
+	RestartPolicy         RestartPolicy `env:"LAUNCHER_RESTART_POLICY,default=on-failure"`
+	RestartBackoffInitial time.Duration `env:"LAUNCHER_RESTART_BACKOFF_INITIAL,default=1s"`
+	RestartBackoffMax     time.Duration `env:"LAUNCHER_RESTART_BACKOFF_MAX,default=1m"`
+	RestartBackoffJitter  float64       `env:"LAUNCHER_RESTART_BACKOFF_JITTER,default=0.2"`
+	CrashLoopWindow       time.Duration `env:"LAUNCHER_CRASH_LOOP_WINDOW,default=5m"`
+	CrashLoopThreshold    int           `env:"LAUNCHER_CRASH_LOOP_THRESHOLD,default=3"`
+	ShutdownGracePeriod   time.Duration `env:"LAUNCHER_SHUTDOWN_GRACE_PERIOD,default=15s"`
+	SocketHandoff         bool          `env:"LAUNCHER_SOCKET_HANDOFF,default=true"`
 }
 
 type ConfigFunc func(context.Context) (*Config, error)
@@ -28,7 +62,22 @@ func fetchFromEnvironment(ctx context.Context) (*Config, error) {
 		return nil, fmt.Errorf("error loading config: %w", err)
 	}
 
+	switch cfg.RestartPolicy {
+	case RestartAlways, RestartOnFailure, RestartNever:
+	default:
+		return nil, fmt.Errorf("invalid restart policy `%s`, expected one of always, on-failure or never", cfg.RestartPolicy)
+	}
+
 	cfg.SessionDirectory = filepath.Join(os.TempDir(), cfg.SessionDirectory)
 
+	// What the updater must remember across reboots, e.g. its high-water mark, can't live in temporary storage.
+	if cfg.StateDirectory == "" {
+		configDir, err := os.UserConfigDir()
+		if err != nil {
+			return nil, fmt.Errorf("failed to find a state directory, set LAUNCHER_STATE_DIRECTORY: %w", err)
+		}
+		cfg.StateDirectory = filepath.Join(configDir, "self-updater")
+	}
+
 	return &cfg, nil
 }
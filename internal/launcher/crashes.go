@@ -0,0 +1,35 @@
+package launcher
+
+import "time"
+
+// CrashTracker counts crashes within a sliding time window.
+type CrashTracker struct {
+	Window  time.Duration
+	crashes []time.Time
+}
+
+func NewCrashTracker(window time.Duration) *CrashTracker {
+	return &CrashTracker{
+		Window: window,
+	}
+}
+
+// Record registers a crash at the given time, and returns how many crashes happened within the window.
+func (ct *CrashTracker) Record(at time.Time) int {
+	ct.crashes = append(ct.crashes, at)
+
+	cutoff := at.Add(-ct.Window)
+	recent := ct.crashes[:0]
+	for _, c := range ct.crashes {
+		if c.After(cutoff) {
+			recent = append(recent, c)
+		}
+	}
+	ct.crashes = recent
+
+	return len(ct.crashes)
+}
+
+func (ct *CrashTracker) Reset() {
+	ct.crashes = nil
+}
@@ -0,0 +1,40 @@
+package launcher
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_CrashTracker_Record(t *testing.T) {
+	start := time.Date(2025, 3, 27, 12, 0, 0, 0, time.UTC)
+
+	t.Run("should count crashes within the window", func(t *testing.T) {
+		ct := NewCrashTracker(time.Minute)
+
+		assert.Equal(t, 1, ct.Record(start))
+		assert.Equal(t, 2, ct.Record(start.Add(10*time.Second)))
+		assert.Equal(t, 3, ct.Record(start.Add(20*time.Second)))
+	})
+
+	t.Run("should forget crashes that left the window", func(t *testing.T) {
+		ct := NewCrashTracker(time.Minute)
+
+		ct.Record(start)
+		ct.Record(start.Add(10 * time.Second))
+
+		assert.Equal(t, 2, ct.Record(start.Add(65*time.Second)), "first crash should have left the window")
+		assert.Equal(t, 1, ct.Record(start.Add(5*time.Minute)), "all previous crashes should have left the window")
+	})
+
+	t.Run("should start over after reset", func(t *testing.T) {
+		ct := NewCrashTracker(time.Minute)
+
+		ct.Record(start)
+		ct.Record(start.Add(time.Second))
+		ct.Reset()
+
+		assert.Equal(t, 1, ct.Record(start.Add(2*time.Second)))
+	})
+}
@@ -0,0 +1,103 @@
+package launcher
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+)
+
+const (
+	// Inherited file descriptors start right after stdin, stdout and stderr.
+	inheritedFDsStart = 3
+	// Env variable holding the file descriptor the server uses to talk back to the launcher.
+	NotifyFDEnv        = "SELF_UPDATER_NOTIFY_FD"
+	updateReadyMessage = "UPDATE_READY"
+)
+
+// Listen opens the server's listening socket in the launcher, so that it outlives every server process.
+// Each server inherits it systemd-style (LISTEN_FDS), so connections are queued instead of refused while
+// servers are swapped.
+func (l *Launcher) Listen() error {
+	logger := l.Logger.With().
+		Str("handler", "Listen").
+		Logger()
+
+	// Windows can't pass file descriptors to child processes.
+	if !l.Config.SocketHandoff || l.Meta.OS == "windows" {
+		logger.Info().
+			Msg("socket handoff disabled, server will open its own listener")
+
+		return nil
+	}
+
+	ln, err := net.Listen("tcp", fmt.Sprintf(":%d", l.Config.ServerPort))
+	if err != nil {
+		return fmt.Errorf("failed to listen on port %d: %w", l.Config.ServerPort, err)
+	}
+	defer ln.Close()
+
+	// The file is a duplicate of the socket, it stays open after closing the listener.
+	f, err := ln.(*net.TCPListener).File()
+	if err != nil {
+		return fmt.Errorf("failed to get listener file: %w", err)
+	}
+	l.ListenerFile = f
+
+	logger.Info().
+		Str("address", ln.Addr().String()).
+		Msg("listening on behalf of the server")
+
+	return nil
+}
+
+// NotifyUpdateReady tells the launcher the server staged an update and is about to drain,
+// so that the launcher can start the new server right away. Returns false when the server was
+// not started by a launcher that supports it.
+func NotifyUpdateReady() (bool, error) {
+	raw := os.Getenv(NotifyFDEnv)
+	if raw == "" {
+		return false, nil
+	}
+
+	fd, err := strconv.Atoi(raw)
+	if err != nil {
+		return false, fmt.Errorf("invalid %s `%s`: %w", NotifyFDEnv, raw, err)
+	}
+
+	f := os.NewFile(uintptr(fd), "launcher-notify")
+	if f == nil {
+		return false, fmt.Errorf("invalid notify file descriptor %d", fd)
+	}
+	defer f.Close()
+
+	if _, err := fmt.Fprintln(f, updateReadyMessage); err != nil {
+		return false, fmt.Errorf("failed to notify launcher: %w", err)
+	}
+
+	return true, nil
+}
+
+// watchNotifications forwards the messages written by the server into the returned channel.
+// The reader is closed once the server closes its end, i.e. when it exits.
+func watchNotifications(r *os.File) <-chan struct{} {
+	updateReady := make(chan struct{}, 1)
+
+	go func() {
+		defer r.Close()
+
+		scanner := bufio.NewScanner(r)
+		for scanner.Scan() {
+			if strings.TrimSpace(scanner.Text()) == updateReadyMessage {
+				select {
+				case updateReady <- struct{}{}:
+				default:
+				}
+			}
+		}
+	}()
+
+	return updateReady
+}
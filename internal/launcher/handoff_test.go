@@ -0,0 +1,44 @@
+package launcher
+
+import (
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_watchNotifications(t *testing.T) {
+	t.Run("should signal when server reports update ready", func(t *testing.T) {
+		r, w, err := os.Pipe()
+		assert.NoError(t, err)
+
+		updateReady := watchNotifications(r)
+		_, err = fmt.Fprintln(w, updateReadyMessage)
+		assert.NoError(t, err)
+		_ = w.Close()
+
+		select {
+		case <-updateReady:
+		case <-time.After(time.Second):
+			assert.Fail(t, "should have received update ready notification")
+		}
+	})
+
+	t.Run("should ignore unknown messages", func(t *testing.T) {
+		r, w, err := os.Pipe()
+		assert.NoError(t, err)
+
+		updateReady := watchNotifications(r)
+		_, err = fmt.Fprintln(w, "HELLO")
+		assert.NoError(t, err)
+		_ = w.Close()
+
+		select {
+		case <-updateReady:
+			assert.Fail(t, "should not signal update ready for unknown messages")
+		case <-time.After(100 * time.Millisecond):
+		}
+	})
+}
@@ -0,0 +1,117 @@
+package launcher
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// HealthReport mirrors the body served by the server on GET /health.
+type HealthReport struct {
+	Status  string `json:"status"`
+	SHA256  []byte `json:"sha256"`
+	Version string `json:"version"`
+	Commit  string `json:"commit"`
+}
+
+// ExpectedHealth is what a freshly launched server must report before it is trusted.
+// An empty Version skips the version check.
+type ExpectedHealth struct {
+	Digest  []byte
+	Version string
+}
+
+func (l *Launcher) healthCheckURL() string {
+	return fmt.Sprintf("http://127.0.0.1:%d/health", l.Config.ServerPort)
+}
+
+func (l *Launcher) probeHealth(ctx context.Context, expected ExpectedHealth) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, l.healthCheckURL(), nil)
+	if err != nil {
+		return fmt.Errorf("error building request: %w", err)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("error making request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+
+	var report HealthReport
+	if err := json.NewDecoder(resp.Body).Decode(&report); err != nil {
+		return fmt.Errorf("failed to decode health report: %w", err)
+	}
+
+	if report.Status != "OK" {
+		return fmt.Errorf("unexpected health status: %s", report.Status)
+	}
+
+	if !bytes.Equal(report.SHA256, expected.Digest) {
+		return fmt.Errorf(
+			"reported digest %s does not match staged digest %s",
+			hex.EncodeToString(report.SHA256),
+			hex.EncodeToString(expected.Digest),
+		)
+	}
+
+	if expected.Version != "" && report.Version != expected.Version {
+		return fmt.Errorf("reported version %s does not match staged version %s", report.Version, expected.Version)
+	}
+
+	return nil
+}
+
+// WaitForHealthy polls the server health endpoint until it reports the expected
+// binary, the server exits, or the configured health check timeout elapses.
+func (l *Launcher) WaitForHealthy(ctx context.Context, exited <-chan struct{}, expected ExpectedHealth) error {
+	logger := l.Logger.With().
+		Str("handler", "WaitForHealthy").
+		Str("url", l.healthCheckURL()).
+		Logger()
+
+	deadlineCtx, cancel := context.WithTimeout(ctx, l.Config.HealthCheckTimeout)
+	defer cancel()
+
+	ticker := time.NewTicker(l.Config.HealthCheckInterval)
+	defer ticker.Stop()
+
+	lastErr := errors.New("server was never probed")
+	for {
+		select {
+		case <-exited:
+			return fmt.Errorf("server exited before passing health check: %w", lastErr)
+		case <-deadlineCtx.Done():
+			return fmt.Errorf("server did not pass health check within %s: %w", l.Config.HealthCheckTimeout, lastErr)
+		case <-ticker.C:
+		}
+
+		probeCtx, cancelProbe := context.WithTimeout(deadlineCtx, l.Config.HealthCheckInterval)
+		err := l.probeHealth(probeCtx, expected)
+		cancelProbe()
+
+		if err == nil {
+			logger.Info().
+				Msg("server passed health check")
+
+			return nil
+		}
+
+		logger.Debug().
+			Err(err).
+			Msg("server not healthy yet")
+
+		// A probe cut short by the deadline says nothing about the server, keep the previous reason.
+		if deadlineCtx.Err() == nil {
+			lastErr = err
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package launcher
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/danilevy1212/self-updater/internal/launcher/config"
+	"github.com/danilevy1212/self-updater/internal/logger"
+)
+
+func newHealthTestLauncher(t *testing.T, handler http.HandlerFunc) *Launcher {
+	ts := httptest.NewServer(handler)
+	t.Cleanup(ts.Close)
+
+	u, err := url.Parse(ts.URL)
+	assert.NoError(t, err)
+	port, err := strconv.Atoi(u.Port())
+	assert.NoError(t, err)
+
+	return &Launcher{
+		Logger: logger.New(true),
+		Config: &config.Config{
+			ServerPort:          uint(port),
+			HealthCheckTimeout:  200 * time.Millisecond,
+			HealthCheckInterval: 10 * time.Millisecond,
+		},
+	}
+}
+
+func healthHandler(digest []byte, version string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/health" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(gin.H{
+			"status":  "OK",
+			"sha256":  digest,
+			"version": version,
+			"commit":  "abc",
+		})
+	}
+}
+
+func Test_Launcher_WaitForHealthy(t *testing.T) {
+	digest := []byte{0xaa, 0xaa, 0x33, 0x33}
+
+	t.Run("should pass when server reports the expected digest and version", func(t *testing.T) {
+		l := newHealthTestLauncher(t, healthHandler(digest, "v1.2.3"))
+
+		err := l.WaitForHealthy(context.Background(), make(chan struct{}), ExpectedHealth{
+			Digest:  digest,
+			Version: "v1.2.3",
+		})
+		assert.NoError(t, err)
+	})
+
+	t.Run("should fail when server reports a different version", func(t *testing.T) {
+		l := newHealthTestLauncher(t, healthHandler(digest, "v1.2.2"))
+
+		err := l.WaitForHealthy(context.Background(), make(chan struct{}), ExpectedHealth{
+			Digest:  digest,
+			Version: "v1.2.3",
+		})
+		assert.Error(t, err)
+		assert.ErrorContains(t, err, "does not match staged version")
+	})
+
+	t.Run("should fail when server reports a different digest", func(t *testing.T) {
+		l := newHealthTestLauncher(t, healthHandler([]byte{0xbb}, "v1.2.3"))
+
+		err := l.WaitForHealthy(context.Background(), make(chan struct{}), ExpectedHealth{
+			Digest: digest,
+		})
+		assert.Error(t, err)
+		assert.ErrorContains(t, err, "does not match staged digest")
+	})
+
+	t.Run("should fail fast when server exits", func(t *testing.T) {
+		l := newHealthTestLauncher(t, func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		})
+		l.Config.HealthCheckTimeout = time.Minute
+
+		exited := make(chan struct{})
+		close(exited)
+
+		err := l.WaitForHealthy(context.Background(), exited, ExpectedHealth{Digest: digest})
+		assert.Error(t, err)
+		assert.ErrorContains(t, err, "server exited before passing health check")
+	})
+}
@@ -2,12 +2,24 @@ package launcher
 
 import (
 	"context"
+	"fmt"
 	"os"
 	"os/exec"
+	"path/filepath"
 	"strings"
+	"syscall"
 )
 
-func (l *Launcher) LaunchServer(ctx context.Context, serverPath string) (*exec.Cmd, error) {
+// ServerProcess is a running server started by the launcher.
+type ServerProcess struct {
+	Cmd *exec.Cmd
+	// Closed once the process exited, after that Cmd.ProcessState is safe to read.
+	Exited <-chan struct{}
+	// Receives once the server staged an update and started draining. Nil without socket handoff.
+	UpdateReady <-chan struct{}
+}
+
+func (l *Launcher) LaunchServer(ctx context.Context, serverPath string) (*ServerProcess, error) {
 	logger := l.Logger.With().
 		Str("handler", "LaunchServer").
 		Logger()
@@ -17,7 +29,53 @@ func (l *Launcher) LaunchServer(ctx context.Context, serverPath string) (*exec.C
 		"--current-session-dir", l.SessionDirectory,
 	}
 	cmd := exec.CommandContext(ctx, serverPath, launchArgs...)
+	// When the launcher is asked to stop, ask the server to do the same and give it
+	// the grace period to drain connections before it gets killed.
+	cmd.Cancel = func() error {
+		logger.Info().
+			Int("pid", cmd.Process.Pid).
+			Dur("grace_period", l.Config.ShutdownGracePeriod).
+			Msg("forwarding termination signal to server")
+
+		// Windows can't deliver SIGTERM, the only option there is to kill the process.
+		if err := cmd.Process.Signal(syscall.SIGTERM); err != nil {
+			return cmd.Process.Kill()
+		}
+
+		return nil
+	}
+	cmd.WaitDelay = l.Config.ShutdownGracePeriod
 	cmd.Env = os.Environ()
+	if os.Getenv("UPDATER_STATE_DIRECTORY") == "" {
+		// Unlike the session directories, it outlives the launcher and reboots, so the updater keeps its state there.
+		cmd.Env = append(cmd.Env, "UPDATER_STATE_DIRECTORY="+l.Config.StateDirectory)
+	}
+	if os.Getenv("UPDATER_DOWNLOAD_DIRECTORY") == "" {
+		// Partial downloads survive server restarts within the session, e.g. after a crash.
+		cmd.Env = append(cmd.Env, "UPDATER_DOWNLOAD_DIRECTORY="+filepath.Join(l.SessionDirectory, "downloads"))
+	}
+	if len(l.RejectedVersions) > 0 {
+		// Let the updater know which releases already failed here, so it does not stage them again.
+		cmd.Env = append(cmd.Env, "UPDATER_REJECTED_VERSIONS="+strings.Join(l.RejectedVersions, ","))
+	}
+
+	var notifyReader, notifyWriter *os.File
+	if l.ListenerFile != nil {
+		var err error
+		notifyReader, notifyWriter, err = os.Pipe()
+		if err != nil {
+			return nil, fmt.Errorf("failed to create notify pipe: %w", err)
+		}
+		defer notifyWriter.Close()
+
+		// Becomes fd 3 and 4 in the server.
+		cmd.ExtraFiles = []*os.File{l.ListenerFile, notifyWriter}
+		cmd.Env = append(cmd.Env,
+			"LISTEN_FDS=1",
+			"LISTEN_FDNAMES=http",
+			fmt.Sprintf("%s=%d", NotifyFDEnv, inheritedFDsStart+1),
+		)
+	}
 
 	// Parent sees the logs of child
 	cmd.Stdout = os.Stdout
@@ -35,6 +93,10 @@ func (l *Launcher) LaunchServer(ctx context.Context, serverPath string) (*exec.C
 			Str("path", serverPath).
 			Str("args", strings.Join(launchArgs, " ")).
 			Msg("failed to start server process")
+
+		if notifyReader != nil {
+			_ = notifyReader.Close()
+		}
 		return nil, err
 	}
 
@@ -44,5 +106,19 @@ func (l *Launcher) LaunchServer(ctx context.Context, serverPath string) (*exec.C
 		Int("pid", cmd.Process.Pid).
 		Msg("server process started successfully")
 
-	return cmd, nil
+	exited := make(chan struct{})
+	go func() {
+		defer close(exited)
+		_ = cmd.Wait()
+	}()
+
+	sp := &ServerProcess{
+		Cmd:    cmd,
+		Exited: exited,
+	}
+	if notifyReader != nil {
+		sp.UpdateReady = watchNotifications(notifyReader)
+	}
+
+	return sp, nil
 }
@@ -3,11 +3,13 @@ package launcher
 import (
 	"context"
 	"fmt"
+	"os"
 	"path/filepath"
 
 	"github.com/google/uuid"
 	"github.com/rs/zerolog"
 
+	"github.com/danilevy1212/self-updater/internal/identity"
 	"github.com/danilevy1212/self-updater/internal/launcher/config"
 	"github.com/danilevy1212/self-updater/internal/logger"
 	"github.com/danilevy1212/self-updater/internal/models"
@@ -18,6 +20,12 @@ type Launcher struct {
 	Logger           *zerolog.Logger
 	Config           *config.Config
 	SessionDirectory string
+	// Listening socket handed to every server, nil when the server listens on its own
+	ListenerFile *os.File
+	// Versions that failed their health check during this session
+	RejectedVersions []string
+	// Stable identifier of this installation, persisted in the state directory
+	InstallationID string
 }
 
 func New(ctx context.Context, am models.ApplicationMeta) (*Launcher, error) {
@@ -33,10 +41,20 @@ func New(ctx context.Context, am models.ApplicationMeta) (*Launcher, error) {
 
 	sessionDir := filepath.Join(conf.SessionDirectory, uuid.NewString())
 
+	// The updater reads the same file through UPDATER_STATE_DIRECTORY, create it before any server starts.
+	installationID, err := identity.LoadOrCreate(conf.StateDirectory)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load installation id from `%s`: %w", conf.StateDirectory, err)
+	}
+	l = l.With().
+		Str("installation_id", installationID).
+		Logger()
+
 	return &Launcher{
 		Meta:             am,
 		Logger:           &l,
 		Config:           conf,
 		SessionDirectory: sessionDir,
+		InstallationID:   installationID,
 	}, nil
 }
@@ -0,0 +1,43 @@
+package launcher
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/danilevy1212/self-updater/internal/launcher/config"
+	"github.com/danilevy1212/self-updater/internal/models"
+)
+
+func Test_New(t *testing.T) {
+	oldFetcher := config.ConfigFetcher
+	t.Cleanup(func() { config.ConfigFetcher = oldFetcher })
+
+	t.Run("should keep the installation id in the state directory", func(t *testing.T) {
+		stateDir := filepath.Join(t.TempDir(), "state")
+		config.ConfigFetcher = func(context.Context) (*config.Config, error) {
+			return &config.Config{SessionDirectory: t.TempDir(), StateDirectory: stateDir}, nil
+		}
+
+		l, err := New(context.Background(), models.ApplicationMeta{})
+		assert.NoError(t, err)
+		assert.NotEmpty(t, l.InstallationID)
+		assert.FileExists(t, filepath.Join(stateDir, "installation-id"))
+	})
+
+	t.Run("should return an error when the installation id can't be stored", func(t *testing.T) {
+		// A file where the state directory should be
+		stateDir := filepath.Join(t.TempDir(), "state")
+		assert.NoError(t, os.WriteFile(stateDir, nil, 0o600))
+		config.ConfigFetcher = func(context.Context) (*config.Config, error) {
+			return &config.Config{SessionDirectory: t.TempDir(), StateDirectory: stateDir}, nil
+		}
+
+		l, err := New(context.Background(), models.ApplicationMeta{})
+		assert.ErrorContains(t, err, "failed to load installation id from `"+stateDir+"`")
+		assert.Nil(t, l)
+	})
+}
@@ -0,0 +1,69 @@
+package maintenance
+
+import (
+	"fmt"
+	"time"
+)
+
+// Schedule is a set of maintenance windows in a time zone. A schedule without windows is always open.
+type Schedule struct {
+	Windows  []Window
+	Location *time.Location
+}
+
+func NewSchedule(specs []string, timezone string) (*Schedule, error) {
+	location, err := time.LoadLocation(timezone)
+	if err != nil {
+		return nil, fmt.Errorf("invalid maintenance time zone `%s`: %w", timezone, err)
+	}
+
+	windows := make([]Window, 0, len(specs))
+	for _, spec := range specs {
+		w, err := ParseWindow(spec)
+		if err != nil {
+			return nil, err
+		}
+		windows = append(windows, w)
+	}
+
+	return &Schedule{
+		Windows:  windows,
+		Location: location,
+	}, nil
+}
+
+func (s *Schedule) IsOpen(t time.Time) bool {
+	if len(s.Windows) == 0 {
+		return true
+	}
+
+	local := t.In(s.Location)
+	for _, w := range s.Windows {
+		if w.Contains(local) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// NextOpen returns the first minute from t on at which the schedule is open,
+// or the zero time if no window opens within a week.
+func (s *Schedule) NextOpen(t time.Time) time.Time {
+	if s.IsOpen(t) {
+		return t
+	}
+
+	candidate := t.Truncate(time.Minute)
+	if candidate.Before(t) {
+		candidate = candidate.Add(time.Minute)
+	}
+
+	for end := t.Add(8 * 24 * time.Hour); candidate.Before(end); candidate = candidate.Add(time.Minute) {
+		if s.IsOpen(candidate) {
+			return candidate
+		}
+	}
+
+	return time.Time{}
+}
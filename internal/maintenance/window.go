@@ -0,0 +1,124 @@
+package maintenance
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Window is a recurring time-of-day range on some weekdays, e.g. `Mon-Fri 02:00-04:00`.
+// A window ending at or before its start runs past midnight into the next day.
+type Window struct {
+	Days  [7]bool
+	Start time.Duration
+	End   time.Duration
+}
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday,
+	"mon": time.Monday,
+	"tue": time.Tuesday,
+	"wed": time.Wednesday,
+	"thu": time.Thursday,
+	"fri": time.Friday,
+	"sat": time.Saturday,
+}
+
+// ParseWindow parses `[DAYS ]HH:MM-HH:MM`, where DAYS is a comma-separated list of weekdays
+// or weekday ranges, e.g. `Sat,Sun`, `Mon-Fri` or `Mon-Wed,Fri`. Without days, the window is open every day.
+func ParseWindow(spec string) (Window, error) {
+	var w Window
+
+	fields := strings.Fields(spec)
+	var days, hours string
+	switch len(fields) {
+	case 1:
+		hours = fields[0]
+		for d := range w.Days {
+			w.Days[d] = true
+		}
+	case 2:
+		days, hours = fields[0], fields[1]
+		if err := w.parseDays(days); err != nil {
+			return Window{}, fmt.Errorf("invalid maintenance window `%s`: %w", spec, err)
+		}
+	default:
+		return Window{}, fmt.Errorf("invalid maintenance window `%s`: expected [DAYS ]HH:MM-HH:MM", spec)
+	}
+
+	start, end, ok := strings.Cut(hours, "-")
+	if !ok {
+		return Window{}, fmt.Errorf("invalid maintenance window `%s`: expected HH:MM-HH:MM", spec)
+	}
+
+	var err error
+	if w.Start, err = parseTimeOfDay(start); err != nil {
+		return Window{}, fmt.Errorf("invalid maintenance window `%s`: %w", spec, err)
+	}
+	if w.End, err = parseTimeOfDay(end); err != nil {
+		return Window{}, fmt.Errorf("invalid maintenance window `%s`: %w", spec, err)
+	}
+
+	return w, nil
+}
+
+func (w *Window) parseDays(days string) error {
+	for _, part := range strings.Split(days, ",") {
+		from, to, isRange := strings.Cut(part, "-")
+
+		first, err := parseWeekday(from)
+		if err != nil {
+			return err
+		}
+		last := first
+		if isRange {
+			if last, err = parseWeekday(to); err != nil {
+				return err
+			}
+		}
+
+		// Ranges may wrap around the week, e.g. Fri-Mon.
+		for d := first; ; d = (d + 1) % 7 {
+			w.Days[d] = true
+			if d == last {
+				break
+			}
+		}
+	}
+
+	return nil
+}
+
+func parseWeekday(s string) (time.Weekday, error) {
+	d, ok := weekdays[strings.ToLower(s)]
+	if !ok {
+		return 0, fmt.Errorf("unknown weekday `%s`, expected one of Mon, Tue, Wed, Thu, Fri, Sat or Sun", s)
+	}
+
+	return d, nil
+}
+
+func parseTimeOfDay(s string) (time.Duration, error) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time of day `%s`, expected HH:MM", s)
+	}
+
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+// Contains tells if t, in its own location, falls in the window.
+func (w Window) Contains(t time.Time) bool {
+	day := t.Weekday()
+	sinceMidnight := time.Duration(t.Hour())*time.Hour +
+		time.Duration(t.Minute())*time.Minute +
+		time.Duration(t.Second())*time.Second
+
+	if w.Start < w.End {
+		return w.Days[day] && sinceMidnight >= w.Start && sinceMidnight < w.End
+	}
+
+	// Past midnight, the window belongs to the day it started on.
+	previous := (day + 6) % 7
+	return (w.Days[day] && sinceMidnight >= w.Start) || (w.Days[previous] && sinceMidnight < w.End)
+}
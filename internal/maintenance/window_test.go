@@ -0,0 +1,94 @@
+package maintenance
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// 2025-03-24 is a Monday.
+func at(day, hour, minute int) time.Time {
+	return time.Date(2025, 3, 24+day, hour, minute, 0, 0, time.UTC)
+}
+
+func Test_ParseWindow(t *testing.T) {
+	t.Run("should parse weekday ranges and lists", func(t *testing.T) {
+		w, err := ParseWindow("Mon-Wed,Sat 02:00-04:30")
+		assert.NoError(t, err)
+
+		assert.Equal(t, [7]bool{false, true, true, true, false, false, true}, w.Days)
+		assert.Equal(t, 2*time.Hour, w.Start)
+		assert.Equal(t, 4*time.Hour+30*time.Minute, w.End)
+	})
+
+	t.Run("should open every day without weekdays", func(t *testing.T) {
+		w, err := ParseWindow("22:00-23:00")
+		assert.NoError(t, err)
+
+		assert.Equal(t, [7]bool{true, true, true, true, true, true, true}, w.Days)
+	})
+
+	t.Run("should wrap weekday ranges around the week", func(t *testing.T) {
+		w, err := ParseWindow("fri-mon 00:00-01:00")
+		assert.NoError(t, err)
+
+		assert.Equal(t, [7]bool{true, true, false, false, false, true, true}, w.Days)
+	})
+
+	t.Run("should reject malformed windows", func(t *testing.T) {
+		for _, spec := range []string{"", "Mon", "Mon 02:00", "Someday 02:00-03:00", "Mon 25:00-26:00", "Mon Tue 02:00-03:00"} {
+			_, err := ParseWindow(spec)
+			assert.Error(t, err, spec)
+		}
+	})
+}
+
+func Test_Window_Contains(t *testing.T) {
+	t.Run("should contain times within the range on its days", func(t *testing.T) {
+		w, _ := ParseWindow("Mon-Fri 02:00-04:00")
+
+		assert.True(t, w.Contains(at(0, 2, 0)))
+		assert.True(t, w.Contains(at(4, 3, 59)))
+		assert.False(t, w.Contains(at(0, 4, 0)), "the end is exclusive")
+		assert.False(t, w.Contains(at(5, 3, 0)), "saturday is not in the window")
+	})
+
+	t.Run("should run past midnight into the next day", func(t *testing.T) {
+		w, _ := ParseWindow("Fri 22:00-02:00")
+
+		assert.True(t, w.Contains(at(4, 23, 0)))
+		assert.True(t, w.Contains(at(5, 1, 0)), "saturday early morning belongs to friday's window")
+		assert.False(t, w.Contains(at(4, 1, 0)), "friday early morning belongs to thursday")
+	})
+}
+
+func Test_Schedule(t *testing.T) {
+	t.Run("should always be open without windows", func(t *testing.T) {
+		s, err := NewSchedule(nil, "UTC")
+		assert.NoError(t, err)
+
+		assert.True(t, s.IsOpen(at(0, 12, 0)))
+	})
+
+	t.Run("should evaluate windows in the configured time zone", func(t *testing.T) {
+		s, err := NewSchedule([]string{"02:00-04:00"}, "America/New_York")
+		assert.NoError(t, err)
+
+		// 02:30 in New York is 06:30 UTC during daylight saving time.
+		assert.True(t, s.IsOpen(at(0, 6, 30)))
+		assert.False(t, s.IsOpen(at(0, 2, 30)))
+	})
+
+	t.Run("should find when the next window opens", func(t *testing.T) {
+		s, _ := NewSchedule([]string{"Sat 02:00-04:00"}, "UTC")
+
+		assert.Equal(t, at(5, 2, 0), s.NextOpen(at(0, 12, 0).Add(30*time.Second)))
+		assert.Equal(t, at(5, 3, 0), s.NextOpen(at(5, 3, 0)))
+	})
+
+	t.Run("should reject unknown time zones", func(t *testing.T) {
+		_, err := NewSchedule(nil, "Mars/Olympus_Mons")
+		assert.Error(t, err)
+	})
+}
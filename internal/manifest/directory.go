@@ -0,0 +1,177 @@
+package manifest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+
+	"github.com/rs/zerolog"
+
+	"github.com/danilevy1212/self-updater/internal/audit"
+	"github.com/danilevy1212/self-updater/internal/downloader"
+	"github.com/danilevy1212/self-updater/internal/models"
+)
+
+const (
+	manifestFileName          = "release.json"
+	manifestSignatureFileName = "release.json.sig.base64"
+	// Short-lived metadata pinning the current manifest, see Freshness
+	timestampFileName          = "timestamp.json"
+	timestampSignatureFileName = "timestamp.json.sig.base64"
+)
+
+// DirectoryManifestFetcher reads releases from a directory, e.g. a USB stick or an NFS share on
+// air-gapped sites. Artifacts are resolved against the same directory.
+type DirectoryManifestFetcher struct {
+	ApplicationMeta models.ApplicationMeta
+	Logger          *zerolog.Logger
+	Directory       string
+	// Freshness of read manifests isn't checked when nil
+	Freshness *Freshness
+	// Keys trusted to sign manifests, only the embedded keys when nil
+	Keys *audit.KeyRing
+}
+
+func NewDirectoryManifestFetcher(ctx context.Context, applicationMeta models.ApplicationMeta, directory string) (*DirectoryManifestFetcher, error) {
+	logger, err := getLogger(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("missing a logger: %w", err)
+	}
+
+	if logger == nil {
+		return nil, errors.New("logger cannot be nil")
+	}
+
+	if directory == "" {
+		return nil, errors.New("directory cannot be empty")
+	}
+
+	return &DirectoryManifestFetcher{
+		ApplicationMeta: applicationMeta,
+		Logger:          logger,
+		Directory:       directory,
+		Freshness:       getFreshness(ctx),
+		Keys:            getKeyRing(ctx, applicationMeta),
+	}, nil
+}
+
+func (df *DirectoryManifestFetcher) FetchManifest(ctx context.Context) (*models.ReleaseManifest, error) {
+	logger := df.Logger
+
+	manifestPath := filepath.Join(df.Directory, manifestFileName)
+	signaturePath := filepath.Join(df.Directory, manifestSignatureFileName)
+
+	logger.Info().
+		Str("release_json_path", manifestPath).
+		Str("release_json_signature_path", signaturePath).
+		Msg("Reading manifest from directory")
+
+	return fetchFresh(ctx, logger, df.Freshness, trustedKeys(df.Keys, df.ApplicationMeta), df.readTimestamp, func(ctx context.Context, keys *audit.KeyRing) (*models.ReleaseManifest, string, error) {
+		manifestFile, err := os.Open(manifestPath)
+		if err != nil {
+			logger.Error().
+				Err(err).
+				Msg("Failed to open manifest file")
+
+			return nil, "", fmt.Errorf("failed to open manifest file: %w", err)
+		}
+		defer manifestFile.Close()
+
+		sigFile, err := os.Open(signaturePath)
+		if err != nil {
+			logger.Error().
+				Err(err).
+				Msg("Failed to open manifest signature file")
+
+			return nil, "", fmt.Errorf("failed to open manifest signature file: %w", err)
+		}
+		defer sigFile.Close()
+
+		return verifyManifest(logger, keys, manifestFile, sigFile)
+	})
+}
+
+func (df *DirectoryManifestFetcher) readTimestamp(ctx context.Context, keys *audit.KeyRing) (*models.Timestamp, error) {
+	timestampFile, err := os.Open(filepath.Join(df.Directory, timestampFileName))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open timestamp file: %w", err)
+	}
+	defer timestampFile.Close()
+
+	sigFile, err := os.Open(filepath.Join(df.Directory, timestampSignatureFileName))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open timestamp signature file: %w", err)
+	}
+	defer sigFile.Close()
+
+	timestamp, _, err := verifySigned[models.Timestamp](df.Logger, "timestamp", keys, timestampFile, sigFile)
+	return timestamp, err
+}
+
+// FetchArtifact copies the artifact to a temporary file, leaving the directory untouched.
+// `artifact.URL` may be a `file://` URL, an absolute path or a path relative to the directory.
+// HTTP(S) URLs are left to the caller to download, see ErrDownloadArtifact.
+func (df *DirectoryManifestFetcher) FetchArtifact(ctx context.Context, artifact *models.Artifact) (*downloader.File, error) {
+	path, isLocal, err := df.resolve(artifact.URL)
+	if err != nil {
+		return nil, err
+	}
+
+	if !isLocal {
+		return nil, ErrDownloadArtifact
+	}
+
+	df.Logger.Info().
+		Str("artifact_path", path).
+		Msg("Copying artifact from directory")
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	src, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open artifact: %w", err)
+	}
+	defer src.Close()
+
+	return downloader.CopyToTemporaryFile(ctx, src, artifact.Filename+".*")
+}
+
+// resolve tells where an artifact URL points to, and if it is on the local filesystem.
+func (df *DirectoryManifestFetcher) resolve(rawURL string) (string, bool, error) {
+	// Checked first, `C:\releases` would otherwise parse as a URL with a `c` scheme.
+	if filepath.IsAbs(rawURL) {
+		return rawURL, true, nil
+	}
+
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", false, fmt.Errorf("invalid artifact url `%s`: %w", rawURL, err)
+	}
+
+	switch u.Scheme {
+	case "":
+		return filepath.Join(df.Directory, filepath.FromSlash(u.Path)), true, nil
+	case "file":
+		if u.Host != "" && u.Host != "localhost" {
+			return "", false, fmt.Errorf("unsupported artifact url `%s`: file urls must not have a remote host", rawURL)
+		}
+
+		path := u.Path
+		// file:///C:/releases/api.exe
+		if runtime.GOOS == "windows" && len(path) > 2 && path[0] == '/' && path[2] == ':' {
+			path = path[1:]
+		}
+
+		return filepath.FromSlash(path), true, nil
+	case "http", "https":
+		return rawURL, false, nil
+	default:
+		return "", false, fmt.Errorf("unsupported artifact url scheme `%s`", u.Scheme)
+	}
+}
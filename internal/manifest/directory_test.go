@@ -0,0 +1,104 @@
+package manifest
+
+import (
+	"context"
+	"encoding/hex"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/danilevy1212/self-updater/internal/assets"
+	"github.com/danilevy1212/self-updater/internal/logger"
+	"github.com/danilevy1212/self-updater/internal/models"
+	"github.com/danilevy1212/self-updater/internal/models/fixtures"
+)
+
+func newDirectoryFixture(t *testing.T, signature []byte) *DirectoryManifestFetcher {
+	dir := t.TempDir()
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, manifestFileName), fixtures.ReleaseFixture, 0o600))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, manifestSignatureFileName), signature, 0o600))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "api-linux-amd64"), []byte("new binary"), 0o600))
+
+	ctx := SetManifestFetcherLogger(context.Background(), logger.New(true))
+	fetcher, err := NewDirectoryManifestFetcher(ctx, models.ApplicationMeta{AuthorsPublicKey: assets.PublicKeyPEM}, dir)
+	assert.NoError(t, err)
+
+	return fetcher
+}
+
+func Test_DirectoryManifestFetcher_FetchManifest(t *testing.T) {
+	t.Run("should read and verify the manifest from the directory", func(t *testing.T) {
+		fetcher := newDirectoryFixture(t, fixtures.ReleaseSignatureFixture)
+
+		got, err := fetcher.FetchManifest(context.Background())
+		assert.NoError(t, err)
+		assert.Equal(t, "v1.2.3", got.Latest)
+	})
+
+	t.Run("should error if digest doesn't match signature", func(t *testing.T) {
+		fetcher := newDirectoryFixture(t, []byte(`NCqAUE2dp7828kzmHzImiGF8AdRdo/Sr+ZJ0FlQWOJJUZm4Qf4eyO/52+nSfg/fs81sZ28rC6m+hrah9kivkBA==`))
+
+		got, err := fetcher.FetchManifest(context.Background())
+		assert.ErrorContains(t, err, "fetched manifest did not come from authors")
+		assert.Nil(t, got)
+	})
+
+	t.Run("should error if the manifest is missing", func(t *testing.T) {
+		fetcher := newDirectoryFixture(t, fixtures.ReleaseSignatureFixture)
+		assert.NoError(t, os.Remove(filepath.Join(fetcher.Directory, manifestFileName)))
+
+		_, err := fetcher.FetchManifest(context.Background())
+		assert.ErrorIs(t, err, os.ErrNotExist)
+	})
+}
+
+func Test_DirectoryManifestFetcher_FetchArtifact(t *testing.T) {
+	fetcher := newDirectoryFixture(t, fixtures.ReleaseSignatureFixture)
+	artifactPath := filepath.Join(fetcher.Directory, "api-linux-amd64")
+	fileURL := (&url.URL{Scheme: "file", Path: filepath.ToSlash(artifactPath)}).String()
+
+	for name, artifactURL := range map[string]string{
+		"relative path": "api-linux-amd64",
+		"absolute path": artifactPath,
+		"file url":      fileURL,
+	} {
+		t.Run("should copy the artifact from a "+name, func(t *testing.T) {
+			f, err := fetcher.FetchArtifact(context.Background(), &models.Artifact{
+				Filename: "api-linux-amd64",
+				URL:      artifactURL,
+			})
+			assert.NoError(t, err)
+			defer func() {
+				_ = f.Close()
+				_ = os.Remove(f.Name())
+			}()
+
+			contents, err := io.ReadAll(f)
+			assert.NoError(t, err)
+			assert.Equal(t, "new binary", string(contents))
+			assert.Equal(t, sha256Hex(contents), hex.EncodeToString(f.SHA256), "should hash the artifact while copying it")
+			assert.NotEqual(t, artifactPath, f.Name(), "should not hand out the file in the directory")
+			assert.FileExists(t, artifactPath)
+		})
+	}
+
+	t.Run("should reject unsupported schemes", func(t *testing.T) {
+		_, err := fetcher.FetchArtifact(context.Background(), &models.Artifact{
+			Filename: "api-linux-amd64",
+			URL:      "ftp://releases.acme.internal/api-linux-amd64",
+		})
+		assert.ErrorContains(t, err, "unsupported artifact url scheme")
+	})
+
+	t.Run("should leave http urls to be downloaded", func(t *testing.T) {
+		_, err := fetcher.FetchArtifact(context.Background(), &models.Artifact{
+			Filename: "api-linux-amd64",
+			URL:      "https://releases.acme.internal/api-linux-amd64",
+		})
+		assert.ErrorIs(t, err, ErrDownloadArtifact)
+	})
+}
@@ -0,0 +1,192 @@
+package manifest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"github.com/danilevy1212/self-updater/internal/audit"
+	"github.com/danilevy1212/self-updater/internal/models"
+)
+
+type ctxKeyFreshness struct{}
+
+var freshnessKey = ctxKeyFreshness{}
+
+// SetManifestFetcherFreshness makes the manifest fetchers created with ctx check the freshness of what they fetch.
+func SetManifestFetcherFreshness(ctx context.Context, freshness *Freshness) context.Context {
+	return context.WithValue(ctx, freshnessKey, freshness)
+}
+
+// getFreshness returns the freshness set on ctx, fetchers don't check freshness without one.
+func getFreshness(ctx context.Context) *Freshness {
+	f, _ := ctx.Value(freshnessKey).(*Freshness)
+	return f
+}
+
+// Freshness protects installations from freeze and rollback attacks, like The Update Framework does.
+// Whoever can serve an old signed manifest can't hold an installation on it once it, or the timestamp
+// pinning the current one, expires. Nor can they take an installation back to older metadata than it
+// already trusted.
+type Freshness struct {
+	// Refuse metadata without a timestamp, an expiry or a version, instead of only checking those it has
+	Require bool
+	Now     func() time.Time
+	// Persists the trusted versions whenever they are raised
+	Save func(models.MetadataVersions) error
+
+	mu      sync.Mutex
+	trusted models.MetadataVersions
+}
+
+func NewFreshness(trusted models.MetadataVersions, require bool, save func(models.MetadataVersions) error) *Freshness {
+	return &Freshness{
+		Require: require,
+		Now:     time.Now,
+		Save:    save,
+		trusted: trusted,
+	}
+}
+
+// Trusted returns the highest metadata versions checked so far.
+func (f *Freshness) Trusted() models.MetadataVersions {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.trusted
+}
+
+// Check checks the manifest and the timestamp naming it are fresh, and trusts their versions from then on.
+// timestamp is nil when none is published, manifestDigest is the hex digest of the manifest file.
+func (f *Freshness) Check(logger *zerolog.Logger, timestamp *models.Timestamp, manifest *models.ReleaseManifest, manifestDigest string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	now := f.Now()
+	trusted := f.trusted
+
+	switch {
+	case timestamp != nil:
+		if timestamp.Expires.IsZero() {
+			return errors.New("timestamp has no expiry")
+		}
+		if !now.Before(timestamp.Expires) {
+			return fmt.Errorf("timestamp expired at %s", timestamp.Expires.Format(time.RFC3339))
+		}
+		if timestamp.Version < trusted.Timestamp {
+			return fmt.Errorf("timestamp version %d is older than trusted version %d", timestamp.Version, trusted.Timestamp)
+		}
+		// A fresh timestamp must not vouch for a stale manifest served along with it.
+		if timestamp.Manifest.Digest != manifestDigest {
+			return fmt.Errorf("manifest digest %s does not match digest %s in timestamp", manifestDigest, timestamp.Manifest.Digest)
+		}
+		if timestamp.Manifest.Version != manifest.MetadataVersion {
+			return fmt.Errorf("manifest version %d does not match version %d in timestamp", manifest.MetadataVersion, timestamp.Manifest.Version)
+		}
+		trusted.Timestamp = timestamp.Version
+	case f.Require:
+		return errors.New("timestamp is missing")
+	case trusted.Timestamp > 0:
+		return fmt.Errorf("timestamp is missing, after trusting timestamp version %d", trusted.Timestamp)
+	}
+
+	switch {
+	case !manifest.Expires.IsZero() && !now.Before(manifest.Expires):
+		return fmt.Errorf("manifest expired at %s", manifest.Expires.Format(time.RFC3339))
+	case manifest.Expires.IsZero() && f.Require:
+		return errors.New("manifest has no expiry")
+	case manifest.MetadataVersion < trusted.Manifest:
+		return fmt.Errorf("manifest version %d is older than trusted version %d", manifest.MetadataVersion, trusted.Manifest)
+	case manifest.MetadataVersion == 0 && f.Require:
+		return errors.New("manifest has no version")
+	}
+	trusted.Manifest = manifest.MetadataVersion
+
+	if trusted == f.trusted {
+		return nil
+	}
+	f.trusted = trusted
+
+	logger.Info().
+		Int64("manifest_version", trusted.Manifest).
+		Int64("timestamp_version", trusted.Timestamp).
+		Msg("Trusting newer metadata versions")
+
+	if f.Save != nil {
+		if err := f.Save(trusted); err != nil {
+			logger.Warn().
+				Err(err).
+				Msg("Failed to persist metadata versions, they will only be enforced until restart")
+		}
+	}
+
+	return nil
+}
+
+// fetchTimestamp fetches the timestamp with fetch. Returns nil without an error when it can't be fetched,
+// as long as none is required nor was trusted before, for releases that don't publish timestamps.
+func (f *Freshness) fetchTimestamp(
+	ctx context.Context,
+	logger *zerolog.Logger,
+	fetch func(ctx context.Context) (*models.Timestamp, error),
+) (*models.Timestamp, error) {
+	timestamp, err := fetch(ctx)
+	if err == nil {
+		return timestamp, nil
+	}
+
+	if f.Require || f.Trusted().Timestamp > 0 {
+		return nil, fmt.Errorf("failed to fetch timestamp: %w", err)
+	}
+
+	logger.Debug().
+		Err(err).
+		Msg("No timestamp could be fetched, only checking the manifest for freshness")
+
+	return nil, nil
+}
+
+// fetchFresh fetches the manifest, and with a freshness to check it against, the timestamp.
+// The manifest comes first, the timestamp may be signed by a key only its key rotations introduce.
+// The rotations are applied to a copy of keys, which are only replaced once the manifest is verified and fresh,
+// so that a forged or replayed manifest can't change the keys trusted.
+func fetchFresh(
+	ctx context.Context,
+	logger *zerolog.Logger,
+	freshness *Freshness,
+	keys *audit.KeyRing,
+	fetchTimestamp func(ctx context.Context, keys *audit.KeyRing) (*models.Timestamp, error),
+	fetchManifest func(ctx context.Context, keys *audit.KeyRing) (*models.ReleaseManifest, string, error),
+) (*models.ReleaseManifest, error) {
+	rotated := keys.Clone()
+
+	manifest, manifestDigest, err := fetchManifest(ctx, rotated)
+	if err != nil {
+		return nil, err
+	}
+
+	if freshness != nil {
+		timestamp, err := freshness.fetchTimestamp(ctx, logger, func(ctx context.Context) (*models.Timestamp, error) {
+			return fetchTimestamp(ctx, rotated)
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		if err := freshness.Check(logger, timestamp, manifest, manifestDigest); err != nil {
+			logger.Error().
+				Err(err).
+				Msg("Manifest is stale or rolled back")
+
+			return nil, fmt.Errorf("refusing stale manifest: %w", err)
+		}
+	}
+
+	keys.Adopt(logger, rotated)
+
+	return manifest, nil
+}
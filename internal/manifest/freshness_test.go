@@ -0,0 +1,234 @@
+package manifest
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/danilevy1212/self-updater/internal/assets"
+	"github.com/danilevy1212/self-updater/internal/audit"
+	"github.com/danilevy1212/self-updater/internal/logger"
+	"github.com/danilevy1212/self-updater/internal/models"
+)
+
+func Test_Freshness_Check(t *testing.T) {
+	now := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	trusted := models.MetadataVersions{Manifest: 5, Timestamp: 100}
+
+	freshManifest := func() *models.ReleaseManifest {
+		return &models.ReleaseManifest{MetadataVersion: 5, Expires: now.Add(30 * 24 * time.Hour)}
+	}
+	freshTimestamp := func() *models.Timestamp {
+		return &models.Timestamp{
+			Version:  101,
+			Expires:  now.Add(time.Hour),
+			Manifest: models.MetadataFile{Version: 5, Digest: "abcd"},
+		}
+	}
+	newFreshness := func(trusted models.MetadataVersions, require bool) (*Freshness, *[]models.MetadataVersions) {
+		var saved []models.MetadataVersions
+		f := NewFreshness(trusted, require, func(v models.MetadataVersions) error {
+			saved = append(saved, v)
+			return nil
+		})
+		f.Now = func() time.Time { return now }
+
+		return f, &saved
+	}
+
+	t.Run("should accept fresh metadata and trust its versions", func(t *testing.T) {
+		f, saved := newFreshness(trusted, true)
+		m := freshManifest()
+		m.MetadataVersion = 6
+		ts := freshTimestamp()
+		ts.Manifest.Version = 6
+
+		err := f.Check(logger.New(true), ts, m, "abcd")
+		assert.NoError(t, err)
+		assert.Equal(t, models.MetadataVersions{Manifest: 6, Timestamp: 101}, f.Trusted())
+		assert.Equal(t, []models.MetadataVersions{{Manifest: 6, Timestamp: 101}}, *saved)
+	})
+
+	t.Run("should not save versions it already trusted", func(t *testing.T) {
+		f, saved := newFreshness(models.MetadataVersions{Manifest: 5, Timestamp: 101}, true)
+
+		err := f.Check(logger.New(true), freshTimestamp(), freshManifest(), "abcd")
+		assert.NoError(t, err)
+		assert.Empty(t, *saved)
+	})
+
+	t.Run("should accept metadata without a timestamp or expiry unless required", func(t *testing.T) {
+		f, _ := newFreshness(models.MetadataVersions{}, false)
+
+		err := f.Check(logger.New(true), nil, &models.ReleaseManifest{}, "abcd")
+		assert.NoError(t, err)
+	})
+
+	for _, tc := range []struct {
+		should    string
+		require   bool
+		timestamp func(ts *models.Timestamp) *models.Timestamp
+		manifest  func(m *models.ReleaseManifest)
+		err       string
+	}{
+		{
+			should: "should refuse an expired timestamp",
+			timestamp: func(ts *models.Timestamp) *models.Timestamp {
+				ts.Expires = now.Add(-time.Minute)
+				return ts
+			},
+			err: "timestamp expired at 2026-10-18T11:59:00Z",
+		},
+		{
+			should: "should refuse a rolled back timestamp",
+			timestamp: func(ts *models.Timestamp) *models.Timestamp {
+				ts.Version = 99
+				return ts
+			},
+			err: "timestamp version 99 is older than trusted version 100",
+		},
+		{
+			should: "should refuse a manifest the timestamp doesn't vouch for",
+			timestamp: func(ts *models.Timestamp) *models.Timestamp {
+				ts.Manifest.Digest = "dcba"
+				return ts
+			},
+			err: "manifest digest abcd does not match digest dcba in timestamp",
+		},
+		{
+			should: "should refuse a missing timestamp once one was trusted",
+			timestamp: func(ts *models.Timestamp) *models.Timestamp {
+				return nil
+			},
+			err: "timestamp is missing, after trusting timestamp version 100",
+		},
+		{
+			should:  "should refuse a missing timestamp when required",
+			require: true,
+			timestamp: func(ts *models.Timestamp) *models.Timestamp {
+				return nil
+			},
+			err: "timestamp is missing",
+		},
+		{
+			should: "should refuse an expired manifest",
+			manifest: func(m *models.ReleaseManifest) {
+				m.Expires = now.Add(-24 * time.Hour)
+			},
+			err: "manifest expired at 2026-10-17T12:00:00Z",
+		},
+		{
+			should:  "should refuse a manifest without expiry when required",
+			require: true,
+			manifest: func(m *models.ReleaseManifest) {
+				m.Expires = time.Time{}
+			},
+			err: "manifest has no expiry",
+		},
+		{
+			should: "should refuse a rolled back manifest",
+			timestamp: func(ts *models.Timestamp) *models.Timestamp {
+				ts.Manifest.Version = 4
+				return ts
+			},
+			manifest: func(m *models.ReleaseManifest) {
+				m.MetadataVersion = 4
+			},
+			err: "manifest version 4 is older than trusted version 5",
+		},
+	} {
+		t.Run(tc.should, func(t *testing.T) {
+			f, saved := newFreshness(trusted, tc.require)
+			ts := freshTimestamp()
+			if tc.timestamp != nil {
+				ts = tc.timestamp(ts)
+			}
+			m := freshManifest()
+			if tc.manifest != nil {
+				tc.manifest(m)
+			}
+
+			err := f.Check(logger.New(true), ts, m, "abcd")
+			assert.EqualError(t, err, tc.err)
+			assert.Equal(t, trusted, f.Trusted(), "should not trust refused metadata")
+			assert.Empty(t, *saved)
+		})
+	}
+}
+
+func Test_DirectoryManifestFetcher_freshness(t *testing.T) {
+	now := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+
+	oldVerify := audit.VerifySignature
+	t.Cleanup(func() { audit.VerifySignature = oldVerify })
+	audit.VerifySignature = func(publicKey []byte, digestHex, signatureBase64 string) (bool, error) {
+		return true, nil
+	}
+
+	setup := func(t *testing.T, manifest models.ReleaseManifest, timestampFor func(digest string) *models.Timestamp) (*DirectoryManifestFetcher, *Freshness) {
+		dir := t.TempDir()
+		data, err := json.Marshal(manifest)
+		assert.NoError(t, err)
+		assert.NoError(t, os.WriteFile(filepath.Join(dir, manifestFileName), data, 0o600))
+		assert.NoError(t, os.WriteFile(filepath.Join(dir, manifestSignatureFileName), []byte("signature"), 0o600))
+
+		if timestampFor != nil {
+			sum := sha256.Sum256(data)
+			data, err := json.Marshal(timestampFor(hex.EncodeToString(sum[:])))
+			assert.NoError(t, err)
+			assert.NoError(t, os.WriteFile(filepath.Join(dir, timestampFileName), data, 0o600))
+			assert.NoError(t, os.WriteFile(filepath.Join(dir, timestampSignatureFileName), []byte("signature"), 0o600))
+		}
+
+		freshness := NewFreshness(models.MetadataVersions{Manifest: 2, Timestamp: 10}, false, nil)
+		freshness.Now = func() time.Time { return now }
+		ctx := SetManifestFetcherLogger(context.Background(), logger.New(true))
+		ctx = SetManifestFetcherFreshness(ctx, freshness)
+		fetcher, err := NewDirectoryManifestFetcher(ctx, models.ApplicationMeta{AuthorsPublicKey: assets.PublicKeyPEM}, dir)
+		assert.NoError(t, err)
+
+		return fetcher, freshness
+	}
+
+	t.Run("should read the timestamp and the manifest it vouches for", func(t *testing.T) {
+		fetcher, freshness := setup(t,
+			models.ReleaseManifest{Latest: "v1.2.3", MetadataVersion: 3, Expires: now.Add(time.Hour)},
+			func(digest string) *models.Timestamp {
+				return &models.Timestamp{Version: 11, Expires: now.Add(time.Hour), Manifest: models.MetadataFile{Version: 3, Digest: digest}}
+			},
+		)
+
+		got, err := fetcher.FetchManifest(context.Background())
+		assert.NoError(t, err)
+		assert.Equal(t, "v1.2.3", got.Latest)
+		assert.Equal(t, models.MetadataVersions{Manifest: 3, Timestamp: 11}, freshness.Trusted())
+	})
+
+	t.Run("should refuse a replayed manifest once the timestamp is gone", func(t *testing.T) {
+		fetcher, _ := setup(t, models.ReleaseManifest{Latest: "v1.2.2", MetadataVersion: 2, Expires: now.Add(time.Hour)}, nil)
+
+		got, err := fetcher.FetchManifest(context.Background())
+		assert.ErrorContains(t, err, "failed to fetch timestamp")
+		assert.Nil(t, got)
+	})
+
+	t.Run("should refuse a stale manifest served with a fresh timestamp", func(t *testing.T) {
+		fetcher, _ := setup(t,
+			models.ReleaseManifest{Latest: "v1.2.2", MetadataVersion: 2, Expires: now.Add(time.Hour)},
+			func(digest string) *models.Timestamp {
+				return &models.Timestamp{Version: 11, Expires: now.Add(time.Hour), Manifest: models.MetadataFile{Version: 3, Digest: "another manifest"}}
+			},
+		)
+
+		got, err := fetcher.FetchManifest(context.Background())
+		assert.ErrorContains(t, err, "refusing stale manifest")
+		assert.Nil(t, got)
+	})
+}
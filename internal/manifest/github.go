@@ -2,19 +2,13 @@ package manifest
 
 import (
 	"context"
-	"encoding/hex"
-	"encoding/json"
 	"errors"
 	"fmt"
-	"io"
-	"os"
-	"sync"
+	"strings"
 
 	"github.com/rs/zerolog"
 
 	"github.com/danilevy1212/self-updater/internal/audit"
-	"github.com/danilevy1212/self-updater/internal/digest"
-	"github.com/danilevy1212/self-updater/internal/downloader"
 	"github.com/danilevy1212/self-updater/internal/models"
 )
 
@@ -25,6 +19,10 @@ var loggerKey = ctxKeyLogger{}
 type GithubManifestFetcher struct {
 	ApplicationMeta models.ApplicationMeta
 	Logger          *zerolog.Logger
+	// Freshness of fetched manifests isn't checked when nil
+	Freshness *Freshness
+	// Keys trusted to sign manifests, only the embedded keys when nil
+	Keys *audit.KeyRing
 }
 
 func getLogger(ctx context.Context) (*zerolog.Logger, error) {
@@ -36,10 +34,15 @@ func getLogger(ctx context.Context) (*zerolog.Logger, error) {
 	return l, nil
 }
 
-func SetGithubManifestFetcherLogger(ctx context.Context, logger *zerolog.Logger) context.Context {
+// SetManifestFetcherLogger sets the logger used by the manifest fetchers created with ctx.
+func SetManifestFetcherLogger(ctx context.Context, logger *zerolog.Logger) context.Context {
 	return context.WithValue(ctx, loggerKey, logger)
 }
 
+func SetGithubManifestFetcherLogger(ctx context.Context, logger *zerolog.Logger) context.Context {
+	return SetManifestFetcherLogger(ctx, logger)
+}
+
 func NewGithubManifestFetcher(ctx context.Context, applicationMeta models.ApplicationMeta) (*GithubManifestFetcher, error) {
 	logger, err := getLogger(ctx)
 	if err != nil {
@@ -53,12 +56,15 @@ func NewGithubManifestFetcher(ctx context.Context, applicationMeta models.Applic
 	return &GithubManifestFetcher{
 		ApplicationMeta: applicationMeta,
 		Logger:          logger,
+		Freshness:       getFreshness(ctx),
+		Keys:            getKeyRing(ctx, applicationMeta),
 	}, nil
 }
 
 func (ghf *GithubManifestFetcher) FetchManifest(ctx context.Context) (*models.ReleaseManifest, error) {
 	logger := ghf.Logger
 	meta := ghf.ApplicationMeta
+	keys := trustedKeys(ghf.Keys, meta)
 
 	releaseJSONURL := fmt.Sprintf(
 		"https://%s/%s/%s/releases/latest/download/release.json",
@@ -67,125 +73,19 @@ func (ghf *GithubManifestFetcher) FetchManifest(ctx context.Context) (*models.Re
 		meta.SourceInfo.Name,
 	)
 	releaseJSONSignatureURL := releaseJSONURL + ".sig.base64"
+	timestampURL := strings.TrimSuffix(releaseJSONURL, manifestFileName) + timestampFileName
 
 	logger.Info().
 		Str("release_json_url", releaseJSONURL).
 		Str("release_json_signature_url", releaseJSONSignatureURL).
 		Msg("Fetching manifest from GitHub")
 
-	var (
-		wg                    sync.WaitGroup
-		manifestFile, sigFile *os.File
-		manifestErr, sigErr   error
+	return fetchFresh(ctx, logger, ghf.Freshness, keys,
+		func(ctx context.Context, keys *audit.KeyRing) (*models.Timestamp, error) {
+			return fetchSignedTimestamp(ctx, logger, keys, timestampURL, timestampURL+".sig.base64", downloadURL)
+		},
+		func(ctx context.Context, keys *audit.KeyRing) (*models.ReleaseManifest, string, error) {
+			return fetchSignedManifest(ctx, logger, keys, releaseJSONURL, releaseJSONSignatureURL, downloadURL)
+		},
 	)
-
-	// Fetch both files in parallel, cancel all if one fails
-	downloadCtx, cancel := context.WithCancel(ctx)
-	defer cancel()
-
-	wg.Add(2)
-	go func() {
-		defer wg.Done()
-		manifestFile, manifestErr = downloader.DownloadToTemporaryFile(downloadCtx, releaseJSONURL, "release.json.*")
-		if manifestErr != nil {
-			cancel()
-		}
-	}()
-	go func() {
-		defer wg.Done()
-		sigFile, sigErr = downloader.DownloadToTemporaryFile(downloadCtx, releaseJSONSignatureURL, "release.json.sig.base64.*")
-		if sigErr != nil {
-			cancel()
-		}
-	}()
-	logger.Info().
-		Msg("Waiting for manifest and signature downloads to complete")
-	wg.Wait()
-
-	if manifestErr != nil || sigErr != nil {
-		errs := []error{}
-
-		if manifestErr != nil {
-			logger.Error().
-				Err(manifestErr).
-				Msg("Failed to download manifest file")
-
-			errs = append(errs, manifestErr)
-		}
-
-		if sigErr != nil {
-			logger.Error().
-				Err(sigErr).
-				Msg("Failed to download manifest signature file")
-
-			errs = append(errs, sigErr)
-		}
-
-		if manifestFile != nil {
-			_ = manifestFile.Close()
-			_ = os.Remove(manifestFile.Name())
-		}
-
-		if sigFile != nil {
-			_ = sigFile.Close()
-			_ = os.Remove(sigFile.Name())
-		}
-
-		return nil, fmt.Errorf("failed to download manifest files: %w", errors.Join(errs...))
-	}
-
-	defer func() {
-		_ = sigFile.Close()
-		_ = os.Remove(sigFile.Name())
-		_ = manifestFile.Close()
-		_ = os.Remove(manifestFile.Name())
-	}()
-
-	publicKey := meta.AuthorsPublicKey
-	sigFileContents, err := io.ReadAll(sigFile)
-	if err != nil {
-		logger.Error().
-			Err(err).
-			Msg("Failed to read signature file")
-
-		return nil, fmt.Errorf("failed to read signature file: %w", err)
-	}
-
-	manifestDigestRaw, err := digest.DigestFile(manifestFile.Name())
-	if err != nil {
-		logger.Error().
-			Err(err).
-			Msg("Failed to compute manifest file digest")
-
-		return nil, fmt.Errorf("failed to compute manifest file digest: %w", err)
-	}
-
-	// A little silly back-and-forth I have to do in the name of re-usability.
-	isVerified, err := audit.VerifySignature(publicKey, hex.EncodeToString(manifestDigestRaw), string(sigFileContents))
-	if err != nil {
-		logger.Error().
-			Err(err).
-			Msg("Failed to verify manifest signature")
-
-		return nil, fmt.Errorf("failed to verify manifest signature: %w", err)
-	}
-
-	if !isVerified {
-		logger.Error().
-			Msg("Manifest signature verification failed. Fetched manifest did not come from authors")
-
-		return nil, errors.New("manifest signature verification failed: fetched manifest did not come from authors")
-	}
-
-	var result models.ReleaseManifest
-	err = json.NewDecoder(manifestFile).Decode(&result)
-	if err != nil {
-		logger.Error().
-			Err(err).
-			Msg("Failed to unmarshal manifest JSON")
-
-		return nil, fmt.Errorf("failed to unmarshal manifest JSON: %w", err)
-	}
-
-	return &result, nil
 }
@@ -83,7 +83,7 @@ func Test_GithubManifestFetcher_FetchManifest(t *testing.T) {
 			sawCanceled    atomic.Bool
 		)
 
-		downloader.DownloadToTemporaryFile = func(dctx context.Context, url, pattern string) (*os.File, error) {
+		downloader.DownloadToTemporaryFile = func(dctx context.Context, url, pattern string) (*downloader.File, error) {
 			switch url {
 			case manifestURL:
 				atomic.AddInt32(&manifestCalled, 1)
@@ -136,7 +136,7 @@ func Test_GithubManifestFetcher_FetchManifest(t *testing.T) {
 			manifestSignatureFilePath string
 		)
 
-		downloader.DownloadToTemporaryFile = func(dctx context.Context, url, pattern string) (*os.File, error) {
+		downloader.DownloadToTemporaryFile = func(dctx context.Context, url, pattern string) (*downloader.File, error) {
 			switch url {
 			case manifestURL:
 				atomic.AddInt32(&manifestCalled, 1)
@@ -153,7 +153,7 @@ func Test_GithubManifestFetcher_FetchManifest(t *testing.T) {
 					_ = manifestFile.Close()
 					t.Fatalf("failed to seek to start of temp manifest file: %v", err)
 				}
-				return manifestFile, nil
+				return &downloader.File{File: manifestFile}, nil
 			case sigURL:
 				atomic.AddInt32(&sigCalled, 1)
 				manifestSignatureFile, err := os.CreateTemp("", "release.json.sig.base64*")
@@ -169,7 +169,7 @@ func Test_GithubManifestFetcher_FetchManifest(t *testing.T) {
 					_ = manifestSignatureFile.Close()
 					t.Fatalf("failed to seek to start of temp signature file: %v", err)
 				}
-				return manifestSignatureFile, nil
+				return &downloader.File{File: manifestSignatureFile}, nil
 			default:
 				t.Fatalf("unexpected url: %s", url)
 				return nil, nil
@@ -213,7 +213,7 @@ func Test_GithubManifestFetcher_FetchManifest(t *testing.T) {
 			manifestSignatureFilePath string
 		)
 
-		downloader.DownloadToTemporaryFile = func(dctx context.Context, url, pattern string) (*os.File, error) {
+		downloader.DownloadToTemporaryFile = func(dctx context.Context, url, pattern string) (*downloader.File, error) {
 			switch url {
 			case manifestURL:
 				atomic.AddInt32(&manifestCalled, 1)
@@ -231,7 +231,7 @@ func Test_GithubManifestFetcher_FetchManifest(t *testing.T) {
 					t.Fatalf("failed to seek to start of temp manifest file: %v", err)
 				}
 				manifestFilePath = manifestFile.Name()
-				return manifestFile, nil
+				return &downloader.File{File: manifestFile}, nil
 			case sigURL:
 				atomic.AddInt32(&sigCalled, 1)
 				manifestSignatureFile, err := os.CreateTemp("", "release.json.sig.base64*")
@@ -253,7 +253,7 @@ func Test_GithubManifestFetcher_FetchManifest(t *testing.T) {
 					t.Fatalf("failed to seek to start of temp signature file: %v", err)
 				}
 				manifestSignatureFilePath = manifestSignatureFile.Name()
-				return manifestSignatureFile, nil
+				return &downloader.File{File: manifestSignatureFile}, nil
 			default:
 				t.Fatalf("unexpected url: %s", url)
 				return nil, nil
@@ -0,0 +1,142 @@
+package manifest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"path"
+	"strings"
+	"text/template"
+
+	"github.com/rs/zerolog"
+
+	"github.com/danilevy1212/self-updater/internal/audit"
+	"github.com/danilevy1212/self-updater/internal/models"
+)
+
+// URLTemplateData is what manifest URL templates can refer to,
+// e.g. `{{.BaseURL}}/{{.Owner}}/{{.Repo}}/{{.Channel}}/release.json`.
+type URLTemplateData struct {
+	BaseURL string
+	Host    string
+	Owner   string
+	Repo    string
+	Channel string
+	OS      string
+	Arch    string
+}
+
+func NewURLTemplateData(applicationMeta models.ApplicationMeta, channel string) URLTemplateData {
+	return URLTemplateData{
+		BaseURL: strings.TrimSuffix(applicationMeta.SourceInfo.BaseURL, "/"),
+		Host:    applicationMeta.SourceInfo.Host,
+		Owner:   applicationMeta.SourceInfo.Owner,
+		Repo:    applicationMeta.SourceInfo.Name,
+		Channel: channel,
+		OS:      applicationMeta.OS,
+		Arch:    applicationMeta.Arch,
+	}
+}
+
+func RenderURL(urlTemplate string, data URLTemplateData) (string, error) {
+	tmpl, err := template.New("url").Option("missingkey=error").Parse(urlTemplate)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse url template `%s`: %w", urlTemplate, err)
+	}
+
+	var sb strings.Builder
+	if err := tmpl.Execute(&sb, data); err != nil {
+		return "", fmt.Errorf("failed to render url template `%s`: %w", urlTemplate, err)
+	}
+
+	return sb.String(), nil
+}
+
+// HTTPManifestFetcher fetches the signed manifest from any HTTP(S) server, e.g. a self-hosted artifact server.
+type HTTPManifestFetcher struct {
+	ApplicationMeta models.ApplicationMeta
+	Logger          *zerolog.Logger
+	ManifestURL     string
+	SignatureURL    string
+	// Signed with a `.sig.base64` suffix, like the manifest by default
+	TimestampURL string
+	// Freshness of fetched manifests isn't checked when nil
+	Freshness *Freshness
+	// Keys trusted to sign manifests, only the embedded keys when nil
+	Keys *audit.KeyRing
+}
+
+// NewHTTPManifestFetcher renders the manifest and signature URL templates, the signature defaults to
+// the manifest URL with a `.sig.base64` suffix. The timestamp is expected next to the manifest.
+func NewHTTPManifestFetcher(
+	ctx context.Context,
+	applicationMeta models.ApplicationMeta,
+	manifestURLTemplate, signatureURLTemplate, channel string,
+) (*HTTPManifestFetcher, error) {
+	logger, err := getLogger(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("missing a logger: %w", err)
+	}
+
+	if logger == nil {
+		return nil, errors.New("logger cannot be nil")
+	}
+
+	data := NewURLTemplateData(applicationMeta, channel)
+	manifestURL, err := RenderURL(manifestURLTemplate, data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid manifest url: %w", err)
+	}
+
+	signatureURL := manifestURL + ".sig.base64"
+	if signatureURLTemplate != "" {
+		signatureURL, err = RenderURL(signatureURLTemplate, data)
+		if err != nil {
+			return nil, fmt.Errorf("invalid manifest signature url: %w", err)
+		}
+	}
+
+	return &HTTPManifestFetcher{
+		ApplicationMeta: applicationMeta,
+		Logger:          logger,
+		ManifestURL:     manifestURL,
+		SignatureURL:    signatureURL,
+		TimestampURL:    SiblingURL(manifestURL, timestampFileName),
+		Freshness:       getFreshness(ctx),
+		Keys:            getKeyRing(ctx, applicationMeta),
+	}, nil
+}
+
+// SiblingURL is the URL of the file with the given name in the same directory as rawURL, e.g. the
+// timestamp next to the manifest. Any query is kept, it may hold credentials.
+func SiblingURL(rawURL, name string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return rawURL
+	}
+
+	u.Path = path.Join(path.Dir(u.Path), name)
+	u.RawPath = ""
+
+	return u.String()
+}
+
+func (hf *HTTPManifestFetcher) FetchManifest(ctx context.Context) (*models.ReleaseManifest, error) {
+	logger := hf.Logger
+
+	logger.Info().
+		Str("release_json_url", hf.ManifestURL).
+		Str("release_json_signature_url", hf.SignatureURL).
+		Msg("Fetching manifest over HTTP")
+
+	keys := trustedKeys(hf.Keys, hf.ApplicationMeta)
+	return fetchFresh(ctx, logger, hf.Freshness, keys,
+		func(ctx context.Context, keys *audit.KeyRing) (*models.Timestamp, error) {
+			return fetchSignedTimestamp(ctx, logger, keys, hf.TimestampURL, hf.TimestampURL+".sig.base64", downloadURL)
+		},
+		func(ctx context.Context, keys *audit.KeyRing) (*models.ReleaseManifest, string, error) {
+			return fetchSignedManifest(ctx, logger, keys, hf.ManifestURL, hf.SignatureURL, downloadURL)
+		},
+	)
+}
@@ -0,0 +1,126 @@
+package manifest
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/danilevy1212/self-updater/internal/assets"
+	"github.com/danilevy1212/self-updater/internal/downloader"
+	"github.com/danilevy1212/self-updater/internal/logger"
+	"github.com/danilevy1212/self-updater/internal/models"
+	"github.com/danilevy1212/self-updater/internal/models/fixtures"
+)
+
+func Test_RenderURL(t *testing.T) {
+	data := URLTemplateData{
+		BaseURL: "https://artifacts.acme.internal",
+		Owner:   "acme",
+		Repo:    "widget",
+		Channel: "beta",
+	}
+
+	t.Run("should render the template with the given data", func(t *testing.T) {
+		got, err := RenderURL("{{.BaseURL}}/{{.Owner}}/{{.Repo}}/{{.Channel}}/release.json", data)
+
+		assert.NoError(t, err)
+		assert.Equal(t, "https://artifacts.acme.internal/acme/widget/beta/release.json", got)
+	})
+
+	t.Run("should error on unknown fields", func(t *testing.T) {
+		_, err := RenderURL("{{.Bucket}}/release.json", data)
+
+		assert.Error(t, err)
+	})
+}
+
+func Test_NewHTTPManifestFetcher(t *testing.T) {
+	ctx := SetManifestFetcherLogger(context.Background(), logger.New(true))
+	meta := models.ApplicationMeta{
+		SourceInfo: models.SourceInfo{
+			BaseURL: "https://artifacts.acme.internal/",
+			Owner:   "acme",
+			Name:    "widget",
+		},
+	}
+
+	t.Run("should default the signature url to the manifest url", func(t *testing.T) {
+		fetcher, err := NewHTTPManifestFetcher(ctx, meta, "{{.BaseURL}}/{{.Repo}}/release.json", "", "stable")
+
+		assert.NoError(t, err)
+		assert.Equal(t, "https://artifacts.acme.internal/widget/release.json", fetcher.ManifestURL)
+		assert.Equal(t, "https://artifacts.acme.internal/widget/release.json.sig.base64", fetcher.SignatureURL)
+	})
+
+	t.Run("should expect the timestamp next to the manifest", func(t *testing.T) {
+		fetcher, err := NewHTTPManifestFetcher(ctx, meta, "{{.BaseURL}}/{{.Repo}}/release.json?token=abc", "", "stable")
+
+		assert.NoError(t, err)
+		assert.Equal(t, "https://artifacts.acme.internal/widget/timestamp.json?token=abc", fetcher.TimestampURL)
+	})
+
+	t.Run("should render a custom signature url", func(t *testing.T) {
+		fetcher, err := NewHTTPManifestFetcher(
+			ctx,
+			meta,
+			"{{.BaseURL}}/{{.Channel}}/release.json",
+			"{{.BaseURL}}/{{.Channel}}/release.sig",
+			"nightly",
+		)
+
+		assert.NoError(t, err)
+		assert.Equal(t, "https://artifacts.acme.internal/nightly/release.sig", fetcher.SignatureURL)
+	})
+
+	t.Run("should return an error if logger is missing", func(t *testing.T) {
+		fetcher, err := NewHTTPManifestFetcher(context.Background(), meta, "{{.BaseURL}}/release.json", "", "stable")
+
+		assert.Error(t, err)
+		assert.Nil(t, fetcher)
+	})
+}
+
+func Test_HTTPManifestFetcher_FetchManifest(t *testing.T) {
+	t.Run("should fetch and verify the manifest from the configured urls", func(t *testing.T) {
+		originalDownloader := downloader.DownloadToTemporaryFile
+		defer func() {
+			downloader.DownloadToTemporaryFile = originalDownloader
+		}()
+
+		manifestURL := "https://artifacts.acme.internal/widget/release.json"
+		sigURL := "https://artifacts.acme.internal/widget/release.json.sig"
+
+		downloader.DownloadToTemporaryFile = func(dctx context.Context, url, pattern string) (*downloader.File, error) {
+			var contents []byte
+			switch url {
+			case manifestURL:
+				contents = fixtures.ReleaseFixture
+			case sigURL:
+				contents = fixtures.ReleaseSignatureFixture
+			default:
+				t.Fatalf("unexpected url: %s", url)
+			}
+
+			path := t.TempDir() + "/download"
+			if err := os.WriteFile(path, contents, 0o600); err != nil {
+				t.Fatalf("failed to write download: %v", err)
+			}
+
+			f, err := os.Open(path)
+			return &downloader.File{File: f}, err
+		}
+
+		fetcher := &HTTPManifestFetcher{
+			ApplicationMeta: models.ApplicationMeta{AuthorsPublicKey: assets.PublicKeyPEM},
+			Logger:          logger.New(true),
+			ManifestURL:     manifestURL,
+			SignatureURL:    sigURL,
+		}
+
+		got, err := fetcher.FetchManifest(context.Background())
+		assert.NoError(t, err)
+		assert.Equal(t, "v1.2.3", got.Latest)
+	})
+}
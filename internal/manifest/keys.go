@@ -0,0 +1,34 @@
+package manifest
+
+import (
+	"context"
+
+	"github.com/danilevy1212/self-updater/internal/audit"
+	"github.com/danilevy1212/self-updater/internal/models"
+)
+
+type ctxKeyKeyRing struct{}
+
+var keyRingKey = ctxKeyKeyRing{}
+
+// SetManifestFetcherKeyRing makes the manifest fetchers created with ctx verify signatures with keys,
+// and apply the key rotations of the manifests they fetch to it.
+func SetManifestFetcherKeyRing(ctx context.Context, keys *audit.KeyRing) context.Context {
+	return context.WithValue(ctx, keyRingKey, keys)
+}
+
+// getKeyRing returns the key ring set on ctx, or without one a key ring of the fetcher's own trusting the
+// keys embedded in the application.
+func getKeyRing(ctx context.Context, applicationMeta models.ApplicationMeta) *audit.KeyRing {
+	k, _ := ctx.Value(keyRingKey).(*audit.KeyRing)
+	return trustedKeys(k, applicationMeta)
+}
+
+// trustedKeys returns keys, or without a key ring one trusting only the keys embedded in the application.
+func trustedKeys(keys *audit.KeyRing, applicationMeta models.ApplicationMeta) *audit.KeyRing {
+	if keys != nil {
+		return keys
+	}
+
+	return audit.NewKeyRing(applicationMeta.AuthorsPublicKey, models.KeyRingState{}, nil)
+}
@@ -0,0 +1,118 @@
+package manifest
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/danilevy1212/self-updater/internal/audit"
+	"github.com/danilevy1212/self-updater/internal/logger"
+	"github.com/danilevy1212/self-updater/internal/models"
+)
+
+type testKey struct {
+	publicKeyPEM string
+	id           string
+	privateKey   ed25519.PrivateKey
+}
+
+func newTestKey(t *testing.T) testKey {
+	t.Helper()
+
+	publicKey, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	assert.NoError(t, err)
+	der, err := x509.MarshalPKIXPublicKey(publicKey)
+	assert.NoError(t, err)
+
+	publicKeyPEM := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})
+	id, err := audit.KeyID(publicKeyPEM)
+	assert.NoError(t, err)
+
+	return testKey{publicKeyPEM: string(publicKeyPEM), id: id, privateKey: privateKey}
+}
+
+// sign signs data's digest like cmd/sign.
+func (k testKey) sign(data []byte) string {
+	digest := sha256.Sum256(data)
+	return audit.Envelope(k.id, base64.StdEncoding.EncodeToString(ed25519.Sign(k.privateKey, digest[:])))
+}
+
+func Test_DirectoryManifestFetcher_keyRotation(t *testing.T) {
+	oldKey := newTestKey(t)
+	newKey := newTestKey(t)
+
+	rotation, err := json.Marshal(models.KeyRotation{Version: 1, Keys: []string{newKey.publicKeyPEM}, Revoked: []string{oldKey.id}})
+	assert.NoError(t, err)
+
+	setup := func(t *testing.T, signer testKey, state models.KeyRingState, save func(models.KeyRingState) error) (*DirectoryManifestFetcher, *audit.KeyRing) {
+		dir := t.TempDir()
+		data, err := json.Marshal(models.ReleaseManifest{
+			Latest:    "v1.2.3",
+			PublicKey: signer.publicKeyPEM,
+			KeyRotations: []models.SignedKeyRotation{{
+				Document:  base64.StdEncoding.EncodeToString(rotation),
+				Signature: oldKey.sign(rotation),
+			}},
+		})
+		assert.NoError(t, err)
+		assert.NoError(t, os.WriteFile(filepath.Join(dir, manifestFileName), data, 0o600))
+		assert.NoError(t, os.WriteFile(filepath.Join(dir, manifestSignatureFileName), []byte(signer.sign(data)+"\n"), 0o600))
+
+		keys := audit.NewKeyRing([]byte(oldKey.publicKeyPEM), state, save)
+		ctx := SetManifestFetcherLogger(context.Background(), logger.New(true))
+		ctx = SetManifestFetcherKeyRing(ctx, keys)
+		fetcher, err := NewDirectoryManifestFetcher(ctx, models.ApplicationMeta{AuthorsPublicKey: []byte(oldKey.publicKeyPEM)}, dir)
+		assert.NoError(t, err)
+
+		return fetcher, keys
+	}
+
+	t.Run("should trust a manifest signed by the key its rotations introduce", func(t *testing.T) {
+		var saved []models.KeyRingState
+		fetcher, keys := setup(t, newKey, models.KeyRingState{}, func(state models.KeyRingState) error {
+			saved = append(saved, state)
+			return nil
+		})
+
+		got, err := fetcher.FetchManifest(context.Background())
+		assert.NoError(t, err)
+		assert.Equal(t, "v1.2.3", got.Latest)
+		assert.True(t, keys.Trusts(got.PublicKey))
+		assert.False(t, keys.Trusts(oldKey.publicKeyPEM))
+		assert.Equal(t, []models.KeyRingState{keys.State()}, saved)
+	})
+
+	t.Run("should keep the trusted keys when a manifest with valid rotations has a bad signature", func(t *testing.T) {
+		var saved []models.KeyRingState
+		fetcher, keys := setup(t, newTestKey(t), models.KeyRingState{}, func(state models.KeyRingState) error {
+			saved = append(saved, state)
+			return nil
+		})
+
+		got, err := fetcher.FetchManifest(context.Background())
+		assert.Error(t, err)
+		assert.Nil(t, got)
+		assert.Empty(t, saved)
+		assert.Zero(t, keys.State().Version)
+		assert.True(t, keys.Trusts(oldKey.publicKeyPEM))
+		assert.False(t, keys.Trusts(newKey.publicKeyPEM))
+	})
+
+	t.Run("should refuse a manifest signed by a revoked key", func(t *testing.T) {
+		fetcher, _ := setup(t, oldKey, models.KeyRingState{Version: 1, Keys: []string{newKey.publicKeyPEM}, Revoked: []string{oldKey.id}}, nil)
+
+		got, err := fetcher.FetchManifest(context.Background())
+		assert.ErrorContains(t, err, "signature key "+oldKey.id+" is not trusted")
+		assert.Nil(t, got)
+	})
+}
@@ -0,0 +1,152 @@
+package manifest
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/rs/zerolog"
+
+	"github.com/danilevy1212/self-updater/internal/audit"
+	"github.com/danilevy1212/self-updater/internal/downloader"
+	"github.com/danilevy1212/self-updater/internal/models"
+	"github.com/danilevy1212/self-updater/internal/oci"
+)
+
+// OCIManifestFetcher reads releases published as OCI artifacts, e.g. with
+// `oras push registry/repo:stable release.json release.json.sig.base64 api-*`.
+// Each file is a layer titled after its name, artifacts are pulled by the digest in the manifest.
+type OCIManifestFetcher struct {
+	ApplicationMeta models.ApplicationMeta
+	Logger          *zerolog.Logger
+	Client          *oci.Client
+	// Tag of the artifact holding the latest release.json
+	Tag string
+	// Freshness of fetched manifests isn't checked when nil
+	Freshness *Freshness
+	// Keys trusted to sign manifests, only the embedded keys when nil
+	Keys *audit.KeyRing
+}
+
+func NewOCIManifestFetcher(ctx context.Context, applicationMeta models.ApplicationMeta, client *oci.Client, tag string) (*OCIManifestFetcher, error) {
+	logger, err := getLogger(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("missing a logger: %w", err)
+	}
+
+	if logger == nil {
+		return nil, errors.New("logger cannot be nil")
+	}
+
+	if client == nil {
+		return nil, errors.New("oci client cannot be nil")
+	}
+
+	if tag == "" {
+		return nil, errors.New("tag cannot be empty")
+	}
+
+	return &OCIManifestFetcher{
+		ApplicationMeta: applicationMeta,
+		Logger:          logger,
+		Client:          client,
+		Tag:             tag,
+		Freshness:       getFreshness(ctx),
+		Keys:            getKeyRing(ctx, applicationMeta),
+	}, nil
+}
+
+func (of *OCIManifestFetcher) FetchManifest(ctx context.Context) (*models.ReleaseManifest, error) {
+	logger := of.Logger.With().
+		Str("registry", of.Client.Registry).
+		Str("repository", of.Client.Repository).
+		Str("tag", of.Tag).
+		Logger()
+
+	logger.Info().
+		Msg("Resolving OCI artifact")
+
+	artifact, err := of.Client.GetManifest(ctx, of.Tag)
+	if err != nil {
+		logger.Error().
+			Err(err).
+			Msg("Failed to resolve OCI artifact")
+
+		return nil, fmt.Errorf("failed to resolve tag `%s`: %w", of.Tag, err)
+	}
+
+	manifestLayer, err := artifact.LayerByTitle(manifestFileName)
+	if err != nil {
+		return nil, err
+	}
+
+	signatureLayer, err := artifact.LayerByTitle(manifestSignatureFileName)
+	if err != nil {
+		return nil, err
+	}
+
+	manifestURL := of.Client.BlobURL(manifestLayer.Digest)
+	signatureURL := of.Client.BlobURL(signatureLayer.Digest)
+
+	logger.Info().
+		Str("release_json_url", manifestURL).
+		Str("release_json_signature_url", signatureURL).
+		Msg("Fetching manifest from OCI registry")
+
+	keys := trustedKeys(of.Keys, of.ApplicationMeta)
+	return fetchFresh(ctx, &logger, of.Freshness, keys,
+		func(ctx context.Context, keys *audit.KeyRing) (*models.Timestamp, error) {
+			timestampLayer, err := artifact.LayerByTitle(timestampFileName)
+			if err != nil {
+				return nil, err
+			}
+			timestampSignatureLayer, err := artifact.LayerByTitle(timestampSignatureFileName)
+			if err != nil {
+				return nil, err
+			}
+
+			timestampURL := of.Client.BlobURL(timestampLayer.Digest)
+			timestampSignatureURL := of.Client.BlobURL(timestampSignatureLayer.Digest)
+
+			return fetchSignedTimestamp(ctx, &logger, keys, timestampURL, timestampSignatureURL, of.download)
+		},
+		func(ctx context.Context, keys *audit.KeyRing) (*models.ReleaseManifest, string, error) {
+			return fetchSignedManifest(ctx, &logger, keys, manifestURL, signatureURL, of.download)
+		},
+	)
+}
+
+// FetchArtifact pulls the artifact blob by its digest, the artifact URL is not used.
+func (of *OCIManifestFetcher) FetchArtifact(ctx context.Context, artifact *models.Artifact) (*downloader.File, error) {
+	return of.download(ctx, of.Client.BlobURL("sha256:"+artifact.DownloadDigest()), artifact.Filename+".*")
+}
+
+// download pulls a blob, making sure its content matches the digest it was addressed by.
+func (of *OCIManifestFetcher) download(ctx context.Context, blobURL, pattern string) (*downloader.File, error) {
+	_, expected, ok := strings.Cut(blobURL, "/blobs/sha256:")
+	if !ok {
+		return nil, fmt.Errorf("unsupported blob url `%s`, only sha256 digests are supported", blobURL)
+	}
+
+	req, err := of.Client.NewRequest(ctx, blobURL)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := downloader.DownloadRequestToTemporaryFile(req, pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	if actual := hex.EncodeToString(f.DownloadSHA256()); actual != expected {
+		_ = f.Close()
+		_ = os.Remove(f.Name())
+
+		return nil, fmt.Errorf("blob digest sha256:%s does not match sha256:%s", actual, expected)
+	}
+
+	return f, nil
+}
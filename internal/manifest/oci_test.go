@@ -0,0 +1,124 @@
+package manifest
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/danilevy1212/self-updater/internal/assets"
+	"github.com/danilevy1212/self-updater/internal/logger"
+	"github.com/danilevy1212/self-updater/internal/models"
+	"github.com/danilevy1212/self-updater/internal/models/fixtures"
+	"github.com/danilevy1212/self-updater/internal/oci"
+)
+
+func sha256Hex(b []byte) string {
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+// newRegistryStandIn serves files as the layers of an artifact tagged `stable`, the way `oras push` publishes them.
+// Blobs are served from the digests registered in it, tamper lets a blob be served with other contents.
+func newRegistryStandIn(t *testing.T, files map[string][]byte, tamper map[string][]byte) *httptest.Server {
+	blobs := map[string][]byte{}
+	artifact := oci.Manifest{SchemaVersion: 2, MediaType: oci.MediaTypeImageManifest}
+	for title, contents := range files {
+		d := "sha256:" + sha256Hex(contents)
+		blobs[d] = contents
+		if tampered, ok := tamper[title]; ok {
+			blobs[d] = tampered
+		}
+
+		artifact.Layers = append(artifact.Layers, oci.Descriptor{
+			MediaType:   "application/octet-stream",
+			Digest:      d,
+			Size:        int64(len(contents)),
+			Annotations: map[string]string{oci.AnnotationTitle: title},
+		})
+	}
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/v2/":
+			w.WriteHeader(http.StatusOK)
+		case r.URL.Path == "/v2/platform/self-updater/manifests/stable":
+			w.Header().Set("Content-Type", oci.MediaTypeImageManifest)
+			_ = json.NewEncoder(w).Encode(artifact)
+		case strings.HasPrefix(r.URL.Path, "/v2/platform/self-updater/blobs/"):
+			contents, ok := blobs[strings.TrimPrefix(r.URL.Path, "/v2/platform/self-updater/blobs/")]
+			if !ok {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			_, _ = w.Write(contents)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(ts.Close)
+
+	return ts
+}
+
+func newOCIFixture(t *testing.T, tamper map[string][]byte) *OCIManifestFetcher {
+	ts := newRegistryStandIn(t, map[string][]byte{
+		"release.json":            fixtures.ReleaseFixture,
+		"release.json.sig.base64": fixtures.ReleaseSignatureFixture,
+		"api-linux-amd64":         []byte("new binary"),
+	}, tamper)
+
+	client, err := oci.NewClient(strings.TrimPrefix(ts.URL, "http://"), "platform/self-updater", "", "", true)
+	assert.NoError(t, err)
+
+	ctx := SetManifestFetcherLogger(context.Background(), logger.New(true))
+	fetcher, err := NewOCIManifestFetcher(ctx, models.ApplicationMeta{AuthorsPublicKey: assets.PublicKeyPEM}, client, "stable")
+	assert.NoError(t, err)
+
+	return fetcher
+}
+
+func Test_OCIManifestFetcher_FetchManifest(t *testing.T) {
+	t.Run("should resolve the tag and verify the manifest", func(t *testing.T) {
+		fetcher := newOCIFixture(t, nil)
+
+		got, err := fetcher.FetchManifest(context.Background())
+		assert.NoError(t, err)
+		assert.Equal(t, "v1.2.3", got.Latest)
+	})
+
+	t.Run("should reject blobs that don't match their digest", func(t *testing.T) {
+		fetcher := newOCIFixture(t, map[string][]byte{"release.json": []byte(`{"latest":"v9.9.9"}`)})
+
+		_, err := fetcher.FetchManifest(context.Background())
+		assert.ErrorContains(t, err, "does not match")
+	})
+}
+
+func Test_OCIManifestFetcher_FetchArtifact(t *testing.T) {
+	t.Run("should pull the artifact by its digest", func(t *testing.T) {
+		fetcher := newOCIFixture(t, nil)
+
+		f, err := fetcher.FetchArtifact(context.Background(), &models.Artifact{
+			Filename: "api-linux-amd64",
+			Digest:   sha256Hex([]byte("new binary")),
+		})
+		assert.NoError(t, err)
+		defer func() {
+			_ = f.Close()
+			_ = os.Remove(f.Name())
+		}()
+
+		contents, err := io.ReadAll(f)
+		assert.NoError(t, err)
+		assert.Equal(t, "new binary", string(contents))
+	})
+}
@@ -2,10 +2,38 @@ package manifest
 
 import (
 	"context"
+	"errors"
 
+	"github.com/danilevy1212/self-updater/internal/downloader"
 	"github.com/danilevy1212/self-updater/internal/models"
 )
 
 type ManifestFetcher interface {
 	FetchManifest(ctx context.Context) (*models.ReleaseManifest, error)
 }
+
+// ArtifactFetcher is implemented by manifest fetchers that also know where their artifacts live,
+// e.g. next to the manifest on a mounted volume. Others get their artifacts downloaded from `artifact.URL`.
+type ArtifactFetcher interface {
+	FetchArtifact(ctx context.Context, artifact *models.Artifact) (*downloader.File, error)
+}
+
+type ctxKeyDownloadDirectory struct{}
+
+var downloadDirectoryKey = ctxKeyDownloadDirectory{}
+
+// SetManifestFetcherDownloadDirectory sets where the manifest fetchers created with ctx keep partial artifact
+// downloads, to resume them later.
+func SetManifestFetcherDownloadDirectory(ctx context.Context, dir string) context.Context {
+	return context.WithValue(ctx, downloadDirectoryKey, dir)
+}
+
+// getDownloadDirectory returns the download directory set on ctx, artifact downloads aren't resumed without one.
+func getDownloadDirectory(ctx context.Context) string {
+	dir, _ := ctx.Value(downloadDirectoryKey).(string)
+	return dir
+}
+
+// ErrDownloadArtifact is returned by FetchArtifact for artifacts at a plain HTTP(S) URL, which are
+// downloaded like those of any other source, resuming interrupted downloads.
+var ErrDownloadArtifact = errors.New("artifact is downloaded from its url")
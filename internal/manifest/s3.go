@@ -0,0 +1,151 @@
+package manifest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+
+	"github.com/rs/zerolog"
+
+	"github.com/danilevy1212/self-updater/internal/audit"
+	"github.com/danilevy1212/self-updater/internal/downloader"
+	"github.com/danilevy1212/self-updater/internal/models"
+	"github.com/danilevy1212/self-updater/internal/s3"
+)
+
+// S3ManifestFetcher reads releases from an S3-compatible bucket, signing requests so that the bucket can stay private.
+type S3ManifestFetcher struct {
+	ApplicationMeta models.ApplicationMeta
+	Logger          *zerolog.Logger
+	Client          *s3.Client
+	Bucket          string
+	// Key prefix of release.json and its signature, e.g. `self-updater/`
+	Prefix string
+	// Freshness of fetched manifests isn't checked when nil
+	Freshness *Freshness
+	// Keys trusted to sign manifests, only the embedded keys when nil
+	Keys *audit.KeyRing
+	// Partial artifact downloads are kept here and resumed, artifacts are downloaded in one go when empty
+	DownloadDirectory string
+}
+
+func NewS3ManifestFetcher(ctx context.Context, applicationMeta models.ApplicationMeta, client *s3.Client, bucket, prefix string) (*S3ManifestFetcher, error) {
+	logger, err := getLogger(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("missing a logger: %w", err)
+	}
+
+	if logger == nil {
+		return nil, errors.New("logger cannot be nil")
+	}
+
+	if client == nil {
+		return nil, errors.New("s3 client cannot be nil")
+	}
+
+	if bucket == "" {
+		return nil, errors.New("bucket cannot be empty")
+	}
+
+	return &S3ManifestFetcher{
+		ApplicationMeta:   applicationMeta,
+		Logger:            logger,
+		Client:            client,
+		Bucket:            bucket,
+		Prefix:            prefix,
+		Freshness:         getFreshness(ctx),
+		Keys:              getKeyRing(ctx, applicationMeta),
+		DownloadDirectory: getDownloadDirectory(ctx),
+	}, nil
+}
+
+func (sf *S3ManifestFetcher) key(name string) string {
+	return path.Join(sf.Prefix, name)
+}
+
+func (sf *S3ManifestFetcher) FetchManifest(ctx context.Context) (*models.ReleaseManifest, error) {
+	logger := sf.Logger
+
+	manifestURL := s3URL(sf.Bucket, sf.key(manifestFileName))
+	signatureURL := s3URL(sf.Bucket, sf.key(manifestSignatureFileName))
+
+	logger.Info().
+		Str("release_json_url", manifestURL).
+		Str("release_json_signature_url", signatureURL).
+		Str("endpoint", sf.Client.Endpoint.String()).
+		Msg("Fetching manifest from S3")
+
+	keys := trustedKeys(sf.Keys, sf.ApplicationMeta)
+	return fetchFresh(ctx, logger, sf.Freshness, keys,
+		func(ctx context.Context, keys *audit.KeyRing) (*models.Timestamp, error) {
+			timestampURL := s3URL(sf.Bucket, sf.key(timestampFileName))
+			timestampSignatureURL := s3URL(sf.Bucket, sf.key(timestampSignatureFileName))
+
+			return fetchSignedTimestamp(ctx, logger, keys, timestampURL, timestampSignatureURL, sf.download)
+		},
+		func(ctx context.Context, keys *audit.KeyRing) (*models.ReleaseManifest, string, error) {
+			return fetchSignedManifest(ctx, logger, keys, manifestURL, signatureURL, sf.download)
+		},
+	)
+}
+
+// FetchArtifact downloads artifacts from the bucket with signed requests, resuming interrupted downloads
+// like those of any other source. `artifact.URL` may be an `s3://bucket/key` URL or a key relative to the
+// prefix, HTTP(S) URLs are left to the caller to download, see ErrDownloadArtifact.
+func (sf *S3ManifestFetcher) FetchArtifact(ctx context.Context, artifact *models.Artifact) (*downloader.File, error) {
+	u, err := url.Parse(artifact.URL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid artifact url `%s`: %w", artifact.URL, err)
+	}
+
+	switch u.Scheme {
+	case "http", "https":
+		return nil, ErrDownloadArtifact
+	case "s3":
+		return sf.downloadArtifact(ctx, artifact.URL, artifact.Filename+".*")
+	case "":
+		return sf.downloadArtifact(ctx, s3URL(sf.Bucket, sf.key(u.Path)), artifact.Filename+".*")
+	default:
+		return nil, fmt.Errorf("unsupported artifact url scheme `%s`", u.Scheme)
+	}
+}
+
+// download fetches an `s3://bucket/key` URL through a signed request.
+func (sf *S3ManifestFetcher) download(ctx context.Context, rawURL, pattern string) (*downloader.File, error) {
+	bucket, key, err := s3.ParseURL(rawURL)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := sf.Client.NewGetObjectRequest(ctx, bucket, key)
+	if err != nil {
+		return nil, err
+	}
+
+	return downloader.DownloadRequestToTemporaryFile(req, pattern)
+}
+
+// downloadArtifact resumes partial downloads left in the download directory by earlier runs, signing the
+// Range of each attempt along with the rest of its request.
+func (sf *S3ManifestFetcher) downloadArtifact(ctx context.Context, rawURL, pattern string) (*downloader.File, error) {
+	if sf.DownloadDirectory == "" {
+		return sf.download(ctx, rawURL, pattern)
+	}
+
+	bucket, key, err := s3.ParseURL(rawURL)
+	if err != nil {
+		return nil, err
+	}
+
+	return downloader.DownloadRequestResumable(ctx, sf.DownloadDirectory, rawURL, func(ctx context.Context, header http.Header) (*http.Request, error) {
+		return sf.Client.NewGetObjectRequestWithHeader(ctx, bucket, key, header)
+	}, pattern)
+}
+
+func s3URL(bucket, key string) string {
+	return "s3://" + bucket + "/" + strings.TrimPrefix(key, "/")
+}
@@ -0,0 +1,202 @@
+package manifest
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/danilevy1212/self-updater/internal/assets"
+	"github.com/danilevy1212/self-updater/internal/logger"
+	"github.com/danilevy1212/self-updater/internal/models"
+	"github.com/danilevy1212/self-updater/internal/models/fixtures"
+	"github.com/danilevy1212/self-updater/internal/s3"
+)
+
+var minioCredentials = s3.Credentials{
+	AccessKeyID:     "minioadmin",
+	SecretAccessKey: "minioadmin-secret",
+}
+
+// newMinioStandIn serves a private bucket with path-style addressing, like MinIO does, and rejects
+// requests whose signature doesn't match the one computed from what went over the wire.
+func newMinioStandIn(t *testing.T, bucket string, objects map[string][]byte) *httptest.Server {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !validSignature(r) {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+
+		key, ok := strings.CutPrefix(r.URL.Path, "/"+bucket+"/")
+		contents, found := objects[key]
+		if !ok || !found {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		_, _ = w.Write(contents)
+	}))
+	t.Cleanup(ts.Close)
+
+	return ts
+}
+
+func validSignature(r *http.Request) bool {
+	authorization := r.Header.Get("Authorization")
+	_, signedHeaders, ok := strings.Cut(authorization, "SignedHeaders=")
+	if !ok {
+		return false
+	}
+	signedHeaders, _, _ = strings.Cut(signedHeaders, ",")
+
+	signedAt, err := time.Parse("20060102T150405Z", r.Header.Get("X-Amz-Date"))
+	if err != nil {
+		return false
+	}
+
+	replay, err := http.NewRequest(r.Method, "http://"+r.Host+r.URL.RequestURI(), nil)
+	if err != nil {
+		return false
+	}
+	for _, name := range strings.Split(signedHeaders, ";") {
+		if name != "host" {
+			replay.Header.Set(name, r.Header.Get(name))
+		}
+	}
+	s3.Sign(replay, minioCredentials, "us-east-1", signedAt)
+
+	return replay.Header.Get("Authorization") == authorization
+}
+
+func newS3Fixture(t *testing.T, creds s3.Credentials) *S3ManifestFetcher {
+	ts := newMinioStandIn(t, "releases", map[string][]byte{
+		"self-updater/release.json":            fixtures.ReleaseFixture,
+		"self-updater/release.json.sig.base64": fixtures.ReleaseSignatureFixture,
+		"self-updater/v1.2.3/api-linux-amd64":  []byte("new binary"),
+	})
+
+	client, err := s3.NewClient(ts.URL, "us-east-1", true, creds)
+	assert.NoError(t, err)
+
+	ctx := SetManifestFetcherLogger(context.Background(), logger.New(true))
+	fetcher, err := NewS3ManifestFetcher(ctx, models.ApplicationMeta{AuthorsPublicKey: assets.PublicKeyPEM}, client, "releases", "self-updater")
+	assert.NoError(t, err)
+
+	return fetcher
+}
+
+func Test_S3ManifestFetcher_FetchManifest(t *testing.T) {
+	t.Run("should fetch and verify the manifest from a private bucket", func(t *testing.T) {
+		fetcher := newS3Fixture(t, minioCredentials)
+
+		got, err := fetcher.FetchManifest(context.Background())
+		assert.NoError(t, err)
+		assert.Equal(t, "v1.2.3", got.Latest)
+	})
+
+	t.Run("should fail with the wrong credentials", func(t *testing.T) {
+		fetcher := newS3Fixture(t, s3.Credentials{
+			AccessKeyID:     "minioadmin",
+			SecretAccessKey: "wrong",
+		})
+
+		_, err := fetcher.FetchManifest(context.Background())
+		assert.ErrorContains(t, err, "unexpected status code: 403")
+	})
+}
+
+func Test_S3ManifestFetcher_FetchArtifact(t *testing.T) {
+	fetcher := newS3Fixture(t, minioCredentials)
+
+	for name, artifactURL := range map[string]string{
+		"key relative to the prefix": "v1.2.3/api-linux-amd64",
+		"s3 url":                     "s3://releases/self-updater/v1.2.3/api-linux-amd64",
+	} {
+		t.Run("should download the artifact from a "+name, func(t *testing.T) {
+			f, err := fetcher.FetchArtifact(context.Background(), &models.Artifact{
+				Filename: "api-linux-amd64",
+				URL:      artifactURL,
+			})
+			assert.NoError(t, err)
+			defer func() {
+				_ = f.Close()
+				_ = os.Remove(f.Name())
+			}()
+
+			contents, err := io.ReadAll(f)
+			assert.NoError(t, err)
+			assert.Equal(t, "new binary", string(contents))
+		})
+	}
+
+	t.Run("should resume an interrupted download with a signed range request", func(t *testing.T) {
+		contents := []byte(strings.Repeat("new binary ", 100))
+		modified := time.Date(2025, 3, 28, 12, 0, 0, 0, time.UTC)
+
+		var ranges []string
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			// A proxy could otherwise change what part of the artifact is served.
+			isRangeSigned := strings.Contains(r.Header.Get("Authorization"), ";range;")
+			if !validSignature(r) || (r.Header.Get("Range") != "" && !isRangeSigned) {
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+			ranges = append(ranges, r.Header.Get("Range"))
+
+			if len(ranges) == 1 {
+				// Cut the first attempt short, half way through the artifact.
+				w.Header().Set("Last-Modified", modified.Format(http.TimeFormat))
+				w.Header().Set("Content-Length", strconv.Itoa(len(contents)))
+				_, _ = w.Write(contents[:len(contents)/2])
+				w.(http.Flusher).Flush()
+				panic(http.ErrAbortHandler)
+			}
+
+			http.ServeContent(w, r, "api-linux-amd64", modified, bytes.NewReader(contents))
+		}))
+		t.Cleanup(ts.Close)
+
+		client, err := s3.NewClient(ts.URL, "us-east-1", true, minioCredentials)
+		assert.NoError(t, err)
+		fetcher := &S3ManifestFetcher{
+			Logger:            logger.New(true),
+			Client:            client,
+			Bucket:            "releases",
+			Prefix:            "self-updater",
+			DownloadDirectory: t.TempDir(),
+		}
+		artifact := &models.Artifact{Filename: "api-linux-amd64", URL: "v1.2.3/api-linux-amd64"}
+
+		_, err = fetcher.FetchArtifact(context.Background(), artifact)
+		assert.Error(t, err)
+
+		f, err := fetcher.FetchArtifact(context.Background(), artifact)
+		assert.NoError(t, err)
+		defer func() {
+			_ = f.Close()
+			_ = os.Remove(f.Name())
+		}()
+
+		got, err := io.ReadAll(f)
+		assert.NoError(t, err)
+		assert.Equal(t, contents, got)
+		assert.Equal(t, []string{"", fmt.Sprintf("bytes=%d-", len(contents)/2)}, ranges)
+	})
+
+	t.Run("should leave http urls to be downloaded", func(t *testing.T) {
+		_, err := fetcher.FetchArtifact(context.Background(), &models.Artifact{
+			Filename: "api-linux-amd64",
+			URL:      "https://releases.acme.internal/api-linux-amd64",
+		})
+		assert.ErrorIs(t, err, ErrDownloadArtifact)
+	})
+}
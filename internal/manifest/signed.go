@@ -0,0 +1,205 @@
+package manifest
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/rs/zerolog"
+
+	"github.com/danilevy1212/self-updater/internal/audit"
+	"github.com/danilevy1212/self-updater/internal/digest"
+	"github.com/danilevy1212/self-updater/internal/downloader"
+	"github.com/danilevy1212/self-updater/internal/models"
+)
+
+// downloadFunc downloads a URL to a temporary file, e.g. through a signed request.
+type downloadFunc func(ctx context.Context, url, pattern string) (*downloader.File, error)
+
+func downloadURL(ctx context.Context, url, pattern string) (*downloader.File, error) {
+	return downloader.DownloadToTemporaryFile(ctx, url, pattern)
+}
+
+// fetchSignedManifest downloads a manifest and its detached signature, and only returns the manifest
+// once the signature proves it came from the authors. Returns the manifest with the hex digest of its file.
+func fetchSignedManifest(
+	ctx context.Context,
+	logger *zerolog.Logger,
+	keys *audit.KeyRing,
+	releaseJSONURL, releaseJSONSignatureURL string,
+	download downloadFunc,
+) (*models.ReleaseManifest, string, error) {
+	return fetchSigned[models.ReleaseManifest](ctx, logger, "manifest", manifestFileName, keys, releaseJSONURL, releaseJSONSignatureURL, download)
+}
+
+// fetchSignedTimestamp downloads a timestamp and its detached signature, like fetchSignedManifest.
+func fetchSignedTimestamp(
+	ctx context.Context,
+	logger *zerolog.Logger,
+	keys *audit.KeyRing,
+	timestampURL, timestampSignatureURL string,
+	download downloadFunc,
+) (*models.Timestamp, error) {
+	timestamp, _, err := fetchSigned[models.Timestamp](ctx, logger, "timestamp", timestampFileName, keys, timestampURL, timestampSignatureURL, download)
+	return timestamp, err
+}
+
+// fetchSigned downloads a metadata file, named after kind in logs and errors, and its detached signature.
+// The downloads are named after fileName.
+func fetchSigned[T any](
+	ctx context.Context,
+	logger *zerolog.Logger,
+	kind, fileName string,
+	keys *audit.KeyRing,
+	fileURL, signatureURL string,
+	download downloadFunc,
+) (*T, string, error) {
+	var (
+		wg                    sync.WaitGroup
+		manifestFile, sigFile *downloader.File
+		manifestErr, sigErr   error
+	)
+
+	// Fetch both files in parallel, cancel all if one fails
+	downloadCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		manifestFile, manifestErr = download(downloadCtx, fileURL, fileName+".*")
+		if manifestErr != nil {
+			cancel()
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		sigFile, sigErr = download(downloadCtx, signatureURL, fileName+".sig.base64.*")
+		if sigErr != nil {
+			cancel()
+		}
+	}()
+	logger.Info().
+		Msgf("Waiting for %s and signature downloads to complete", kind)
+	wg.Wait()
+
+	if manifestErr != nil || sigErr != nil {
+		errs := []error{}
+
+		if manifestErr != nil {
+			logger.Error().
+				Err(manifestErr).
+				Msgf("Failed to download %s file", kind)
+
+			errs = append(errs, manifestErr)
+		}
+
+		if sigErr != nil {
+			logger.Error().
+				Err(sigErr).
+				Msgf("Failed to download %s signature file", kind)
+
+			errs = append(errs, sigErr)
+		}
+
+		if manifestFile != nil {
+			_ = manifestFile.Close()
+			_ = os.Remove(manifestFile.Name())
+		}
+
+		if sigFile != nil {
+			_ = sigFile.Close()
+			_ = os.Remove(sigFile.Name())
+		}
+
+		return nil, "", fmt.Errorf("failed to download %s files: %w", kind, errors.Join(errs...))
+	}
+
+	defer func() {
+		_ = sigFile.Close()
+		_ = os.Remove(sigFile.Name())
+		_ = manifestFile.Close()
+		_ = os.Remove(manifestFile.Name())
+	}()
+
+	return verifySigned[T](logger, kind, keys, manifestFile.File, sigFile.File)
+}
+
+// verifyManifest decodes the manifest once its detached signature proves it came from the authors.
+// Returns the manifest with the hex digest of its file.
+func verifyManifest(logger *zerolog.Logger, keys *audit.KeyRing, manifestFile, sigFile *os.File) (*models.ReleaseManifest, string, error) {
+	return verifySigned[models.ReleaseManifest](logger, "manifest", keys, manifestFile, sigFile)
+}
+
+// verifySigned decodes a metadata file, named after kind in logs and errors, once its detached signature
+// proves it came from the authors. The key rotations of a manifest are applied to keys first, as it may
+// be signed by a key the installation doesn't trust yet.
+func verifySigned[T any](logger *zerolog.Logger, kind string, keys *audit.KeyRing, file, sigFile *os.File) (*T, string, error) {
+	sigFileContents, err := io.ReadAll(sigFile)
+	if err != nil {
+		logger.Error().
+			Err(err).
+			Msg("Failed to read signature file")
+
+		return nil, "", fmt.Errorf("failed to read signature file: %w", err)
+	}
+
+	digestRaw, err := digest.DigestFile(file.Name())
+	if err != nil {
+		logger.Error().
+			Err(err).
+			Msgf("Failed to compute %s file digest", kind)
+
+		return nil, "", fmt.Errorf("failed to compute %s file digest: %w", kind, err)
+	}
+	digestHex := hex.EncodeToString(digestRaw)
+
+	// Decoded before the signature is verified, an undecodable file is only reported once it is verified.
+	var result T
+	decodeErr := json.NewDecoder(file).Decode(&result)
+
+	// Every rotation is signed on its own, by a key trusted before it, so a forged manifest can't
+	// introduce keys. keys is a copy, only trusted once the manifest is verified, see fetchFresh.
+	if manifest, ok := any(&result).(*models.ReleaseManifest); ok && decodeErr == nil {
+		if err := keys.Rotate(logger, manifest.KeyRotations); err != nil {
+			logger.Error().
+				Err(err).
+				Msg("Failed to apply key rotations")
+
+			return nil, "", fmt.Errorf("failed to apply key rotations: %w", err)
+		}
+	}
+
+	// A little silly back-and-forth I have to do in the name of re-usability.
+	isVerified, err := audit.VerifySignature(keys.PEM(), digestHex, string(sigFileContents))
+	if err != nil {
+		logger.Error().
+			Err(err).
+			Msgf("Failed to verify %s signature", kind)
+
+		return nil, "", fmt.Errorf("failed to verify %s signature: %w", kind, err)
+	}
+
+	if !isVerified {
+		logger.Error().
+			Msgf("%s signature verification failed. Fetched %s did not come from authors", strings.ToUpper(kind[:1])+kind[1:], kind)
+
+		return nil, "", fmt.Errorf("%s signature verification failed: fetched %s did not come from authors", kind, kind)
+	}
+
+	if decodeErr != nil {
+		logger.Error().
+			Err(decodeErr).
+			Msgf("Failed to unmarshal %s JSON", kind)
+
+		return nil, "", fmt.Errorf("failed to unmarshal %s JSON: %w", kind, decodeErr)
+	}
+
+	return &result, digestHex, nil
+}
@@ -0,0 +1,96 @@
+package mirror
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/danilevy1212/self-updater/internal/httpclient"
+)
+
+// Strategy decides the order mirrors are tried in.
+type Strategy string
+
+const (
+	// StrategyOrdered tries mirrors in the order they are listed.
+	StrategyOrdered Strategy = "ordered"
+	// StrategyLatency tries the mirror that answered fastest first.
+	StrategyLatency Strategy = "latency"
+)
+
+// For testing sake
+type ProbeFunc func(ctx context.Context, url string) (time.Duration, error)
+
+var Probe ProbeFunc = defaultProbe
+
+// defaultProbe measures how long a mirror takes to answer a HEAD request for url.
+func defaultProbe(ctx context.Context, url string) (time.Duration, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, url, nil)
+	if err != nil {
+		return 0, fmt.Errorf("error building request: %w", err)
+	}
+
+	start := time.Now()
+	resp, err := httpclient.FromContext(ctx).Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("error making request: %w", err)
+	}
+	_ = resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		return 0, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+
+	return time.Since(start), nil
+}
+
+// Rank orders items by the given strategy, url tells where each item is probed.
+// With StrategyLatency, all items are probed in parallel until ctx is done,
+// and items that could not be probed keep their relative order after the ones that could.
+func Rank[T any](ctx context.Context, strategy Strategy, items []T, url func(T) string) []T {
+	ranked := make([]T, len(items))
+	copy(ranked, items)
+
+	if strategy != StrategyLatency || len(ranked) < 2 {
+		return ranked
+	}
+
+	latencies := make([]time.Duration, len(ranked))
+	var wg sync.WaitGroup
+	for i, item := range ranked {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			latency, err := Probe(ctx, url(item))
+			if err != nil {
+				latency = -1
+			}
+			latencies[i] = latency
+		}()
+	}
+	wg.Wait()
+
+	order := make([]int, len(ranked))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		la, lb := latencies[order[a]], latencies[order[b]]
+		if la < 0 || lb < 0 {
+			return lb < 0 && la >= 0
+		}
+
+		return la < lb
+	})
+
+	result := make([]T, len(ranked))
+	for i, idx := range order {
+		result[i] = ranked[idx]
+	}
+
+	return result
+}
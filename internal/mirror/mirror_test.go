@@ -0,0 +1,82 @@
+package mirror
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func identity(s string) string {
+	return s
+}
+
+func Test_Rank(t *testing.T) {
+	latencies := map[string]time.Duration{
+		"https://a.example.com": 300 * time.Millisecond,
+		"https://b.example.com": 100 * time.Millisecond,
+		"https://c.example.com": 200 * time.Millisecond,
+	}
+	mirrors := []string{"https://down.example.com", "https://a.example.com", "https://b.example.com", "https://c.example.com"}
+
+	stubProbe := func(t *testing.T) {
+		old := Probe
+		t.Cleanup(func() {
+			Probe = old
+		})
+		Probe = func(ctx context.Context, url string) (time.Duration, error) {
+			if latency, ok := latencies[url]; ok {
+				return latency, nil
+			}
+
+			return 0, errors.New("unreachable")
+		}
+	}
+
+	t.Run("should keep the configured order", func(t *testing.T) {
+		stubProbe(t)
+
+		got := Rank(context.Background(), StrategyOrdered, mirrors, identity)
+		assert.Equal(t, mirrors, got)
+	})
+
+	t.Run("should put the fastest mirrors first and unreachable ones last", func(t *testing.T) {
+		stubProbe(t)
+
+		got := Rank(context.Background(), StrategyLatency, mirrors, identity)
+		assert.Equal(t, []string{
+			"https://b.example.com",
+			"https://c.example.com",
+			"https://a.example.com",
+			"https://down.example.com",
+		}, got)
+	})
+}
+
+func Test_defaultProbe(t *testing.T) {
+	t.Run("should measure a mirror that answers", func(t *testing.T) {
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, http.MethodHead, r.Method)
+			w.WriteHeader(http.StatusOK)
+		}))
+		defer ts.Close()
+
+		latency, err := defaultProbe(context.Background(), ts.URL)
+		assert.NoError(t, err)
+		assert.Greater(t, latency, time.Duration(0))
+	})
+
+	t.Run("should fail for a mirror missing the file", func(t *testing.T) {
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusNotFound)
+		}))
+		defer ts.Close()
+
+		_, err := defaultProbe(context.Background(), ts.URL)
+		assert.Error(t, err)
+	})
+}
@@ -12,12 +12,15 @@ const (
 	SourceName  = "self-updater"
 	SourceOwner = "danilevy1212"
 	Host        = "github.com"
+	BaseURL     = "https://" + Host
 )
 
+// SourceInfo is where releases are published, the defaults can be overridden with the ARCHIVER_* variables.
 type SourceInfo struct {
-	Name  string
-	Owner string
-	Host  string
+	Name    string
+	Owner   string
+	Host    string
+	BaseURL string
 }
 
 type ApplicationMeta struct {
@@ -41,9 +44,10 @@ func NewApplicationMeta(digest []byte, version, commit, exePath string) Applicat
 		Arch:             runtime.GOARCH, // "amd64" or "arm64"
 		ExecutablePath:   exePath,
 		SourceInfo: SourceInfo{
-			Name:  SourceName,
-			Owner: SourceOwner,
-			Host:  Host,
+			Name:    SourceName,
+			Owner:   SourceOwner,
+			Host:    Host,
+			BaseURL: BaseURL,
 		},
 	}
 }
@@ -0,0 +1,15 @@
+package models
+
+import "time"
+
+// DownloadProgress describes the artifact the updater is downloading right now,
+// so that operators can follow slow downloads through the server.
+type DownloadProgress struct {
+	Version        string    `json:"version"`
+	URL            string    `json:"url"`
+	StartedAt      time.Time `json:"startedAt"`
+	BytesDone      int64     `json:"bytesDone"`
+	BytesTotal     int64     `json:"bytesTotal"`
+	BytesPerSecond float64   `json:"bytesPerSecond"`
+	ETASeconds     float64   `json:"etaSeconds"`
+}
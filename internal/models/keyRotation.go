@@ -0,0 +1,28 @@
+package models
+
+// KeyRotation replaces the keys the authors sign with. It is only trusted when signed by a key that was
+// trusted before it, so that installations can follow the authors from one key to the next.
+type KeyRotation struct {
+	// Raised with every rotation, installations apply rotations in order and never go back to an older one
+	Version int64 `json:"version"`
+	// PEM encoded public keys trusted once the rotation is applied, replacing the previous ones
+	Keys []string `json:"keys"`
+	// IDs of keys that must never be trusted again, e.g. because they leaked
+	Revoked []string `json:"revoked,omitempty"`
+}
+
+// SignedKeyRotation is a key rotation as published in the manifest.
+type SignedKeyRotation struct {
+	// Base64 encoded KeyRotation JSON, kept encoded so that the signed bytes are verified as is
+	Document string `json:"document"`
+	// Signature of the document's digest, in an envelope naming the key that made it
+	Signature string `json:"signature"`
+}
+
+// KeyRingState is the key set an installation trusts after the rotations it applied.
+type KeyRingState struct {
+	// Version of the last rotation applied, 0 while the embedded keys are trusted
+	Version int64    `json:"version,omitempty"`
+	Keys    []string `json:"keys,omitempty"`
+	Revoked []string `json:"revoked,omitempty"`
+}
@@ -1,26 +1,85 @@
 package models
 
-import "errors"
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+const (
+	ChannelStable  = "stable"
+	ChannelBeta    = "beta"
+	ChannelNightly = "nightly"
+)
 
 type ReleaseManifest struct {
-	Latest    string        `json:"latest"`
-	PublicKey string        `json:"publicKey"`
-	Versions  []ReleaseInfo `json:"versions"`
+	// Latest stable version, kept for clients that predate channels
+	Latest string `json:"latest"`
+	// Latest version per release channel
+	Channels  map[string]string `json:"channels,omitempty"`
+	PublicKey string            `json:"publicKey"`
+	Versions  []ReleaseInfo     `json:"versions"`
+	// Raised with every manifest published, installations refuse to go back to a lower one
+	MetadataVersion int64 `json:"metadataVersion,omitempty"`
+	// Installations refuse the manifest past this time, so that an old one can't be replayed forever
+	Expires time.Time `json:"expires,omitzero"`
+	// Every rotation of the signing keys, oldest first, so that installations can catch up from any key
+	KeyRotations []SignedKeyRotation `json:"keyRotations,omitempty"`
 }
 
 type ReleaseInfo struct {
 	Version   string     `json:"version"`
 	Commit    string     `json:"commit"`
 	Artifacts []Artifact `json:"artifacts"`
+	// Staged rollout of this version, everyone gets it when nil
+	Rollout *Rollout `json:"rollout,omitempty"`
+	// Critical releases are applied right away, even outside maintenance windows
+	Critical bool `json:"critical,omitempty"`
 }
 
 type Artifact struct {
-	OS              string `json:"os"`
-	Arch            string `json:"arch"`
-	Filename        string `json:"filename"`
+	OS       string `json:"os"`
+	Arch     string `json:"arch"`
+	Filename string `json:"filename"`
+	// Digest and signature of the executable, once decompressed
 	Digest          string `json:"digest"`
 	SignatureBase64 string `json:"signatureBase64"`
 	URL             string `json:"url"`
+	// How the file at URL is compressed, e.g. `gzip`. Uncompressed when empty.
+	Compression      string `json:"compression,omitempty"`
+	CompressedDigest string `json:"compressedDigest,omitempty"`
+	// Archive shipping the executable with companion files, `tar.gz` or `zip`. A bare executable when empty.
+	Bundle string `json:"bundle,omitempty"`
+	// Path of the executable within the bundle
+	Entrypoint string `json:"entrypoint,omitempty"`
+	// In bytes, downloads bigger than this are abandoned. Unchecked when zero.
+	Size int64 `json:"size,omitempty"`
+	// Tried in turn when URL fails, the digest and signature make any of them safe to use
+	Mirrors []string `json:"mirrors,omitempty"`
+	// Binary patches from earlier versions, much smaller to download than the artifact
+	Patches []Patch `json:"patches,omitempty"`
+}
+
+// Patch turns the artifact of version From into this artifact, see package delta.
+type Patch struct {
+	From            string `json:"from"`
+	URL             string `json:"url"`
+	Digest          string `json:"digest"`
+	SignatureBase64 string `json:"signatureBase64"`
+	Size            int64  `json:"size,omitempty"`
+}
+
+// LatestForChannel returns the latest version published to the given channel.
+func (rm *ReleaseManifest) LatestForChannel(channel string) (string, error) {
+	if v := rm.Channels[channel]; v != "" {
+		return v, nil
+	}
+
+	if channel == ChannelStable && rm.Latest != "" {
+		return rm.Latest, nil
+	}
+
+	return "", fmt.Errorf("channel `%s` not found in manifest", channel)
 }
 
 func (rm *ReleaseManifest) GetVersionInfo(version string) (*ReleaseInfo, error) {
@@ -33,6 +92,26 @@ func (rm *ReleaseManifest) GetVersionInfo(version string) (*ReleaseInfo, error)
 	return nil, errors.New("version not found in manifest")
 }
 
+// DownloadDigest returns the digest of the file at URL, which is compressed for compressed artifacts.
+func (a *Artifact) DownloadDigest() string {
+	if a.Compression != "" {
+		return a.CompressedDigest
+	}
+
+	return a.Digest
+}
+
+// PatchFrom returns the patch from the given version, nil when there is none.
+func (a *Artifact) PatchFrom(version string) *Patch {
+	for _, p := range a.Patches {
+		if p.From == version {
+			return &p
+		}
+	}
+
+	return nil
+}
+
 func (ri *ReleaseInfo) GetArtifactForPlatform(os, arch string) (*Artifact, error) {
 	for _, a := range ri.Artifacts {
 		if a.OS == os && a.Arch == arch {
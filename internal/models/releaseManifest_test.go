@@ -28,3 +28,49 @@ func TestJsonParse(t *testing.T) {
 		assert.NotEmpty(t, artifact.URL, "should have a URL for the artifact")
 	}
 }
+
+func Test_ReleaseManifest_LatestForChannel(t *testing.T) {
+	manifest := ReleaseManifest{
+		Latest: "v1.2.3",
+		Channels: map[string]string{
+			ChannelBeta: "v1.3.0-beta.1",
+		},
+	}
+
+	t.Run("should return the latest version of the channel", func(t *testing.T) {
+		v, err := manifest.LatestForChannel(ChannelBeta)
+		assert.NoError(t, err)
+		assert.Equal(t, "v1.3.0-beta.1", v)
+	})
+
+	t.Run("should fall back to latest for the stable channel", func(t *testing.T) {
+		v, err := manifest.LatestForChannel(ChannelStable)
+		assert.NoError(t, err)
+		assert.Equal(t, "v1.2.3", v)
+	})
+
+	t.Run("should error for an unknown channel", func(t *testing.T) {
+		_, err := manifest.LatestForChannel(ChannelNightly)
+		assert.Error(t, err)
+	})
+}
+
+func Test_Artifact_PatchFrom(t *testing.T) {
+	artifact := Artifact{
+		Patches: []Patch{
+			{From: "v1.2.1", URL: "https://example.com/v1.2.1.patch"},
+			{From: "v1.2.2", URL: "https://example.com/v1.2.2.patch"},
+		},
+	}
+
+	t.Run("should return the patch from the given version", func(t *testing.T) {
+		patch := artifact.PatchFrom("v1.2.2")
+		if assert.NotNil(t, patch) {
+			assert.Equal(t, "https://example.com/v1.2.2.patch", patch.URL)
+		}
+	})
+
+	t.Run("should return nil when there is no patch from the given version", func(t *testing.T) {
+		assert.Nil(t, artifact.PatchFrom("v1.0.0"))
+	})
+}
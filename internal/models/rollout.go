@@ -0,0 +1,41 @@
+package models
+
+import "time"
+
+// Rollout limits a release to a share of the fleet, optionally ramping it up over time.
+type Rollout struct {
+	// Share of installations, from 0 to 100, that get the release from StartAt on
+	Percentage float64 `json:"percentage"`
+	// The release is not offered to anyone before this time
+	StartAt *time.Time `json:"startAt,omitempty"`
+	// Each step raises the percentage from its time on, e.g. 5% now, 25% tomorrow, 100% next week
+	Schedule []RolloutStep `json:"schedule,omitempty"`
+}
+
+type RolloutStep struct {
+	At         time.Time `json:"at"`
+	Percentage float64   `json:"percentage"`
+}
+
+// PercentageAt returns the share of installations that should have the release at the given time.
+// A nil rollout means the release goes to everyone.
+func (r *Rollout) PercentageAt(now time.Time) float64 {
+	if r == nil {
+		return 100
+	}
+
+	if r.StartAt != nil && now.Before(*r.StartAt) {
+		return 0
+	}
+
+	percentage := r.Percentage
+	var latestStep time.Time
+	for _, step := range r.Schedule {
+		if !now.Before(step.At) && !step.At.Before(latestStep) {
+			latestStep = step.At
+			percentage = step.Percentage
+		}
+	}
+
+	return min(max(percentage, 0), 100)
+}
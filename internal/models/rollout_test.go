@@ -0,0 +1,46 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Rollout_PercentageAt(t *testing.T) {
+	start := time.Date(2025, 3, 27, 12, 0, 0, 0, time.UTC)
+
+	t.Run("should release to everyone without a rollout", func(t *testing.T) {
+		var r *Rollout
+		assert.Equal(t, float64(100), r.PercentageAt(start))
+	})
+
+	t.Run("should release to nobody before the start time", func(t *testing.T) {
+		r := &Rollout{
+			Percentage: 50,
+			StartAt:    &start,
+		}
+
+		assert.Equal(t, float64(0), r.PercentageAt(start.Add(-time.Second)))
+		assert.Equal(t, float64(50), r.PercentageAt(start))
+	})
+
+	t.Run("should ramp up following the schedule", func(t *testing.T) {
+		r := &Rollout{
+			Percentage: 5,
+			Schedule: []RolloutStep{
+				{At: start.Add(48 * time.Hour), Percentage: 100},
+				{At: start.Add(24 * time.Hour), Percentage: 25},
+			},
+		}
+
+		assert.Equal(t, float64(5), r.PercentageAt(start))
+		assert.Equal(t, float64(25), r.PercentageAt(start.Add(30*time.Hour)))
+		assert.Equal(t, float64(100), r.PercentageAt(start.Add(72*time.Hour)))
+	})
+
+	t.Run("should clamp out of range percentages", func(t *testing.T) {
+		assert.Equal(t, float64(100), (&Rollout{Percentage: 150}).PercentageAt(start))
+		assert.Equal(t, float64(0), (&Rollout{Percentage: -3}).PercentageAt(start))
+	})
+}
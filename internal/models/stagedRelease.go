@@ -0,0 +1,13 @@
+package models
+
+// StagedRelease describes the binary the server staged for the launcher to swap in.
+// It is written next to the staged binary so the launcher knows what to expect
+// from the new server when health checking it.
+type StagedRelease struct {
+	Version string `json:"version"`
+	Commit  string `json:"commit"`
+	// Digest of the executable, which for bundles is not the digest of the artifact
+	Digest string `json:"digest"`
+	// Path of the executable within the staged release directory
+	Entrypoint string `json:"entrypoint,omitempty"`
+}
@@ -0,0 +1,26 @@
+package models
+
+import "time"
+
+// Timestamp is short-lived signed metadata naming the current manifest, like the timestamp role of
+// The Update Framework. It is re-signed much more often than releases are published, so that an
+// installation served an old manifest notices as soon as the timestamp expires.
+type Timestamp struct {
+	// Raised every time the timestamp is signed
+	Version int64     `json:"version"`
+	Expires time.Time `json:"expires"`
+	// Manifest current when the timestamp was signed
+	Manifest MetadataFile `json:"manifest"`
+}
+
+// MetadataFile pins a metadata file by its version and digest.
+type MetadataFile struct {
+	Version int64  `json:"version"`
+	Digest  string `json:"digest"`
+}
+
+// MetadataVersions are the highest metadata versions an installation trusted, anything lower is a rollback.
+type MetadataVersions struct {
+	Manifest  int64 `json:"manifest,omitempty"`
+	Timestamp int64 `json:"timestamp,omitempty"`
+}
@@ -0,0 +1,286 @@
+package oci
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/danilevy1212/self-updater/internal/httpclient"
+)
+
+const (
+	MediaTypeImageManifest = "application/vnd.oci.image.manifest.v1+json"
+	// Set by e.g. `oras push` to the name of the pushed file
+	AnnotationTitle = "org.opencontainers.image.title"
+)
+
+type Descriptor struct {
+	MediaType   string            `json:"mediaType"`
+	Digest      string            `json:"digest"`
+	Size        int64             `json:"size"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+func (d Descriptor) Title() string {
+	return d.Annotations[AnnotationTitle]
+}
+
+// Manifest is an OCI image manifest, artifacts keep their files as layers.
+type Manifest struct {
+	SchemaVersion int          `json:"schemaVersion"`
+	MediaType     string       `json:"mediaType"`
+	ArtifactType  string       `json:"artifactType,omitempty"`
+	Config        Descriptor   `json:"config"`
+	Layers        []Descriptor `json:"layers"`
+}
+
+// LayerByTitle finds the layer pushed from the file with the given name.
+func (m *Manifest) LayerByTitle(title string) (*Descriptor, error) {
+	for _, l := range m.Layers {
+		if l.Title() == title {
+			return &l, nil
+		}
+	}
+
+	return nil, fmt.Errorf("no layer titled `%s` in manifest", title)
+}
+
+// Client pulls from a repository of an OCI distribution registry, see
+// https://github.com/opencontainers/distribution-spec/blob/main/spec.md.
+type Client struct {
+	Registry   string
+	Repository string
+	Username   string
+	Password   string
+	// Talk plain HTTP, for registries in a lab
+	PlainHTTP bool
+
+	mu          sync.Mutex
+	token       string
+	tokenExpiry time.Time
+	basicAuth   bool
+}
+
+func NewClient(registry, repository, username, password string, plainHTTP bool) (*Client, error) {
+	if registry == "" {
+		return nil, errors.New("oci registry cannot be empty")
+	}
+
+	if repository == "" {
+		return nil, errors.New("oci repository cannot be empty")
+	}
+
+	return &Client{
+		Registry:   registry,
+		Repository: repository,
+		Username:   username,
+		Password:   password,
+		PlainHTTP:  plainHTTP,
+	}, nil
+}
+
+func (c *Client) baseURL() string {
+	scheme := "https"
+	if c.PlainHTTP {
+		scheme = "http"
+	}
+
+	return scheme + "://" + c.Registry + "/v2/"
+}
+
+func (c *Client) ManifestURL(reference string) string {
+	return c.baseURL() + c.Repository + "/manifests/" + reference
+}
+
+func (c *Client) BlobURL(digest string) string {
+	return c.baseURL() + c.Repository + "/blobs/" + digest
+}
+
+// GetManifest resolves a tag or digest to its manifest.
+func (c *Client) GetManifest(ctx context.Context, reference string) (*Manifest, error) {
+	req, err := c.NewRequest(ctx, c.ManifestURL(reference))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", MediaTypeImageManifest)
+
+	resp, err := httpclient.FromContext(ctx).Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("error making request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+
+	var m Manifest
+	if err := json.NewDecoder(resp.Body).Decode(&m); err != nil {
+		return nil, fmt.Errorf("failed to decode manifest: %w", err)
+	}
+
+	if m.MediaType != "" && m.MediaType != MediaTypeImageManifest {
+		return nil, fmt.Errorf("unsupported manifest media type `%s`", m.MediaType)
+	}
+
+	return &m, nil
+}
+
+// NewRequest builds an authorized GET request to the registry.
+func (c *Client) NewRequest(ctx context.Context, rawURL string) (*http.Request, error) {
+	if err := c.authorize(ctx); err != nil {
+		return nil, fmt.Errorf("failed to authorize with registry: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("error building request: %w", err)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	switch {
+	case c.token != "":
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	case c.basicAuth:
+		req.SetBasicAuth(c.Username, c.Password)
+	}
+
+	return req, nil
+}
+
+// authorize pings the registry and follows its challenge, if any, for a pull token of the repository.
+func (c *Client) authorize(ctx context.Context) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.basicAuth || (c.token != "" && time.Now().Before(c.tokenExpiry)) {
+		return nil
+	}
+	c.token = ""
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL(), nil)
+	if err != nil {
+		return fmt.Errorf("error building request: %w", err)
+	}
+
+	resp, err := httpclient.FromContext(ctx).Do(req)
+	if err != nil {
+		return fmt.Errorf("error making request: %w", err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusUnauthorized {
+		return nil
+	}
+
+	scheme, params := parseChallenge(resp.Header.Get("WWW-Authenticate"))
+	switch strings.ToLower(scheme) {
+	case "basic":
+		if c.Username == "" {
+			return errors.New("registry requires basic auth, but no credentials are configured")
+		}
+		c.basicAuth = true
+
+		return nil
+	case "bearer":
+		return c.fetchToken(ctx, params)
+	default:
+		return fmt.Errorf("unsupported auth challenge `%s`", scheme)
+	}
+}
+
+func (c *Client) fetchToken(ctx context.Context, params map[string]string) error {
+	realm, err := url.Parse(params["realm"])
+	if err != nil || realm.Scheme == "" {
+		return fmt.Errorf("invalid token realm `%s`", params["realm"])
+	}
+
+	query := realm.Query()
+	if service := params["service"]; service != "" {
+		query.Set("service", service)
+	}
+	query.Set("scope", "repository:"+c.Repository+":pull")
+	realm.RawQuery = query.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, realm.String(), nil)
+	if err != nil {
+		return fmt.Errorf("error building token request: %w", err)
+	}
+	if c.Username != "" {
+		req.SetBasicAuth(c.Username, c.Password)
+	}
+
+	resp, err := httpclient.FromContext(ctx).Do(req)
+	if err != nil {
+		return fmt.Errorf("error requesting token: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected token status code: %d", resp.StatusCode)
+	}
+
+	var body struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return fmt.Errorf("failed to decode token: %w", err)
+	}
+
+	c.token = body.Token
+	if c.token == "" {
+		c.token = body.AccessToken
+	}
+	if c.token == "" {
+		return errors.New("registry returned an empty token")
+	}
+
+	// The spec defaults to 60 seconds, keep a margin so that a token doesn't expire mid-request.
+	expiresIn := body.ExpiresIn
+	if expiresIn <= 0 {
+		expiresIn = 60
+	}
+	c.tokenExpiry = time.Now().Add(time.Duration(expiresIn)*time.Second - 10*time.Second)
+
+	return nil
+}
+
+// parseChallenge parses `Bearer realm="https://auth.example.com/token",service="registry"`.
+func parseChallenge(header string) (string, map[string]string) {
+	scheme, rest, _ := strings.Cut(strings.TrimSpace(header), " ")
+	params := map[string]string{}
+
+	for rest != "" {
+		var pair string
+		// Values are quoted and may contain commas, e.g. in scopes.
+		key, value, ok := strings.Cut(rest, "=")
+		if !ok {
+			break
+		}
+		key = strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(key), ","))
+
+		if strings.HasPrefix(value, `"`) {
+			end := strings.Index(value[1:], `"`)
+			if end < 0 {
+				break
+			}
+			pair, rest = value[1:end+1], value[end+2:]
+		} else {
+			pair, rest, _ = strings.Cut(value, ",")
+		}
+
+		params[strings.ToLower(key)] = pair
+	}
+
+	return scheme, params
+}
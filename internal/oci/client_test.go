@@ -0,0 +1,96 @@
+package oci
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_parseChallenge(t *testing.T) {
+	t.Run("should parse quoted parameters", func(t *testing.T) {
+		scheme, params := parseChallenge(`Bearer realm="https://auth.example.com/token",service="registry.example.com",scope="repository:a/b:pull,push"`)
+
+		assert.Equal(t, "Bearer", scheme)
+		assert.Equal(t, map[string]string{
+			"realm":   "https://auth.example.com/token",
+			"service": "registry.example.com",
+			"scope":   "repository:a/b:pull,push",
+		}, params)
+	})
+
+	t.Run("should parse a challenge without parameters", func(t *testing.T) {
+		scheme, params := parseChallenge(`Basic`)
+
+		assert.Equal(t, "Basic", scheme)
+		assert.Empty(t, params)
+	})
+}
+
+func Test_Client_GetManifest(t *testing.T) {
+	t.Run("should follow the bearer challenge for a pull token", func(t *testing.T) {
+		var tokenScope string
+		var ts *httptest.Server
+		ts = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch {
+			case r.URL.Path == "/token":
+				user, pass, _ := r.BasicAuth()
+				if user != "robot" || pass != "secret" {
+					w.WriteHeader(http.StatusUnauthorized)
+					return
+				}
+				tokenScope = r.URL.Query().Get("scope")
+				_ = json.NewEncoder(w).Encode(map[string]any{"token": "pull-token", "expires_in": 300})
+			case r.Header.Get("Authorization") != "Bearer pull-token":
+				w.Header().Set("WWW-Authenticate", `Bearer realm="`+ts.URL+`/token",service="test-registry"`)
+				w.WriteHeader(http.StatusUnauthorized)
+			case r.URL.Path == "/v2/":
+				w.WriteHeader(http.StatusOK)
+			case r.URL.Path == "/v2/platform/self-updater/manifests/stable":
+				assert.Equal(t, MediaTypeImageManifest, r.Header.Get("Accept"))
+				w.Header().Set("Content-Type", MediaTypeImageManifest)
+				_ = json.NewEncoder(w).Encode(Manifest{
+					SchemaVersion: 2,
+					MediaType:     MediaTypeImageManifest,
+					Layers: []Descriptor{{
+						Digest:      "sha256:aaaa",
+						Annotations: map[string]string{AnnotationTitle: "release.json"},
+					}},
+				})
+			default:
+				w.WriteHeader(http.StatusNotFound)
+			}
+		}))
+		defer ts.Close()
+
+		c, err := NewClient(strings.TrimPrefix(ts.URL, "http://"), "platform/self-updater", "robot", "secret", true)
+		assert.NoError(t, err)
+
+		m, err := c.GetManifest(context.Background(), "stable")
+		assert.NoError(t, err)
+		assert.Equal(t, "repository:platform/self-updater:pull", tokenScope)
+
+		layer, err := m.LayerByTitle("release.json")
+		assert.NoError(t, err)
+		assert.Equal(t, "sha256:aaaa", layer.Digest)
+	})
+
+	t.Run("should reject image indexes", func(t *testing.T) {
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_ = json.NewEncoder(w).Encode(map[string]any{
+				"schemaVersion": 2,
+				"mediaType":     "application/vnd.oci.image.index.v1+json",
+			})
+		}))
+		defer ts.Close()
+
+		c, _ := NewClient(strings.TrimPrefix(ts.URL, "http://"), "platform/self-updater", "", "", true)
+
+		_, err := c.GetManifest(context.Background(), "stable")
+		assert.ErrorContains(t, err, "unsupported manifest media type")
+	})
+}
@@ -0,0 +1,92 @@
+package s3
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Client reads objects from an S3-compatible bucket, e.g. AWS S3 or MinIO.
+type Client struct {
+	Endpoint *url.URL
+	Region   string
+	// Path-style addressing puts the bucket in the path, as MinIO expects, instead of in the host name
+	PathStyle   bool
+	Credentials Credentials
+}
+
+func NewClient(endpoint, region string, pathStyle bool, creds Credentials) (*Client, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("invalid s3 endpoint `%s`: %w", endpoint, err)
+	}
+
+	if u.Scheme == "" || u.Host == "" {
+		return nil, fmt.Errorf("invalid s3 endpoint `%s`, expected e.g. https://s3.amazonaws.com", endpoint)
+	}
+
+	if region == "" {
+		return nil, errors.New("s3 region cannot be empty")
+	}
+
+	return &Client{
+		Endpoint:    u,
+		Region:      region,
+		PathStyle:   pathStyle,
+		Credentials: creds,
+	}, nil
+}
+
+func (c *Client) ObjectURL(bucket, key string) *url.URL {
+	u := *c.Endpoint
+	key = strings.TrimPrefix(key, "/")
+	basePath := strings.TrimSuffix(u.Path, "/")
+
+	if c.PathStyle {
+		u.Path = basePath + "/" + bucket + "/" + key
+	} else {
+		u.Host = bucket + "." + u.Host
+		u.Path = basePath + "/" + key
+	}
+
+	return &u
+}
+
+// NewGetObjectRequest builds a request for the object, signed unless the client is anonymous.
+func (c *Client) NewGetObjectRequest(ctx context.Context, bucket, key string) (*http.Request, error) {
+	return c.NewGetObjectRequestWithHeader(ctx, bucket, key, http.Header{})
+}
+
+// NewGetObjectRequestWithHeader is like NewGetObjectRequest, header is signed along with the request,
+// e.g. the Range of a resumed download.
+func (c *Client) NewGetObjectRequestWithHeader(ctx context.Context, bucket, key string, header http.Header) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.ObjectURL(bucket, key).String(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("error building request: %w", err)
+	}
+	req.Header = header.Clone()
+
+	if !c.Credentials.IsAnonymous() {
+		Sign(req, c.Credentials, c.Region, time.Now())
+	}
+
+	return req, nil
+}
+
+// ParseURL splits an `s3://bucket/key` URL.
+func ParseURL(raw string) (string, string, error) {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return "", "", fmt.Errorf("invalid s3 url `%s`: %w", raw, err)
+	}
+
+	if u.Scheme != "s3" || u.Host == "" {
+		return "", "", fmt.Errorf("invalid s3 url `%s`, expected s3://bucket/key", raw)
+	}
+
+	return u.Host, strings.TrimPrefix(u.Path, "/"), nil
+}
@@ -0,0 +1,61 @@
+package s3
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Client_ObjectURL(t *testing.T) {
+	t.Run("should put the bucket in the path with path-style addressing", func(t *testing.T) {
+		c, err := NewClient("http://localhost:9000", "us-east-1", true, Credentials{})
+		assert.NoError(t, err)
+
+		assert.Equal(t, "http://localhost:9000/releases/self-updater/release.json", c.ObjectURL("releases", "self-updater/release.json").String())
+	})
+
+	t.Run("should put the bucket in the host name with virtual-hosted addressing", func(t *testing.T) {
+		c, err := NewClient("https://s3.amazonaws.com", "us-east-1", false, Credentials{})
+		assert.NoError(t, err)
+
+		assert.Equal(t, "https://releases.s3.amazonaws.com/release.json", c.ObjectURL("releases", "/release.json").String())
+	})
+}
+
+func Test_Client_NewGetObjectRequest(t *testing.T) {
+	t.Run("should not sign anonymous requests", func(t *testing.T) {
+		c, _ := NewClient("http://localhost:9000", "us-east-1", true, Credentials{})
+
+		req, err := c.NewGetObjectRequest(context.Background(), "releases", "release.json")
+		assert.NoError(t, err)
+		assert.Empty(t, req.Header.Get("Authorization"))
+	})
+
+	t.Run("should sign requests with credentials", func(t *testing.T) {
+		c, _ := NewClient("http://localhost:9000", "us-east-1", true, Credentials{
+			AccessKeyID:     "minioadmin",
+			SecretAccessKey: "minioadmin",
+		})
+
+		req, err := c.NewGetObjectRequest(context.Background(), "releases", "release.json")
+		assert.NoError(t, err)
+		assert.Contains(t, req.Header.Get("Authorization"), "Credential=minioadmin/")
+	})
+}
+
+func Test_ParseURL(t *testing.T) {
+	t.Run("should split bucket and key", func(t *testing.T) {
+		bucket, key, err := ParseURL("s3://releases/v1.2.3/api-linux-amd64")
+
+		assert.NoError(t, err)
+		assert.Equal(t, "releases", bucket)
+		assert.Equal(t, "v1.2.3/api-linux-amd64", key)
+	})
+
+	t.Run("should reject other urls", func(t *testing.T) {
+		_, _, err := ParseURL("https://releases/v1.2.3/api-linux-amd64")
+
+		assert.Error(t, err)
+	})
+}
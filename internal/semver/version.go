@@ -0,0 +1,196 @@
+package semver
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Version is a semantic version (https://semver.org), an optional leading `v` is accepted.
+type Version struct {
+	Major      uint64
+	Minor      uint64
+	Patch      uint64
+	Prerelease []string
+	Build      []string
+	original   string
+}
+
+func Parse(raw string) (Version, error) {
+	s := strings.TrimPrefix(raw, "v")
+	if s == "" {
+		return Version{}, errors.New("version is empty")
+	}
+
+	v := Version{original: raw}
+
+	if i := strings.IndexByte(s, '+'); i >= 0 {
+		build := s[i+1:]
+		s = s[:i]
+
+		ids, err := parseIdentifiers(build, false)
+		if err != nil {
+			return Version{}, fmt.Errorf("invalid build metadata in `%s`: %w", raw, err)
+		}
+		v.Build = ids
+	}
+
+	if i := strings.IndexByte(s, '-'); i >= 0 {
+		prerelease := s[i+1:]
+		s = s[:i]
+
+		ids, err := parseIdentifiers(prerelease, true)
+		if err != nil {
+			return Version{}, fmt.Errorf("invalid pre-release in `%s`: %w", raw, err)
+		}
+		v.Prerelease = ids
+	}
+
+	parts := strings.Split(s, ".")
+	if len(parts) != 3 {
+		return Version{}, fmt.Errorf("invalid version `%s`: expected MAJOR.MINOR.PATCH", raw)
+	}
+
+	numbers := make([]uint64, 3)
+	for i, p := range parts {
+		n, err := parseNumber(p)
+		if err != nil {
+			return Version{}, fmt.Errorf("invalid version `%s`: %w", raw, err)
+		}
+		numbers[i] = n
+	}
+	v.Major, v.Minor, v.Patch = numbers[0], numbers[1], numbers[2]
+
+	return v, nil
+}
+
+func parseNumber(s string) (uint64, error) {
+	if s == "" {
+		return 0, errors.New("empty numeric identifier")
+	}
+	if len(s) > 1 && s[0] == '0' {
+		return 0, fmt.Errorf("numeric identifier `%s` has leading zeros", s)
+	}
+
+	n, err := strconv.ParseUint(s, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid numeric identifier `%s`", s)
+	}
+
+	return n, nil
+}
+
+func parseIdentifiers(s string, strictNumbers bool) ([]string, error) {
+	ids := strings.Split(s, ".")
+	for _, id := range ids {
+		if id == "" {
+			return nil, errors.New("empty identifier")
+		}
+
+		for _, c := range id {
+			if !(c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c == '-') {
+				return nil, fmt.Errorf("invalid character `%c` in identifier `%s`", c, id)
+			}
+		}
+
+		if strictNumbers && isNumeric(id) {
+			if _, err := parseNumber(id); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return ids, nil
+}
+
+func isNumeric(s string) bool {
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+
+	return s != ""
+}
+
+// Compare returns -1, 0 or 1 when v is lower, equal or higher than o. Build metadata is ignored.
+func (v Version) Compare(o Version) int {
+	if c := compareNumbers(v.Major, o.Major); c != 0 {
+		return c
+	}
+	if c := compareNumbers(v.Minor, o.Minor); c != 0 {
+		return c
+	}
+	if c := compareNumbers(v.Patch, o.Patch); c != 0 {
+		return c
+	}
+
+	// A pre-release has lower precedence than its normal version.
+	switch {
+	case len(v.Prerelease) == 0 && len(o.Prerelease) == 0:
+		return 0
+	case len(v.Prerelease) == 0:
+		return 1
+	case len(o.Prerelease) == 0:
+		return -1
+	}
+
+	for i := 0; i < len(v.Prerelease) && i < len(o.Prerelease); i++ {
+		if c := compareIdentifiers(v.Prerelease[i], o.Prerelease[i]); c != 0 {
+			return c
+		}
+	}
+
+	return compareNumbers(uint64(len(v.Prerelease)), uint64(len(o.Prerelease)))
+}
+
+func (v Version) LessThan(o Version) bool {
+	return v.Compare(o) < 0
+}
+
+func compareNumbers(a, b uint64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	default:
+		return 0
+	}
+}
+
+func compareIdentifiers(a, b string) int {
+	aNumeric, bNumeric := isNumeric(a), isNumeric(b)
+
+	switch {
+	case aNumeric && bNumeric:
+		an, _ := strconv.ParseUint(a, 10, 64)
+		bn, _ := strconv.ParseUint(b, 10, 64)
+		return compareNumbers(an, bn)
+	case aNumeric:
+		// Numeric identifiers have lower precedence than alphanumeric ones.
+		return -1
+	case bNumeric:
+		return 1
+	default:
+		return strings.Compare(a, b)
+	}
+}
+
+// String returns the version as it was parsed.
+func (v Version) String() string {
+	if v.original != "" {
+		return v.original
+	}
+
+	s := fmt.Sprintf("%d.%d.%d", v.Major, v.Minor, v.Patch)
+	if len(v.Prerelease) > 0 {
+		s += "-" + strings.Join(v.Prerelease, ".")
+	}
+	if len(v.Build) > 0 {
+		s += "+" + strings.Join(v.Build, ".")
+	}
+
+	return s
+}
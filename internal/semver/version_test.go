@@ -0,0 +1,81 @@
+package semver
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParse(t *testing.T) {
+	t.Run("should parse a full version", func(t *testing.T) {
+		v, err := Parse("v1.2.3-beta.1+build.5")
+		assert.NoError(t, err)
+		assert.Equal(t, uint64(1), v.Major)
+		assert.Equal(t, uint64(2), v.Minor)
+		assert.Equal(t, uint64(3), v.Patch)
+		assert.Equal(t, []string{"beta", "1"}, v.Prerelease)
+		assert.Equal(t, []string{"build", "5"}, v.Build)
+		assert.Equal(t, "v1.2.3-beta.1+build.5", v.String(), "should keep the original string")
+	})
+
+	t.Run("should parse a version without v prefix", func(t *testing.T) {
+		v, err := Parse("10.0.1")
+		assert.NoError(t, err)
+		assert.Equal(t, uint64(10), v.Major)
+	})
+
+	t.Run("should reject invalid versions", func(t *testing.T) {
+		for _, raw := range []string{
+			"",
+			"v",
+			"development",
+			"1.2",
+			"1.2.3.4",
+			"01.2.3",
+			"1.2.3-",
+			"1.2.3-01",
+			"1.2.3-beta..1",
+			"1.2.3+",
+			"1.2.3-b@d",
+		} {
+			_, err := Parse(raw)
+			assert.Error(t, err, "should reject `%s`", raw)
+		}
+	})
+}
+
+func Test_Version_Compare(t *testing.T) {
+	mustParse := func(raw string) Version {
+		v, err := Parse(raw)
+		assert.NoError(t, err)
+		return v
+	}
+
+	t.Run("should follow semver precedence", func(t *testing.T) {
+		// Example from https://semver.org/#spec-item-11
+		ordered := []string{
+			"1.0.0-alpha",
+			"1.0.0-alpha.1",
+			"1.0.0-alpha.beta",
+			"1.0.0-beta",
+			"1.0.0-beta.2",
+			"1.0.0-beta.11",
+			"1.0.0-rc.1",
+			"1.0.0",
+			"1.0.1",
+			"1.1.0",
+			"2.0.0",
+		}
+
+		for i := 0; i < len(ordered)-1; i++ {
+			lower, higher := mustParse(ordered[i]), mustParse(ordered[i+1])
+			assert.Equal(t, -1, lower.Compare(higher), "%s should be lower than %s", lower, higher)
+			assert.Equal(t, 1, higher.Compare(lower), "%s should be higher than %s", higher, lower)
+			assert.True(t, lower.LessThan(higher))
+		}
+	})
+
+	t.Run("should ignore build metadata and v prefix", func(t *testing.T) {
+		assert.Equal(t, 0, mustParse("v1.2.3+abc").Compare(mustParse("1.2.3+def")))
+	})
+}
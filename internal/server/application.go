@@ -3,10 +3,13 @@ package server
 import (
 	"context"
 	"fmt"
+	"net"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog"
 
+	"github.com/danilevy1212/self-updater/internal/logger"
 	"github.com/danilevy1212/self-updater/internal/models"
 	"github.com/danilevy1212/self-updater/internal/server/config"
 )
@@ -16,15 +19,32 @@ type Application struct {
 	Router *gin.Engine
 	Config *config.Config
 	Meta   models.ApplicationMeta
+	Logger *zerolog.Logger
+	// Reports the artifact the updater is downloading, nil when it is idle.
+	DownloadProgress func() *models.DownloadProgress
 }
 
 func (a *Application) Serve(port uint) error {
-	a.Server = &http.Server{
-		Addr:    fmt.Sprintf(":%d", a.Config.Port),
-		Handler: a.Router,
+	a.Server.Addr = fmt.Sprintf(":%d", port)
+
+	ln, err := InheritedListener()
+	if err != nil {
+		return fmt.Errorf("failed to use inherited listener: %w", err)
 	}
 
-	if err := a.Server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+	if ln == nil {
+		ln, err = net.Listen("tcp", a.Server.Addr)
+		if err != nil {
+			return err
+		}
+	} else {
+		a.Logger.Info().
+			Str("address", ln.Addr().String()).
+			Msg("Serving on listener inherited from launcher")
+	}
+
+	// Returns http.ErrServerClosed straight away if Shutdown was called before serving.
+	if err := a.Server.Serve(ln); err != nil && err != http.ErrServerClosed {
 		return err
 	}
 
@@ -55,9 +75,19 @@ func New(ctx context.Context, meta models.ApplicationMeta) (*Application, error)
 	}
 	r.RemoveExtraSlash = true
 
+	l := logger.New(c.IsDev).
+		With().
+		Str("app", "server").
+		Logger()
+
 	return &Application{
+		// Created upfront, so that a shutdown requested before serving is not lost.
+		Server: &http.Server{
+			Handler: r,
+		},
 		Config: c,
 		Meta:   meta,
 		Router: r,
+		Logger: &l,
 	}, nil
 }
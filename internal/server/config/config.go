@@ -3,13 +3,15 @@ package config
 import (
 	"context"
 	"fmt"
+	"time"
 
 	"github.com/sethvargo/go-envconfig"
 )
 
 type Config struct {
-	IsDev bool `env:"SERVER_IS_DEV,default=false"`
-	Port  uint `env:"SERVER_PORT,default=3000"`
+	IsDev           bool          `env:"SERVER_IS_DEV,default=false"`
+	Port            uint          `env:"SERVER_PORT,default=3000"`
+	ShutdownTimeout time.Duration `env:"SERVER_SHUTDOWN_TIMEOUT,default=10s"`
 }
 
 type ConfigFunc func(context.Context) (*Config, error)
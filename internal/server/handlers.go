@@ -4,6 +4,7 @@ import (
 	"net/http"
 
 	"github.com/danilevy1212/self-updater/internal/logger"
+	"github.com/danilevy1212/self-updater/internal/models"
 	"github.com/gin-gonic/gin"
 )
 
@@ -23,3 +24,15 @@ func (a *Application) HealthCheck(ctx *gin.Context) {
 		"commit":  a.Meta.Commit,
 	})
 }
+
+func (a *Application) UpdateDownload(ctx *gin.Context) {
+	var progress *models.DownloadProgress
+	if a.DownloadProgress != nil {
+		progress = a.DownloadProgress()
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"inProgress": progress != nil,
+		"progress":   progress,
+	})
+}
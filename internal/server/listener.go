@@ -0,0 +1,51 @@
+package server
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+)
+
+// First file descriptor passed with LISTEN_FDS, right after stdin, stdout and stderr.
+const listenFDsStart = 3
+
+// InheritedListener returns the listening socket handed down by the launcher (or systemd)
+// through LISTEN_FDS, nil when there is none.
+func InheritedListener() (net.Listener, error) {
+	raw := os.Getenv("LISTEN_FDS")
+	if raw == "" {
+		return nil, nil
+	}
+
+	// The launcher can't know our PID before starting us, systemd sets it.
+	if pid := os.Getenv("LISTEN_PID"); pid != "" && pid != strconv.Itoa(os.Getpid()) {
+		return nil, nil
+	}
+
+	n, err := strconv.Atoi(raw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid LISTEN_FDS `%s`: %w", raw, err)
+	}
+	if n < 1 {
+		return nil, nil
+	}
+
+	// Children of this process should not think they inherited the socket too.
+	_ = os.Unsetenv("LISTEN_FDS")
+	_ = os.Unsetenv("LISTEN_PID")
+	_ = os.Unsetenv("LISTEN_FDNAMES")
+
+	f := os.NewFile(uintptr(listenFDsStart), "LISTEN_FD_3")
+	if f == nil {
+		return nil, fmt.Errorf("invalid inherited file descriptor %d", listenFDsStart)
+	}
+	defer f.Close()
+
+	ln, err := net.FileListener(f)
+	if err != nil {
+		return nil, fmt.Errorf("failed to use inherited file descriptor as listener: %w", err)
+	}
+
+	return ln, nil
+}
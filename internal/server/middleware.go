@@ -13,10 +13,5 @@ func (a *Application) RegisterGlobalMiddleware() {
 	r.Use(gin.Recovery())
 
 	// Zerolog logger
-	l := logger.New(a.Config.IsDev).
-		With().
-		Str("app", "server").
-		Logger()
-
-	r.Use(logger.NewMiddleware(&l))
+	r.Use(logger.NewMiddleware(a.Logger))
 }
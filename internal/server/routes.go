@@ -4,4 +4,5 @@ func (a *Application) RegisterRoutes() {
 	r := a.Router
 
 	r.GET("/health", a.HealthCheck)
+	r.GET("/update/download", a.UpdateDownload)
 }
@@ -0,0 +1,57 @@
+package updater
+
+import (
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/danilevy1212/self-updater/internal/bundle"
+	"github.com/danilevy1212/self-updater/internal/digest"
+	"github.com/danilevy1212/self-updater/internal/models"
+)
+
+// extractBundle extracts a verified bundle next to it, within the configured limits. Returns the release
+// directory, opened to be handed over like an artifact file, and the hex digest of its executable. Consumes file.
+func (u *Updater) extractBundle(file *os.File, artifact *models.Artifact) (*os.File, string, error) {
+	defer discardFile(file)
+
+	entrypoint := filepath.FromSlash(artifact.Entrypoint)
+	if !filepath.IsLocal(entrypoint) {
+		return nil, "", fmt.Errorf("invalid bundle entrypoint `%s`", artifact.Entrypoint)
+	}
+
+	dir, err := os.MkdirTemp(filepath.Dir(file.Name()), "bundle-*")
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to create bundle directory: %w", err)
+	}
+
+	limits := bundle.Limits{
+		MaxFiles: u.Config.BundleMaxFiles,
+		MaxSize:  int64(u.Config.BundleMaxSize),
+	}
+	if err := bundle.Extract(file, artifact.Bundle, dir, limits); err != nil {
+		_ = os.RemoveAll(dir)
+		return nil, "", fmt.Errorf("failed to extract bundle: %w", err)
+	}
+
+	executable := filepath.Join(dir, entrypoint)
+	if info, err := os.Lstat(executable); err != nil || !info.Mode().IsRegular() {
+		_ = os.RemoveAll(dir)
+		return nil, "", fmt.Errorf("bundle entrypoint `%s` is not a file in the bundle", artifact.Entrypoint)
+	}
+
+	executableDigest, err := digest.DigestFile(executable)
+	if err != nil {
+		_ = os.RemoveAll(dir)
+		return nil, "", fmt.Errorf("failed to calculate entrypoint digest: %w", err)
+	}
+
+	handle, err := os.Open(dir)
+	if err != nil {
+		_ = os.RemoveAll(dir)
+		return nil, "", fmt.Errorf("failed to open bundle directory: %w", err)
+	}
+
+	return handle, hex.EncodeToString(executableDigest), nil
+}
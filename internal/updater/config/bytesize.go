@@ -0,0 +1,45 @@
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ByteSize is a number of bytes, e.g. `1048576`, `512KiB` or `10MB`.
+type ByteSize int64
+
+var byteSizeUnits = []struct {
+	suffix     string
+	multiplier int64
+}{
+	// Longest suffixes first, `KiB` also ends in `B`.
+	{"KiB", 1 << 10},
+	{"MiB", 1 << 20},
+	{"GiB", 1 << 30},
+	{"KB", 1000},
+	{"MB", 1000 * 1000},
+	{"GB", 1000 * 1000 * 1000},
+	{"B", 1},
+}
+
+func (b *ByteSize) EnvDecode(val string) error {
+	val = strings.TrimSpace(val)
+
+	multiplier := int64(1)
+	for _, unit := range byteSizeUnits {
+		if number, ok := strings.CutSuffix(val, unit.suffix); ok {
+			val, multiplier = strings.TrimSpace(number), unit.multiplier
+			break
+		}
+	}
+
+	n, err := strconv.ParseInt(val, 10, 64)
+	if err != nil || n < 0 {
+		return fmt.Errorf("invalid byte size `%s`, expected e.g. 512KiB", val)
+	}
+
+	*b = ByteSize(n * multiplier)
+
+	return nil
+}
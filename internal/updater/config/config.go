@@ -2,15 +2,143 @@ package config
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"net/url"
+	"time"
 
 	"github.com/sethvargo/go-envconfig"
+
+	"github.com/danilevy1212/self-updater/internal/maintenance"
+	"github.com/danilevy1212/self-updater/internal/mirror"
+	"github.com/danilevy1212/self-updater/internal/models"
+	"github.com/danilevy1212/self-updater/internal/semver"
+)
+
+// BumpLevel is the largest version change the updater may apply on its own.
+type BumpLevel string
+
+const (
+	BumpMajor BumpLevel = "major"
+	BumpMinor BumpLevel = "minor"
+	BumpPatch BumpLevel = "patch"
+)
+
+// ManifestSource is where the signed release manifest is fetched from.
+type ManifestSource string
+
+const (
+	ManifestSourceGithub    ManifestSource = "github"
+	ManifestSourceHTTP      ManifestSource = "http"
+	ManifestSourceDirectory ManifestSource = "directory"
+	ManifestSourceS3        ManifestSource = "s3"
+	ManifestSourceOCI       ManifestSource = "oci"
 )
 
 type Config struct {
 	IsDev     bool   `env:"UPDATER_IS_DEV,default=false"`
 	Schedule  string `env:"UPDATER_CRON_SCHEDULE,default=* * * * *"`
 	RunAtBoot bool   `env:"UPDATER_RUN_AT_BOOT,default=true"`
+	// Set by the launcher for versions that failed their health check
+	RejectedVersions []string `env:"UPDATER_REJECTED_VERSIONS"`
+	AllowDowngrade   bool     `env:"UPDATER_ALLOW_DOWNGRADE,default=false"`
+	Channel          string   `env:"UPDATER_CHANNEL,default=stable"`
+	// Where state that must survive restarts is kept, nothing is persisted when empty
+	StateDirectory string `env:"UPDATER_STATE_DIRECTORY"`
+	// Update policy, e.g. to freeze a host during an incident
+	PinVersion   string    `env:"UPDATER_PIN_VERSION"`
+	AllowedBump  BumpLevel `env:"UPDATER_ALLOWED_BUMP,default=major"`
+	DenyVersions []string  `env:"UPDATER_DENY_VERSIONS"`
+	MinVersion   string    `env:"UPDATER_MIN_VERSION"`
+	// Verified updates are only applied within these windows, e.g. `Mon-Fri 02:00-04:00;Sat,Sun 00:00-06:00`.
+	// Updates are applied as soon as they are verified when empty.
+	MaintenanceWindows  []string `env:"UPDATER_MAINTENANCE_WINDOWS,delimiter=;"`
+	MaintenanceTimezone string   `env:"UPDATER_MAINTENANCE_TIMEZONE,default=Local"`
+	// Scheduled checks wait a random delay up to Jitter, and back off after consecutive failures
+	Jitter                time.Duration `env:"UPDATER_JITTER,default=0s"`
+	FailureBackoffInitial time.Duration `env:"UPDATER_FAILURE_BACKOFF_INITIAL,default=1m"`
+	FailureBackoffMax     time.Duration `env:"UPDATER_FAILURE_BACKOFF_MAX,default=1h"`
+	// Where the signed manifest is fetched from
+	ManifestSource ManifestSource `env:"UPDATER_MANIFEST_SOURCE,default=github"`
+	// Templates for the http source, see manifest.URLTemplateData for the available fields
+	ManifestURL          string `env:"UPDATER_MANIFEST_URL"`
+	ManifestSignatureURL string `env:"UPDATER_MANIFEST_SIGNATURE_URL"`
+	// Expected next to the manifest when empty
+	TimestampURL string `env:"UPDATER_TIMESTAMP_URL"`
+	// Refuse manifests without a timestamp, an expiry or a metadata version, once every release publishes them
+	RequireFreshMetadata bool `env:"UPDATER_REQUIRE_FRESH_METADATA,default=false"`
+	// Directory holding release.json and its signature for the directory source, e.g. a mounted volume
+	ManifestDirectory string `env:"UPDATER_MANIFEST_DIRECTORY"`
+	// S3-compatible bucket for the s3 source, e.g. MinIO with path-style addressing
+	S3Endpoint  string `env:"UPDATER_S3_ENDPOINT,default=https://s3.amazonaws.com"`
+	S3Region    string `env:"UPDATER_S3_REGION,default=us-east-1"`
+	S3Bucket    string `env:"UPDATER_S3_BUCKET"`
+	S3Prefix    string `env:"UPDATER_S3_PREFIX"`
+	S3PathStyle bool   `env:"UPDATER_S3_PATH_STYLE,default=false"`
+	// Requests to the bucket are anonymous without an access key
+	S3AccessKeyID     string `env:"AWS_ACCESS_KEY_ID"`
+	S3SecretAccessKey string `env:"AWS_SECRET_ACCESS_KEY"`
+	S3SessionToken    string `env:"AWS_SESSION_TOKEN"`
+	// OCI registry for the oci source, the tag defaults to the channel
+	OCIRegistry   string `env:"UPDATER_OCI_REGISTRY"`
+	OCIRepository string `env:"UPDATER_OCI_REPOSITORY"`
+	OCITag        string `env:"UPDATER_OCI_TAG"`
+	OCIUsername   string `env:"UPDATER_OCI_USERNAME"`
+	OCIPassword   string `env:"UPDATER_OCI_PASSWORD"`
+	OCIPlainHTTP  bool   `env:"UPDATER_OCI_PLAIN_HTTP,default=false"`
+	// Partial artifact downloads are kept in DownloadDirectory and resumed by the next check,
+	// so that large artifacts over slow links finish across checks. Downloads restart from zero when empty.
+	DownloadDirectory string        `env:"UPDATER_DOWNLOAD_DIRECTORY"`
+	DownloadTimeout   time.Duration `env:"UPDATER_DOWNLOAD_TIMEOUT,default=30s"`
+	// Artifact downloads are throttled to DownloadRateLimit per second when set, e.g. `512KiB`
+	DownloadRateLimit ByteSize      `env:"UPDATER_DOWNLOAD_RATE_LIMIT,default=0"`
+	ProgressInterval  time.Duration `env:"UPDATER_PROGRESS_INTERVAL,default=5s"`
+	// Failed downloads that may succeed later, e.g. server errors or rate limiting, are retried within the same check
+	DownloadRetries             int           `env:"UPDATER_DOWNLOAD_RETRIES,default=3"`
+	DownloadRetryBackoffInitial time.Duration `env:"UPDATER_DOWNLOAD_RETRY_BACKOFF_INITIAL,default=1s"`
+	DownloadRetryBackoffMax     time.Duration `env:"UPDATER_DOWNLOAD_RETRY_BACKOFF_MAX,default=30s"`
+	// Bundles are extracted within these limits
+	BundleMaxSize  ByteSize `env:"UPDATER_BUNDLE_MAX_SIZE,default=1GiB"`
+	BundleMaxFiles int      `env:"UPDATER_BUNDLE_MAX_FILES,default=10000"`
+	// Patch the running binary when the manifest has a patch from its version, instead of downloading the artifact
+	DeltaUpdates bool `env:"UPDATER_DELTA_UPDATES,default=true"`
+	// Fallback mirrors serving the current release like the source does, e.g. `https://mirror.example.com/{{.Repo}}`.
+	// They may use the same fields as UPDATER_MANIFEST_URL.
+	Mirrors        []string        `env:"UPDATER_MIRRORS"`
+	MirrorStrategy mirror.Strategy `env:"UPDATER_MIRROR_STRATEGY,default=ordered"`
+	// HTTP client for every request to release hosts, e.g. behind a corporate proxy with a private CA
+	HTTPProxy         string        `env:"UPDATER_HTTP_PROXY"`
+	HTTPCABundle      string        `env:"UPDATER_HTTP_CA_BUNDLE"`
+	HTTPClientCert    string        `env:"UPDATER_HTTP_CLIENT_CERT"`
+	HTTPClientKey     string        `env:"UPDATER_HTTP_CLIENT_KEY"`
+	HTTPTLSMinVersion string        `env:"UPDATER_HTTP_TLS_MIN_VERSION,default=1.2"`
+	HTTPTimeout       time.Duration `env:"UPDATER_HTTP_TIMEOUT,default=10s"`
+	// Where releases are published, the compiled in models.SourceInfo is used when empty
+	ArchiverBaseURL string `env:"ARCHIVER_BASE_URL"`
+	ArchiverOwner   string `env:"ARCHIVER_OWNER"`
+	ArchiverRepo    string `env:"ARCHIVER_REPO"`
+}
+
+// SourceInfo overrides the defaults with the configured ARCHIVER_* values.
+func (c *Config) SourceInfo(defaults models.SourceInfo) models.SourceInfo {
+	si := defaults
+
+	if c.ArchiverBaseURL != "" {
+		// Validated when loading the config.
+		u, _ := url.Parse(c.ArchiverBaseURL)
+		si.BaseURL = c.ArchiverBaseURL
+		si.Host = u.Host
+	}
+
+	if c.ArchiverOwner != "" {
+		si.Owner = c.ArchiverOwner
+	}
+
+	if c.ArchiverRepo != "" {
+		si.Name = c.ArchiverRepo
+	}
+
+	return si
 }
 
 type ConfigFunc func(context.Context) (*Config, error)
@@ -26,5 +154,88 @@ func fetchFromEnvironment(ctx context.Context) (*Config, error) {
 	if err := envconfig.Process(ctx, &cfg); err != nil {
 		return nil, fmt.Errorf("error loading config: %w", err)
 	}
+
+	switch cfg.AllowedBump {
+	case BumpMajor, BumpMinor, BumpPatch:
+	default:
+		return nil, fmt.Errorf("invalid allowed bump `%s`, expected one of major, minor or patch", cfg.AllowedBump)
+	}
+
+	if cfg.PinVersion != "" {
+		if _, err := semver.Parse(cfg.PinVersion); err != nil {
+			return nil, fmt.Errorf("invalid pin version: %w", err)
+		}
+	}
+
+	if cfg.MinVersion != "" {
+		if _, err := semver.Parse(cfg.MinVersion); err != nil {
+			return nil, fmt.Errorf("invalid min version: %w", err)
+		}
+	}
+
+	switch cfg.ManifestSource {
+	case ManifestSourceGithub:
+	case ManifestSourceHTTP:
+		if cfg.ManifestURL == "" {
+			return nil, errors.New("UPDATER_MANIFEST_URL is required by the http manifest source")
+		}
+	case ManifestSourceDirectory:
+		if cfg.ManifestDirectory == "" {
+			return nil, errors.New("UPDATER_MANIFEST_DIRECTORY is required by the directory manifest source")
+		}
+	case ManifestSourceS3:
+		if cfg.S3Bucket == "" {
+			return nil, errors.New("UPDATER_S3_BUCKET is required by the s3 manifest source")
+		}
+		if cfg.S3AccessKeyID != "" && cfg.S3SecretAccessKey == "" {
+			return nil, errors.New("AWS_SECRET_ACCESS_KEY is required along with AWS_ACCESS_KEY_ID")
+		}
+	case ManifestSourceOCI:
+		if cfg.OCIRegistry == "" || cfg.OCIRepository == "" {
+			return nil, errors.New("UPDATER_OCI_REGISTRY and UPDATER_OCI_REPOSITORY are required by the oci manifest source")
+		}
+		if cfg.OCITag == "" {
+			cfg.OCITag = cfg.Channel
+		}
+	default:
+		return nil, fmt.Errorf("invalid manifest source `%s`, expected one of github, http, directory, s3 or oci", cfg.ManifestSource)
+	}
+
+	switch cfg.HTTPTLSMinVersion {
+	case "1.2", "1.3":
+	default:
+		return nil, fmt.Errorf("invalid tls min version `%s`, expected one of 1.2 or 1.3", cfg.HTTPTLSMinVersion)
+	}
+
+	if (cfg.HTTPClientCert == "") != (cfg.HTTPClientKey == "") {
+		return nil, errors.New("UPDATER_HTTP_CLIENT_CERT and UPDATER_HTTP_CLIENT_KEY must be set together")
+	}
+
+	switch cfg.MirrorStrategy {
+	case mirror.StrategyOrdered, mirror.StrategyLatency:
+	default:
+		return nil, fmt.Errorf("invalid mirror strategy `%s`, expected one of ordered or latency", cfg.MirrorStrategy)
+	}
+
+	// Without a state directory, the metadata versions trusted are forgotten on restart.
+	if cfg.RequireFreshMetadata && cfg.StateDirectory == "" {
+		return nil, errors.New("UPDATER_REQUIRE_FRESH_METADATA needs UPDATER_STATE_DIRECTORY to remember the metadata versions trusted")
+	}
+
+	if cfg.BundleMaxSize <= 0 || cfg.BundleMaxFiles <= 0 {
+		return nil, errors.New("UPDATER_BUNDLE_MAX_SIZE and UPDATER_BUNDLE_MAX_FILES must be positive")
+	}
+
+	if cfg.ArchiverBaseURL != "" {
+		u, err := url.Parse(cfg.ArchiverBaseURL)
+		if err != nil || u.Scheme == "" || u.Host == "" {
+			return nil, fmt.Errorf("invalid archiver base url `%s`, expected e.g. https://github.com", cfg.ArchiverBaseURL)
+		}
+	}
+
+	if _, err := maintenance.NewSchedule(cfg.MaintenanceWindows, cfg.MaintenanceTimezone); err != nil {
+		return nil, fmt.Errorf("invalid maintenance windows: %w", err)
+	}
+
 	return &cfg, nil
 }
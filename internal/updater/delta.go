@@ -0,0 +1,120 @@
+package updater
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/rs/zerolog"
+
+	"github.com/danilevy1212/self-updater/internal/audit"
+	"github.com/danilevy1212/self-updater/internal/delta"
+	"github.com/danilevy1212/self-updater/internal/digest"
+	"github.com/danilevy1212/self-updater/internal/downloader"
+	"github.com/danilevy1212/self-updater/internal/models"
+)
+
+// fetchUpdate patches the running binary into the artifact when the manifest has a patch from the running
+// version, falling back to fetching the whole artifact when anything goes wrong. Returns the artifact with its hex digest.
+func (u *Updater) fetchUpdate(logger *zerolog.Logger, version string, artifact *models.Artifact) (*os.File, string, error) {
+	// Patches turn one executable into another, a bundle holds more than that.
+	if patch := artifact.PatchFrom(u.Meta.Version); patch != nil && u.Config.DeltaUpdates && artifact.Bundle == "" {
+		l := logger.With().
+			Str("patch_url", patch.URL).
+			Str("patch_from", patch.From).
+			Logger()
+
+		file, artifactDigestHex, err := u.fetchPatched(&l, version, artifact, patch)
+		if err == nil {
+			l.Info().
+				Str("artifact_file", file.Name()).
+				Msg("Patched running binary into artifact file")
+
+			return file, artifactDigestHex, nil
+		}
+
+		l.Warn().
+			Err(err).
+			Msg("Failed to update with delta patch, downloading the full artifact")
+	}
+
+	return u.fetchArtifact(logger, version, artifact)
+}
+
+// fetchPatched applies the patch to the running binary. The patch is verified before it is applied,
+// and the result is held to the artifact digest, like a downloaded artifact.
+func (u *Updater) fetchPatched(logger *zerolog.Logger, version string, artifact *models.Artifact, patch *models.Patch) (*os.File, string, error) {
+	// Patches only apply to the exact binary they were made from.
+	current, err := os.ReadFile(u.Meta.ExecutablePath)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to read running binary: %w", err)
+	}
+	if currentDigest := sha256.Sum256(current); !bytes.Equal(currentDigest[:], u.Meta.Digest) {
+		return nil, "", errors.New("running binary does not match its digest")
+	}
+
+	defer u.setProgress(nil)
+	file, err := downloader.Retry(u.requestContext(), u.retryPolicy(), u.logRetry(logger), func(ctx context.Context) (*downloader.File, error) {
+		ctx, cancel := u.downloadContext(ctx, logger, version, patch.URL, patch.Size)
+		defer cancel()
+
+		return u.download(ctx, patch.URL, artifact.Filename+".patch.*")
+	})
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to download patch: %w", err)
+	}
+	defer discardFile(file.File)
+
+	patchDigest := file.SHA256
+	if patchDigest == nil {
+		patchDigest, err = digest.DigestFile(file.Name())
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to calculate patch digest: %w", err)
+		}
+	}
+	if patchDigestHex := hex.EncodeToString(patchDigest); patchDigestHex != patch.Digest {
+		return nil, "", fmt.Errorf("patch digest %s does not match expected digest %s", patchDigestHex, patch.Digest)
+	}
+
+	isVerified, err := audit.VerifySignature(u.Keys.PEM(), patch.Digest, patch.SignatureBase64)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to verify patch signature: %w", err)
+	}
+	if !isVerified {
+		return nil, "", errors.New("patch signature verification failed, patch did not come from authors")
+	}
+
+	data, err := os.ReadFile(file.Name())
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to read patch: %w", err)
+	}
+
+	result, err := os.CreateTemp("", artifact.Filename+".*")
+	if err != nil {
+		return nil, "", fmt.Errorf("error creating temp file: %w", err)
+	}
+
+	hash := sha256.New()
+	if err := delta.Apply(bytes.NewReader(current), data, io.MultiWriter(result, hash)); err != nil {
+		discardFile(result)
+		return nil, "", fmt.Errorf("failed to apply patch: %w", err)
+	}
+
+	artifactDigestHex := hex.EncodeToString(hash.Sum(nil))
+	if artifactDigestHex != artifact.Digest {
+		discardFile(result)
+		return nil, "", fmt.Errorf("patched artifact digest %s does not match expected digest %s", artifactDigestHex, artifact.Digest)
+	}
+
+	if _, err := result.Seek(0, io.SeekStart); err != nil {
+		discardFile(result)
+		return nil, "", fmt.Errorf("error seeking to start of temp file: %w", err)
+	}
+
+	return result, artifactDigestHex, nil
+}
@@ -1,18 +1,15 @@
 package updater
 
 import (
-	"context"
-	"encoding/hex"
-	"os"
-	"time"
+	"slices"
 
 	"github.com/danilevy1212/self-updater/internal/audit"
-	"github.com/danilevy1212/self-updater/internal/digest"
-	"github.com/danilevy1212/self-updater/internal/downloader"
+	"github.com/danilevy1212/self-updater/internal/models"
 )
 
 func (u *Updater) Run() {
 	logger := u.Logger
+	defer u.settleFailures(logger)
 
 	logger.Info().
 		Str("version", u.Meta.Version).
@@ -24,21 +21,21 @@ func (u *Updater) Run() {
 	logger.Info().
 		Msg("fetching latest manifest")
 
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
-	defer cancel()
-
-	manifest, err := u.ManifestFetcher.FetchManifest(ctx)
+	manifest, err := u.fetchManifest(logger)
 	if err != nil {
 		logger.Error().
 			Err(err).
 			Msg("Failed to fetch manifest")
+
+		u.recordFailure()
 		return
 	}
 
-	if manifest.PublicKey != string(u.Meta.AuthorsPublicKey) {
+	// The manifest names the key currently signing releases, which must be one of the trusted keys.
+	if !u.Keys.Trusts(manifest.PublicKey) {
 		logger.Error().
 			Str("manifest_public_key", manifest.PublicKey).
-			Str("application_public_key", string(u.Meta.AuthorsPublicKey)).
+			Str("application_public_key", string(u.Keys.PEM())).
 			Msg("Manifest public key does not match application public key")
 
 		return
@@ -48,7 +45,21 @@ func (u *Updater) Run() {
 	//  - Is the current binary tampered? (Digest AND signature won't match)
 	//  - Is the current version in the manifest (Only check this if we are not in DEV mode)
 	// Should log out an error and stop in those cases.
-	latestVersion := manifest.Latest
+	latestVersion, err := manifest.LatestForChannel(u.Config.Channel)
+	if err != nil {
+		logger.Error().
+			Err(err).
+			Str("channel", u.Config.Channel).
+			Msg("Failed to get latest version for channel from manifest")
+
+		return
+	}
+	u.lowerRejectedHighWaterMark(logger)
+	u.raiseHighWaterMark(logger, u.Meta.Version)
+	latestVersion = u.targetVersion(logger, latestVersion)
+	// A staged update that is no longer the target must not be applied later.
+	u.discardStagedUnless(logger, latestVersion)
+
 	if u.Meta.Version == latestVersion {
 		logger.Info().
 			Msg("No updates available. Current version is up to date.")
@@ -56,72 +67,61 @@ func (u *Updater) Run() {
 		return
 	}
 
-	matchingVersion, err := manifest.GetVersionInfo(latestVersion)
-	if err != nil {
-		logger.Error().
-			Err(err).
-			Msg("Failed to get version info from manifest")
+	if slices.Contains(u.Config.RejectedVersions, latestVersion) {
+		logger.Warn().
+			Str("latest_version", latestVersion).
+			Msg("Latest version was rejected by the launcher after a failed health check, skipping update")
 
 		return
 	}
 
-	artifactForPlatform, err := matchingVersion.GetArtifactForPlatform(u.Meta.OS, u.Meta.Arch)
-	if err != nil {
-		logger.Error().
-			Err(err).
-			Msg("Failed to get artifact for platform from manifest")
-
+	if !u.allowedByPolicy(logger, latestVersion) {
 		return
 	}
 
-	ctxDownload, cancelDownload := context.WithTimeout(context.Background(), time.Second*30)
-	defer cancelDownload()
-	artifactFile, err := downloader.DownloadToTemporaryFile(
-		ctxDownload,
-		artifactForPlatform.URL,
-		artifactForPlatform.Filename+".*",
-	)
+	if !u.isUpgrade(logger, latestVersion) {
+		return
+	}
 
+	matchingVersion, err := manifest.GetVersionInfo(latestVersion)
 	if err != nil {
 		logger.Error().
 			Err(err).
-			Msg("Failed to download artifact file")
+			Msg("Failed to get version info from manifest")
 
 		return
 	}
 
-	cleanArtifactTmp := func() {
-		_ = artifactFile.Close()
-		_ = os.Remove(artifactFile.Name())
+	if !u.inRollout(logger, matchingVersion) {
+		u.discardStagedUnless(logger, "")
+		return
 	}
 
-	logger.Info().
-		Str("artifact_file", artifactFile.Name()).
-		Msg("Downloaded artifact file")
+	if u.applyStaged(logger, matchingVersion) {
+		return
+	}
 
-	artifactDigest, err := digest.DigestFile(artifactFile.Name())
+	artifactForPlatform, err := matchingVersion.GetArtifactForPlatform(u.Meta.OS, u.Meta.Arch)
 	if err != nil {
 		logger.Error().
 			Err(err).
-			Msg("Failed to calculate artifact file digest")
+			Msg("Failed to get artifact for platform from manifest")
 
-		cleanArtifactTmp()
 		return
 	}
 
-	artifactDigestHex := hex.EncodeToString(artifactDigest)
-	if artifactDigestHex != artifactForPlatform.Digest {
+	artifactFile, artifactDigestHex, err := u.fetchUpdate(logger, matchingVersion.Version, artifactForPlatform)
+	if err != nil {
 		logger.Error().
-			Str("expected_digest", artifactForPlatform.Digest).
-			Str("actual_digest", artifactDigestHex).
-			Msg("Artifact file digest does not match expected digest")
+			Err(err).
+			Msg("Failed to fetch a verified artifact file")
 
-		cleanArtifactTmp()
+		u.recordFailure()
 		return
 	}
 
 	isVerified, err := audit.VerifySignature(
-		u.Meta.AuthorsPublicKey,
+		u.Keys.PEM(),
 		artifactDigestHex,
 		artifactForPlatform.SignatureBase64,
 	)
@@ -130,21 +130,47 @@ func (u *Updater) Run() {
 			Err(err).
 			Msg("Failed to verify artifact signature")
 
-		cleanArtifactTmp()
+		discardFile(artifactFile)
 		return
 	}
 	if !isVerified {
 		logger.Error().
 			Msg("Artifact signature verification failed. Artifact did not come from authors")
 
-		cleanArtifactTmp()
+		discardFile(artifactFile)
 		return
 	}
 
-	l := u.Logger.With().
-		Str("handler", "OnUpgradeReady").
-		Str("artifact_file", artifactFile.Name()).
-		Logger()
+	release := models.StagedRelease{
+		Version: matchingVersion.Version,
+		Commit:  matchingVersion.Commit,
+		Digest:  artifactDigestHex,
+	}
+	if artifactForPlatform.Bundle != "" {
+		// From here on the release is a directory, the launcher checks its executable rather than the archive.
+		artifactFile, release.Digest, err = u.extractBundle(artifactFile, artifactForPlatform)
+		if err != nil {
+			logger.Error().
+				Err(err).
+				Str("bundle", artifactForPlatform.Bundle).
+				Msg("Failed to extract bundle")
+
+			return
+		}
+		release.Entrypoint = artifactForPlatform.Entrypoint
+
+		logger.Info().
+			Str("release_directory", artifactFile.Name()).
+			Str("entrypoint", release.Entrypoint).
+			Msg("Extracted bundle")
+	}
 
-	u.OnUpgradeReady(artifactFile, &l)
+	// Only versions this installation staged, rather than any it was offered, hold it back from now on.
+	// A retracted version it was never going to install must not keep it from older manifests.
+	u.raiseHighWaterMark(logger, release.Version)
+	u.stage(logger, &stagedUpdate{
+		file:     artifactFile,
+		release:  release,
+		critical: matchingVersion.Critical,
+	})
 }
@@ -1,22 +1,39 @@
 package updater
 
 import (
+	"archive/tar"
 	"bytes"
+	"compress/gzip"
 	"context"
+	"crypto/sha256"
 	"encoding/hex"
+	"encoding/json"
 	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
 	"testing"
+	"time"
 
 	"github.com/rs/zerolog"
 	"github.com/stretchr/testify/assert"
 
 	"github.com/danilevy1212/self-updater/internal/assets"
 	"github.com/danilevy1212/self-updater/internal/audit"
+	"github.com/danilevy1212/self-updater/internal/bundle"
+	"github.com/danilevy1212/self-updater/internal/compression"
+	"github.com/danilevy1212/self-updater/internal/delta"
 	"github.com/danilevy1212/self-updater/internal/digest"
 	"github.com/danilevy1212/self-updater/internal/downloader"
+	"github.com/danilevy1212/self-updater/internal/maintenance"
 	"github.com/danilevy1212/self-updater/internal/manifest"
 	"github.com/danilevy1212/self-updater/internal/models"
+	"github.com/danilevy1212/self-updater/internal/models/fixtures"
+	"github.com/danilevy1212/self-updater/internal/updater/config"
 )
 
 type ErrorFetcher struct{}
@@ -25,9 +42,42 @@ func (ef *ErrorFetcher) FetchManifest(ctx context.Context) (*models.ReleaseManif
 	return nil, errors.New("failed to fetch manifest")
 }
 
+type StubFetcher struct {
+	Manifest *models.ReleaseManifest
+}
+
+func (sf *StubFetcher) FetchManifest(ctx context.Context) (*models.ReleaseManifest, error) {
+	return sf.Manifest, nil
+}
+
+// ArtifactStubFetcher also resolves artifacts, like manifest.DirectoryManifestFetcher does.
+type ArtifactStubFetcher struct {
+	StubFetcher
+	Fetched []string
+	// Returned by FetchArtifact, an error of its own when nil
+	Err error
+}
+
+func (af *ArtifactStubFetcher) FetchArtifact(ctx context.Context, artifact *models.Artifact) (*downloader.File, error) {
+	af.Fetched = append(af.Fetched, artifact.URL)
+	if af.Err != nil {
+		return nil, af.Err
+	}
+	return nil, errors.New("artifact boom")
+}
+
+func fixtureManifest(t *testing.T) *models.ReleaseManifest {
+	var m models.ReleaseManifest
+	if err := json.Unmarshal(fixtures.ReleaseFixture, &m); err != nil {
+		t.Fatalf("failed to parse release fixture: %v", err)
+	}
+
+	return &m
+}
+
 func Test_Updater_Run(t *testing.T) {
 	t.Run("should return if application public key doesn't match manifests", func(t *testing.T) {
-		up, _ := New(context.Background(), models.ApplicationMeta{AuthorsPublicKey: []byte(`wrong`)}, func(newVersion *os.File, _ *zerolog.Logger) {
+		up, _ := New(context.Background(), models.ApplicationMeta{AuthorsPublicKey: []byte(`wrong`)}, func(newVersion *os.File, _ models.StagedRelease, _ *zerolog.Logger) {
 			assert.Fail(t, "should not call callback when public key doesn't match")
 		})
 
@@ -37,192 +87,1530 @@ func Test_Updater_Run(t *testing.T) {
 
 		up.Run()
 
-		assert.Contains(t, buf.String(), "Manifest public key does not match application public key")
+		assert.Contains(t, buf.String(), "Manifest public key does not match application public key")
+	})
+
+	t.Run("should return if application version matchest latest from manifest", func(t *testing.T) {
+		up, _ := New(context.Background(), models.ApplicationMeta{
+			AuthorsPublicKey: assets.PublicKeyPEM,
+			Version:          "v1.2.3",
+		}, func(newVersion *os.File, _ models.StagedRelease, _ *zerolog.Logger) {
+			assert.Fail(t, "should not call callback when version matches latest from manifest")
+		})
+
+		var buf bytes.Buffer
+		logger := zerolog.New(&buf)
+		up.Logger = &logger
+
+		up.Run()
+		assert.Contains(t, buf.String(), "No updates available. Current version is up to date.")
+	})
+
+	t.Run("should return if latest version was rejected by the launcher", func(t *testing.T) {
+		up, _ := New(context.Background(), models.ApplicationMeta{
+			AuthorsPublicKey: assets.PublicKeyPEM,
+			Version:          "v1.2.2",
+			OS:               "linux",
+			Arch:             "amd64",
+		}, func(newVersion *os.File, _ models.StagedRelease, _ *zerolog.Logger) {
+			assert.Fail(t, "should not call callback when latest version was rejected")
+		})
+		up.Config.RejectedVersions = []string{"v1.2.3"}
+
+		var buf bytes.Buffer
+		logger := zerolog.New(&buf)
+		up.Logger = &logger
+
+		up.Run()
+		assert.Contains(t, buf.String(), "Latest version was rejected by the launcher after a failed health check, skipping update")
+	})
+
+	t.Run("should refuse to downgrade to an older latest version", func(t *testing.T) {
+		up, _ := New(context.Background(), models.ApplicationMeta{
+			AuthorsPublicKey: assets.PublicKeyPEM,
+			Version:          "v1.3.0",
+			OS:               "linux",
+			Arch:             "amd64",
+		}, func(newVersion *os.File, _ models.StagedRelease, _ *zerolog.Logger) {
+			assert.Fail(t, "should not call callback when latest version is older")
+		})
+
+		var buf bytes.Buffer
+		logger := zerolog.New(&buf)
+		up.Logger = &logger
+
+		up.Run()
+		assert.Contains(t, buf.String(), "Candidate version is older than the current version, refusing to downgrade")
+	})
+
+	t.Run("should downgrade when policy allows it", func(t *testing.T) {
+		old := downloader.DownloadToTemporaryFile
+		defer func() {
+			downloader.DownloadToTemporaryFile = old
+		}()
+		downloader.DownloadToTemporaryFile = func(ctx context.Context, url, _ string) (*downloader.File, error) {
+			return nil, errors.New("download boom")
+		}
+
+		up, _ := New(context.Background(), models.ApplicationMeta{
+			AuthorsPublicKey: assets.PublicKeyPEM,
+			Version:          "v1.3.0",
+			OS:               "linux",
+			Arch:             "amd64",
+		}, func(newVersion *os.File, _ models.StagedRelease, _ *zerolog.Logger) {
+			assert.Fail(t, "should not call callback when download fails")
+		})
+		up.Config.AllowDowngrade = true
+
+		var buf bytes.Buffer
+		logger := zerolog.New(&buf)
+		up.Logger = &logger
+
+		up.Run()
+		assert.Contains(t, buf.String(), "downgrading as allowed by policy")
+		assert.Contains(t, buf.String(), "Failed to download artifact file")
+	})
+
+	t.Run("should skip versions deny-listed by policy", func(t *testing.T) {
+		up, _ := New(context.Background(), models.ApplicationMeta{
+			AuthorsPublicKey: assets.PublicKeyPEM,
+			Version:          "v1.2.2",
+		}, func(newVersion *os.File, _ models.StagedRelease, _ *zerolog.Logger) {
+			assert.Fail(t, "should not call callback when latest version is deny-listed")
+		})
+		up.Config.DenyVersions = []string{"v1.2.3"}
+
+		var buf bytes.Buffer
+		logger := zerolog.New(&buf)
+		up.Logger = &logger
+
+		up.Run()
+		assert.Contains(t, buf.String(), "Update skipped, candidate version is deny-listed by policy")
+	})
+
+	t.Run("should skip versions below the policy minimum", func(t *testing.T) {
+		up, _ := New(context.Background(), models.ApplicationMeta{
+			AuthorsPublicKey: assets.PublicKeyPEM,
+			Version:          "v1.2.2",
+		}, func(newVersion *os.File, _ models.StagedRelease, _ *zerolog.Logger) {
+			assert.Fail(t, "should not call callback when latest version is below the minimum")
+		})
+		up.Config.MinVersion = "v1.3.0"
+
+		var buf bytes.Buffer
+		logger := zerolog.New(&buf)
+		up.Logger = &logger
+
+		up.Run()
+		assert.Contains(t, buf.String(), "Update skipped, candidate version is below the minimum version allowed by policy")
+	})
+
+	t.Run("should skip bumps bigger than allowed by policy", func(t *testing.T) {
+		up, _ := New(context.Background(), models.ApplicationMeta{
+			AuthorsPublicKey: assets.PublicKeyPEM,
+			Version:          "v1.1.9",
+		}, func(newVersion *os.File, _ models.StagedRelease, _ *zerolog.Logger) {
+			assert.Fail(t, "should not call callback when the bump is not allowed")
+		})
+		up.Config.AllowedBump = config.BumpPatch
+
+		var buf bytes.Buffer
+		logger := zerolog.New(&buf)
+		up.Logger = &logger
+
+		up.Run()
+		assert.Contains(t, buf.String(), "Update skipped, candidate version is a bigger bump than allowed by policy")
+	})
+
+	t.Run("should allow bumps within the policy", func(t *testing.T) {
+		old := downloader.DownloadToTemporaryFile
+		defer func() {
+			downloader.DownloadToTemporaryFile = old
+		}()
+		downloader.DownloadToTemporaryFile = func(ctx context.Context, url, _ string) (*downloader.File, error) {
+			return nil, errors.New("download boom")
+		}
+
+		up, _ := New(context.Background(), models.ApplicationMeta{
+			AuthorsPublicKey: assets.PublicKeyPEM,
+			Version:          "v1.1.9",
+			OS:               "linux",
+			Arch:             "amd64",
+		}, func(newVersion *os.File, _ models.StagedRelease, _ *zerolog.Logger) {
+			assert.Fail(t, "should not call callback when download fails")
+		})
+		up.Config.AllowedBump = config.BumpMinor
+
+		var buf bytes.Buffer
+		logger := zerolog.New(&buf)
+		up.Logger = &logger
+
+		up.Run()
+		assert.Contains(t, buf.String(), "Failed to download artifact file")
+	})
+
+	t.Run("should update to the pinned version instead of latest", func(t *testing.T) {
+		old := downloader.DownloadToTemporaryFile
+		defer func() {
+			downloader.DownloadToTemporaryFile = old
+		}()
+		var downloadedURL string
+		downloader.DownloadToTemporaryFile = func(ctx context.Context, url, _ string) (*downloader.File, error) {
+			downloadedURL = url
+			return nil, errors.New("download boom")
+		}
+
+		up, _ := New(context.Background(), models.ApplicationMeta{
+			AuthorsPublicKey: assets.PublicKeyPEM,
+			Version:          "v1.2.1",
+			OS:               "linux",
+			Arch:             "amd64",
+		}, func(newVersion *os.File, _ models.StagedRelease, _ *zerolog.Logger) {
+			assert.Fail(t, "should not call callback when download fails")
+		})
+		up.Config.PinVersion = "v1.2.2"
+
+		var buf bytes.Buffer
+		logger := zerolog.New(&buf)
+		up.Logger = &logger
+
+		up.Run()
+		assert.Contains(t, buf.String(), "Installation is pinned to a version, ignoring the latest version of the channel")
+		assert.Contains(t, downloadedURL, "v1.2.2", "should download the pinned version instead of latest")
+	})
+
+	t.Run("should stay on the pinned version", func(t *testing.T) {
+		up, _ := New(context.Background(), models.ApplicationMeta{
+			AuthorsPublicKey: assets.PublicKeyPEM,
+			Version:          "v1.2.2",
+		}, func(newVersion *os.File, _ models.StagedRelease, _ *zerolog.Logger) {
+			assert.Fail(t, "should not call callback when running the pinned version")
+		})
+		up.Config.PinVersion = "v1.2.2"
+
+		var buf bytes.Buffer
+		logger := zerolog.New(&buf)
+		up.Logger = &logger
+
+		up.Run()
+		assert.Contains(t, buf.String(), "No updates available. Current version is up to date.")
+	})
+
+	t.Run("should refuse versions below the persisted high-water mark", func(t *testing.T) {
+		stateDir := t.TempDir()
+		assert.NoError(t, (&State{HighWaterMark: "v1.4.0"}).Save(stateDir))
+
+		up, _ := New(context.Background(), models.ApplicationMeta{
+			AuthorsPublicKey: assets.PublicKeyPEM,
+			Version:          "v1.2.2",
+			OS:               "linux",
+			Arch:             "amd64",
+		}, func(newVersion *os.File, _ models.StagedRelease, _ *zerolog.Logger) {
+			assert.Fail(t, "should not call callback when manifest is older than the high-water mark")
+		})
+		up.Config.StateDirectory = stateDir
+		up.State, _ = LoadState(stateDir)
+
+		var buf bytes.Buffer
+		logger := zerolog.New(&buf)
+		up.Logger = &logger
+
+		up.Run()
+		assert.Contains(t, buf.String(), "Candidate version is older than the highest version seen, refusing to roll back")
+	})
+
+	t.Run("should lower a high-water mark the launcher rejected to the running version", func(t *testing.T) {
+		stateDir := t.TempDir()
+		assert.NoError(t, (&State{HighWaterMark: "v1.4.0"}).Save(stateDir))
+
+		up, _ := New(context.Background(), models.ApplicationMeta{
+			AuthorsPublicKey: assets.PublicKeyPEM,
+			Version:          "v1.2.3",
+		}, func(newVersion *os.File, _ models.StagedRelease, _ *zerolog.Logger) {
+			assert.Fail(t, "should not call callback when version matches latest from manifest")
+		})
+		up.Config.StateDirectory = stateDir
+		up.Config.RejectedVersions = []string{"v1.4.0"}
+		up.State, _ = LoadState(stateDir)
+
+		var buf bytes.Buffer
+		logger := zerolog.New(&buf)
+		up.Logger = &logger
+
+		up.Run()
+		assert.Contains(t, buf.String(), "High-water mark was rejected by the launcher, lowering it to the running version")
+
+		state, err := LoadState(stateDir)
+		assert.NoError(t, err)
+		assert.Equal(t, "v1.2.3", state.HighWaterMark)
+	})
+
+	t.Run("should persist the highest version run", func(t *testing.T) {
+		stateDir := t.TempDir()
+
+		up, _ := New(context.Background(), models.ApplicationMeta{
+			AuthorsPublicKey: assets.PublicKeyPEM,
+			Version:          "v1.2.3",
+		}, func(newVersion *os.File, _ models.StagedRelease, _ *zerolog.Logger) {
+			assert.Fail(t, "should not call callback when version matches latest from manifest")
+		})
+		up.Config.StateDirectory = stateDir
+
+		var buf bytes.Buffer
+		logger := zerolog.New(&buf)
+		up.Logger = &logger
+
+		up.Run()
+
+		state, err := LoadState(stateDir)
+		assert.NoError(t, err)
+		assert.Equal(t, "v1.2.3", state.HighWaterMark)
+	})
+
+	t.Run("should follow the configured channel", func(t *testing.T) {
+		old := downloader.DownloadToTemporaryFile
+		defer func() {
+			downloader.DownloadToTemporaryFile = old
+		}()
+		var downloadedURL string
+		downloader.DownloadToTemporaryFile = func(ctx context.Context, url, _ string) (*downloader.File, error) {
+			downloadedURL = url
+			return nil, errors.New("download boom")
+		}
+
+		up, _ := New(context.Background(), models.ApplicationMeta{
+			AuthorsPublicKey: assets.PublicKeyPEM,
+			Version:          "v1.2.1",
+			OS:               "linux",
+			Arch:             "amd64",
+		}, func(newVersion *os.File, _ models.StagedRelease, _ *zerolog.Logger) {
+			assert.Fail(t, "should not call callback when download fails")
+		})
+		m := fixtureManifest(t)
+		m.Channels = map[string]string{models.ChannelBeta: "v1.2.2"}
+		up.ManifestFetcher = &StubFetcher{Manifest: m}
+		up.Config.Channel = models.ChannelBeta
+
+		var buf bytes.Buffer
+		logger := zerolog.New(&buf)
+		up.Logger = &logger
+
+		up.Run()
+		assert.Contains(t, downloadedURL, "v1.2.2", "should download the beta version instead of latest")
+	})
+
+	t.Run("should return if the configured channel is not in the manifest", func(t *testing.T) {
+		up, _ := New(context.Background(), models.ApplicationMeta{
+			AuthorsPublicKey: assets.PublicKeyPEM,
+			Version:          "v1.2.2",
+		}, func(newVersion *os.File, _ models.StagedRelease, _ *zerolog.Logger) {
+			assert.Fail(t, "should not call callback when channel is missing")
+		})
+		up.Config.Channel = models.ChannelNightly
+
+		var buf bytes.Buffer
+		logger := zerolog.New(&buf)
+		up.Logger = &logger
+
+		up.Run()
+		assert.Contains(t, buf.String(), "Failed to get latest version for channel from manifest")
+	})
+
+	t.Run("should skip the update when installation is outside the rollout", func(t *testing.T) {
+		up, _ := New(context.Background(), models.ApplicationMeta{
+			AuthorsPublicKey: assets.PublicKeyPEM,
+			Version:          "v1.2.2",
+			OS:               "linux",
+			Arch:             "amd64",
+		}, func(newVersion *os.File, _ models.StagedRelease, _ *zerolog.Logger) {
+			assert.Fail(t, "should not call callback when installation is outside the rollout")
+		})
+		m := fixtureManifest(t)
+		m.Versions[0].Rollout = &models.Rollout{Percentage: 0}
+		up.ManifestFetcher = &StubFetcher{Manifest: m}
+
+		var buf bytes.Buffer
+		logger := zerolog.New(&buf)
+		up.Logger = &logger
+
+		up.Run()
+		assert.Contains(t, buf.String(), "Installation is not part of the rollout for this version yet, skipping update")
+		assert.Equal(t, "v1.2.2", up.State.HighWaterMark, "should not raise the high-water mark to a version it doesn't install")
+	})
+
+	t.Run("should download once the rollout reaches the installation", func(t *testing.T) {
+		oldDownload := downloader.DownloadToTemporaryFile
+		oldNow := NowGenerator
+		defer func() {
+			downloader.DownloadToTemporaryFile = oldDownload
+			NowGenerator = oldNow
+		}()
+		downloaded := false
+		downloader.DownloadToTemporaryFile = func(ctx context.Context, url, _ string) (*downloader.File, error) {
+			downloaded = true
+			return nil, errors.New("download boom")
+		}
+		start := time.Date(2025, 3, 27, 0, 0, 0, 0, time.UTC)
+		NowGenerator = func() time.Time {
+			return start.Add(time.Hour)
+		}
+
+		up, _ := New(context.Background(), models.ApplicationMeta{
+			AuthorsPublicKey: assets.PublicKeyPEM,
+			Version:          "v1.2.2",
+			OS:               "linux",
+			Arch:             "amd64",
+		}, func(newVersion *os.File, _ models.StagedRelease, _ *zerolog.Logger) {
+			assert.Fail(t, "should not call callback when download fails")
+		})
+		m := fixtureManifest(t)
+		m.Versions[0].Rollout = &models.Rollout{
+			Percentage: 0,
+			Schedule:   []models.RolloutStep{{At: start, Percentage: 100}},
+		}
+		up.ManifestFetcher = &StubFetcher{Manifest: m}
+
+		var buf bytes.Buffer
+		logger := zerolog.New(&buf)
+		up.Logger = &logger
+
+		up.Run()
+		assert.Contains(t, buf.String(), "Installation is part of the rollout for this version")
+		assert.True(t, downloaded, "should download the artifact")
+	})
+
+	t.Run("should return if manifest fails to download", func(t *testing.T) {
+		old := ManifestFetcherFactory
+		defer func() {
+			ManifestFetcherFactory = old
+		}()
+		ManifestFetcherFactory = func(ctx context.Context, applicationMeta models.ApplicationMeta, _ *config.Config, logger *zerolog.Logger) (manifest.ManifestFetcher, error) {
+
+			return &ErrorFetcher{}, nil
+		}
+
+		up, _ := New(context.Background(), models.ApplicationMeta{
+			AuthorsPublicKey: assets.PublicKeyPEM,
+			Version:          "v1.2.2",
+		}, func(newVersion *os.File, _ models.StagedRelease, _ *zerolog.Logger) {
+			assert.Fail(t, "should not call callback when manifest fetch fails")
+		})
+
+		var buf bytes.Buffer
+		logger := zerolog.New(&buf)
+		assert.NotNil(t, logger, "Logger should not be nil")
+		up.Logger = &logger
+
+		up.Run()
+		assert.Contains(t, buf.String(), "Failed to fetch manifest")
+	})
+
+	t.Run("should return if artifact fetch fails", func(t *testing.T) {
+		old := downloader.DownloadToTemporaryFile
+		defer func() {
+			downloader.DownloadToTemporaryFile = old
+		}()
+		downloader.DownloadToTemporaryFile = func(ctx context.Context, url, _ string) (*downloader.File, error) {
+			return nil, errors.New("failed to fetch manifest")
+		}
+
+		up, _ := New(context.Background(), models.ApplicationMeta{
+			AuthorsPublicKey: assets.PublicKeyPEM,
+			Version:          "v1.2.2",
+		}, func(newVersion *os.File, _ models.StagedRelease, _ *zerolog.Logger) {
+			assert.Fail(t, "should not call callback when manifest fetch fails")
+		})
+
+		var buf bytes.Buffer
+		logger := zerolog.New(&buf)
+		up.Logger = &logger
+
+		up.Run()
+		assert.Contains(t, buf.String(), "Failed to get artifact for platform from manifest")
+	})
+
+	t.Run("should fetch artifacts through the manifest fetcher when it can", func(t *testing.T) {
+		old := downloader.DownloadToTemporaryFile
+		defer func() {
+			downloader.DownloadToTemporaryFile = old
+		}()
+		downloader.DownloadToTemporaryFile = func(ctx context.Context, url, _ string) (*downloader.File, error) {
+			assert.Fail(t, "should not download artifacts the manifest fetcher resolves")
+			return nil, errors.New("download boom")
+		}
+
+		up, _ := New(context.Background(), models.ApplicationMeta{
+			AuthorsPublicKey: assets.PublicKeyPEM,
+			Version:          "v1.2.2",
+			OS:               "linux",
+			Arch:             "amd64",
+		}, func(newVersion *os.File, _ models.StagedRelease, _ *zerolog.Logger) {
+			assert.Fail(t, "should not call callback when artifact fetch fails")
+		})
+		fetcher := &ArtifactStubFetcher{StubFetcher: StubFetcher{Manifest: fixtureManifest(t)}}
+		up.ManifestFetcher = fetcher
+
+		var buf bytes.Buffer
+		logger := zerolog.New(&buf)
+		up.Logger = &logger
+
+		up.Run()
+		assert.Len(t, fetcher.Fetched, 1)
+		assert.Contains(t, buf.String(), "Failed to download artifact file")
+	})
+
+	t.Run("should resume downloads in the download directory", func(t *testing.T) {
+		oldDownload := downloader.DownloadToTemporaryFile
+		oldResumable := downloader.DownloadResumable
+		defer func() {
+			downloader.DownloadToTemporaryFile = oldDownload
+			downloader.DownloadResumable = oldResumable
+		}()
+		downloader.DownloadToTemporaryFile = func(ctx context.Context, url, _ string) (*downloader.File, error) {
+			assert.Fail(t, "should not download from scratch with a download directory")
+			return nil, errors.New("download boom")
+		}
+		var dirs []string
+		downloader.DownloadResumable = func(ctx context.Context, dir, url, _ string) (*downloader.File, error) {
+			dirs = append(dirs, dir)
+			return nil, errors.New("connection reset")
+		}
+
+		up, _ := New(context.Background(), models.ApplicationMeta{
+			AuthorsPublicKey: assets.PublicKeyPEM,
+			Version:          "v1.2.2",
+			OS:               "linux",
+			Arch:             "amd64",
+		}, func(newVersion *os.File, _ models.StagedRelease, _ *zerolog.Logger) {
+			assert.Fail(t, "should not call callback when artifact download fails")
+		})
+		up.ManifestFetcher = &StubFetcher{Manifest: fixtureManifest(t)}
+		up.Config.DownloadDirectory = t.TempDir()
+
+		var buf bytes.Buffer
+		logger := zerolog.New(&buf)
+		up.Logger = &logger
+
+		up.Run()
+		assert.Equal(t, []string{up.Config.DownloadDirectory}, dirs)
+		assert.Contains(t, buf.String(), "Failed to download artifact file")
+	})
+
+	t.Run("should resume downloads of artifacts the manifest fetcher leaves to download", func(t *testing.T) {
+		oldResumable := downloader.DownloadResumable
+		defer func() {
+			downloader.DownloadResumable = oldResumable
+		}()
+		var urls []string
+		downloader.DownloadResumable = func(ctx context.Context, dir, url, _ string) (*downloader.File, error) {
+			urls = append(urls, url)
+			return nil, errors.New("connection reset")
+		}
+
+		up, _ := New(context.Background(), models.ApplicationMeta{
+			AuthorsPublicKey: assets.PublicKeyPEM,
+			Version:          "v1.2.2",
+			OS:               "linux",
+			Arch:             "amd64",
+		}, func(newVersion *os.File, _ models.StagedRelease, _ *zerolog.Logger) {
+			assert.Fail(t, "should not call callback when artifact download fails")
+		})
+		m := fixtureManifest(t)
+		fetcher := &ArtifactStubFetcher{StubFetcher: StubFetcher{Manifest: m}, Err: manifest.ErrDownloadArtifact}
+		up.ManifestFetcher = fetcher
+		up.Config.DownloadDirectory = t.TempDir()
+
+		var buf bytes.Buffer
+		logger := zerolog.New(&buf)
+		up.Logger = &logger
+
+		up.Run()
+		assert.Len(t, fetcher.Fetched, 1)
+		artifact, err := m.Versions[0].GetArtifactForPlatform("linux", "amd64")
+		assert.NoError(t, err)
+		assert.Equal(t, artifact.URL, urls[0])
+	})
+
+	t.Run("should retry transient download failures within the same check", func(t *testing.T) {
+		oldDownload := downloader.DownloadToTemporaryFile
+		defer func() {
+			downloader.DownloadToTemporaryFile = oldDownload
+		}()
+		var attempts int
+		downloader.DownloadToTemporaryFile = func(ctx context.Context, url, _ string) (*downloader.File, error) {
+			attempts++
+			if attempts == 1 {
+				return nil, &downloader.StatusError{URL: url, StatusCode: http.StatusServiceUnavailable}
+			}
+
+			return nil, &downloader.StatusError{URL: url, StatusCode: http.StatusNotFound}
+		}
+
+		up, _ := New(context.Background(), models.ApplicationMeta{
+			AuthorsPublicKey: assets.PublicKeyPEM,
+			Version:          "v1.2.2",
+			OS:               "linux",
+			Arch:             "amd64",
+		}, func(newVersion *os.File, _ models.StagedRelease, _ *zerolog.Logger) {
+			assert.Fail(t, "should not call callback when artifact download fails")
+		})
+		up.ManifestFetcher = &StubFetcher{Manifest: fixtureManifest(t)}
+		up.Config.DownloadRetryBackoffInitial = time.Millisecond
+
+		var buf bytes.Buffer
+		logger := zerolog.New(&buf)
+		up.Logger = &logger
+
+		up.Run()
+		assert.Equal(t, 2, attempts, "should retry the server error, but not the missing file")
+		assert.Contains(t, buf.String(), "Download failed, retrying")
+		assert.Contains(t, buf.String(), `"error_kind":"client"`)
+	})
+
+	t.Run("should not read the artifact again when it was hashed while downloading", func(t *testing.T) {
+		oldDownload := downloader.DownloadToTemporaryFile
+		oldVerify := audit.VerifySignature
+		oldDigest := digest.DigestFile
+		defer func() {
+			downloader.DownloadToTemporaryFile = oldDownload
+			audit.VerifySignature = oldVerify
+			digest.DigestFile = oldDigest
+		}()
+		downloader.DownloadToTemporaryFile = func(ctx context.Context, url, _ string) (*downloader.File, error) {
+			file, _ := os.CreateTemp(t.TempDir(), "artifact")
+			sum, _ := hex.DecodeString("aaaa3333")
+
+			return &downloader.File{File: file, SHA256: sum}, nil
+		}
+		digest.DigestFile = func(filePath string) ([]byte, error) {
+			assert.Fail(t, "should not read the downloaded artifact again")
+			return nil, errors.New("digest boom")
+		}
+		audit.VerifySignature = func(publicKeyPEM []byte, digestHex, signatureBase64 string) (bool, error) {
+			return true, nil
+		}
+
+		var staged models.StagedRelease
+		up, _ := New(context.Background(), models.ApplicationMeta{
+			AuthorsPublicKey: assets.PublicKeyPEM,
+			Version:          "v1.2.2",
+			OS:               "linux",
+			Arch:             "amd64",
+		}, func(newVersion *os.File, release models.StagedRelease, _ *zerolog.Logger) {
+			staged = release
+			_ = newVersion.Close()
+		})
+		up.ManifestFetcher = &StubFetcher{Manifest: fixtureManifest(t)}
+
+		var buf bytes.Buffer
+		logger := zerolog.New(&buf)
+		up.Logger = &logger
+
+		up.Run()
+		assert.Equal(t, "aaaa3333", staged.Digest)
+	})
+
+	t.Run("should report the progress of the artifact download", func(t *testing.T) {
+		oldDownload := downloader.DownloadToTemporaryFile
+		defer func() {
+			downloader.DownloadToTemporaryFile = oldDownload
+		}()
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, _ = w.Write(bytes.Repeat([]byte("a"), 1024))
+		}))
+		defer ts.Close()
+
+		var up *Updater
+		var during *models.DownloadProgress
+		downloader.DownloadToTemporaryFile = func(ctx context.Context, url, pattern string) (*downloader.File, error) {
+			file, err := oldDownload(ctx, ts.URL, pattern)
+			during = up.DownloadProgress()
+
+			return file, err
+		}
+
+		up, _ = New(context.Background(), models.ApplicationMeta{
+			AuthorsPublicKey: assets.PublicKeyPEM,
+			Version:          "v1.2.2",
+			OS:               "linux",
+			Arch:             "amd64",
+		}, func(newVersion *os.File, _ models.StagedRelease, _ *zerolog.Logger) {
+			_ = newVersion.Close()
+		})
+		up.ManifestFetcher = &StubFetcher{Manifest: fixtureManifest(t)}
+		up.Config.ProgressInterval = time.Millisecond
+
+		var buf bytes.Buffer
+		logger := zerolog.New(&buf)
+		up.Logger = &logger
+
+		up.Run()
+		if assert.NotNil(t, during) {
+			assert.Equal(t, "v1.2.3", during.Version)
+			assert.Equal(t, int64(1024), during.BytesDone)
+			assert.Equal(t, int64(1024), during.BytesTotal)
+		}
+		assert.Contains(t, buf.String(), "Downloading artifact")
+		assert.Nil(t, up.DownloadProgress(), "should clear the progress once the download is over")
+	})
+
+	t.Run("should return if artifact digest does not match", func(t *testing.T) {
+		oldDownload := downloader.DownloadToTemporaryFile
+		var fileName string
+		defer func() {
+			downloader.DownloadToTemporaryFile = oldDownload
+		}()
+		downloader.DownloadToTemporaryFile = func(ctx context.Context, url, _ string) (*downloader.File, error) {
+			file, _ := os.CreateTemp("", "artifact")
+			fileName = file.Name()
+			return &downloader.File{File: file}, nil
+		}
+
+		up, _ := New(context.Background(), models.ApplicationMeta{
+			AuthorsPublicKey: assets.PublicKeyPEM,
+			Version:          "v1.2.2",
+			OS:               "linux",
+			Arch:             "amd64",
+		}, func(newVersion *os.File, _ models.StagedRelease, _ *zerolog.Logger) {
+			assert.Fail(t, "should not call callback when artifact digest does not match")
+		})
+
+		var buf bytes.Buffer
+		logger := zerolog.New(&buf)
+		up.Logger = &logger
+
+		up.Run()
+		assert.Contains(t, buf.String(), "Artifact file digest does not match expected digest")
+		assert.NotEmpty(t, fileName, "Temporary artifact file should have been created")
+		assert.NoFileExists(t, fileName, "Temporary artifact file should not exist")
+	})
+
+	t.Run("should return if artifact signature verification fails", func(t *testing.T) {
+		oldDownload := downloader.DownloadToTemporaryFile
+		oldVerify := audit.VerifySignature
+		oldDigest := digest.DigestFile
+		var fileName string
+		defer func() {
+			downloader.DownloadToTemporaryFile = oldDownload
+			audit.VerifySignature = oldVerify
+			digest.DigestFile = oldDigest
+		}()
+		downloader.DownloadToTemporaryFile = func(ctx context.Context, url, _ string) (*downloader.File, error) {
+			file, _ := os.CreateTemp("", "artifact")
+			fileName = file.Name()
+			return &downloader.File{File: file}, nil
+		}
+		digest.DigestFile = func(filePath string) ([]byte, error) {
+			res, _ := hex.DecodeString("aaaa3333")
+
+			return res, nil
+		}
+		audit.VerifySignature = func(publicKeyPEM []byte, digestHex, signatureBase64 string) (bool, error) {
+			return false, nil
+		}
+
+		up, _ := New(context.Background(), models.ApplicationMeta{
+			AuthorsPublicKey: assets.PublicKeyPEM,
+			Version:          "v1.2.2",
+			OS:               "linux",
+			Arch:             "amd64",
+		}, func(newVersion *os.File, _ models.StagedRelease, _ *zerolog.Logger) {
+			assert.Fail(t, "should not call callback when artifact signature verification fails")
+		})
+
+		var buf bytes.Buffer
+		logger := zerolog.New(&buf)
+		up.Logger = &logger
+
+		up.Run()
+		assert.Contains(t, buf.String(), "Artifact signature verification failed. Artifact did not come from authors")
+		assert.NotEmpty(t, fileName, "Temporary artifact file should have been created")
+		assert.NoFileExists(t, fileName, "Temporary artifact file should not exist")
+	})
+
+	t.Run("should stage the update until a maintenance window opens", func(t *testing.T) {
+		oldDownload := downloader.DownloadToTemporaryFile
+		oldVerify := audit.VerifySignature
+		oldDigest := digest.DigestFile
+		oldNow := NowGenerator
+		defer func() {
+			downloader.DownloadToTemporaryFile = oldDownload
+			audit.VerifySignature = oldVerify
+			digest.DigestFile = oldDigest
+			NowGenerator = oldNow
+		}()
+		downloads := 0
+		downloader.DownloadToTemporaryFile = func(ctx context.Context, url, _ string) (*downloader.File, error) {
+			downloads++
+			file, err := os.CreateTemp(t.TempDir(), "artifact")
+			return &downloader.File{File: file}, err
+		}
+		digest.DigestFile = func(filePath string) ([]byte, error) {
+			return hex.DecodeString("aaaa3333")
+		}
+		audit.VerifySignature = func(publicKeyPEM []byte, digestHex, signatureBase64 string) (bool, error) {
+			return true, nil
+		}
+		// 2025-03-24 is a Monday.
+		now := time.Date(2025, 3, 24, 12, 0, 0, 0, time.UTC)
+		NowGenerator = func() time.Time {
+			return now
+		}
+
+		applied := 0
+		up, _ := New(context.Background(), models.ApplicationMeta{
+			AuthorsPublicKey: assets.PublicKeyPEM,
+			Version:          "v1.2.2",
+			OS:               "linux",
+			Arch:             "amd64",
+		}, func(newVersion *os.File, release models.StagedRelease, _ *zerolog.Logger) {
+			applied++
+			assert.Equal(t, "v1.2.3", release.Version)
+			_ = newVersion.Close()
+		})
+		up.Maintenance, _ = maintenance.NewSchedule([]string{"Sat 02:00-04:00"}, "UTC")
+
+		var buf bytes.Buffer
+		logger := zerolog.New(&buf)
+		up.Logger = &logger
+
+		up.Run()
+		assert.Contains(t, buf.String(), "Update staged, waiting for a maintenance window to apply it")
+		assert.Equal(t, 0, applied, "should not apply the update outside maintenance windows")
+		assert.Equal(t, "v1.2.3", up.State.HighWaterMark, "should raise the high-water mark to the staged version")
+
+		now = time.Date(2025, 3, 29, 2, 30, 0, 0, time.UTC)
+		up.Run()
+		assert.Equal(t, 1, applied, "should apply the staged update within the maintenance window")
+		assert.Equal(t, 1, downloads, "should not download the staged update again")
+	})
+
+	t.Run("should apply critical releases outside maintenance windows", func(t *testing.T) {
+		oldDownload := downloader.DownloadToTemporaryFile
+		oldVerify := audit.VerifySignature
+		oldDigest := digest.DigestFile
+		defer func() {
+			downloader.DownloadToTemporaryFile = oldDownload
+			audit.VerifySignature = oldVerify
+			digest.DigestFile = oldDigest
+		}()
+		downloader.DownloadToTemporaryFile = func(ctx context.Context, url, _ string) (*downloader.File, error) {
+			file, err := os.CreateTemp(t.TempDir(), "artifact")
+			return &downloader.File{File: file}, err
+		}
+		digest.DigestFile = func(filePath string) ([]byte, error) {
+			return hex.DecodeString("aaaa3333")
+		}
+		audit.VerifySignature = func(publicKeyPEM []byte, digestHex, signatureBase64 string) (bool, error) {
+			return true, nil
+		}
+
+		applied := false
+		up, _ := New(context.Background(), models.ApplicationMeta{
+			AuthorsPublicKey: assets.PublicKeyPEM,
+			Version:          "v1.2.2",
+			OS:               "linux",
+			Arch:             "amd64",
+		}, func(newVersion *os.File, _ models.StagedRelease, _ *zerolog.Logger) {
+			applied = true
+			_ = newVersion.Close()
+		})
+		// A window that is never open.
+		up.Maintenance = &maintenance.Schedule{Windows: []maintenance.Window{{}}, Location: time.UTC}
+		m := fixtureManifest(t)
+		m.Versions[0].Critical = true
+		up.ManifestFetcher = &StubFetcher{Manifest: m}
+
+		var buf bytes.Buffer
+		logger := zerolog.New(&buf)
+		up.Logger = &logger
+
+		up.Run()
+		assert.True(t, applied, "should apply critical releases right away")
+	})
+
+	t.Run("should call callback with new version file", func(t *testing.T) {
+		oldDownload := downloader.DownloadToTemporaryFile
+		oldVerify := audit.VerifySignature
+		oldDigest := digest.DigestFile
+		var fileName string
+		defer func() {
+			downloader.DownloadToTemporaryFile = oldDownload
+			audit.VerifySignature = oldVerify
+			digest.DigestFile = oldDigest
+		}()
+		downloader.DownloadToTemporaryFile = func(ctx context.Context, url, _ string) (*downloader.File, error) {
+			file, _ := os.CreateTemp("", "artifact")
+			fileName = file.Name()
+			return &downloader.File{File: file}, nil
+		}
+		digest.DigestFile = func(filePath string) ([]byte, error) {
+			res, _ := hex.DecodeString("aaaa3333")
+
+			return res, nil
+		}
+		audit.VerifySignature = func(publicKeyPEM []byte, digestHex, signatureBase64 string) (bool, error) {
+			return true, nil
+		}
+
+		up, _ := New(context.Background(), models.ApplicationMeta{
+			AuthorsPublicKey: assets.PublicKeyPEM,
+			Version:          "v1.2.2",
+			OS:               "linux",
+			Arch:             "amd64",
+		}, func(newVersion *os.File, release models.StagedRelease, _ *zerolog.Logger) {
+			assert.NotNil(t, newVersion, "Callback should be called with new version file")
+			assert.Equal(t, "v1.2.3", release.Version, "Callback should receive the staged version")
+			assert.Equal(t, "aaaa3333", release.Digest, "Callback should receive the staged digest")
+			assert.Equal(t, fileName, newVersion.Name(), "Callback should receive the correct new version file")
+			assert.FileExists(t, fileName, "New version file should exist")
+
+			_ = newVersion.Close()
+			_ = os.Remove(fileName)
+		})
+
+		var buf bytes.Buffer
+		logger := zerolog.New(&buf)
+		up.Logger = &logger
+
+		up.Run()
+		assert.Contains(t, buf.String(), "Downloaded artifact file")
+	})
+}
+
+func Test_rolloutBucket(t *testing.T) {
+	t.Run("should place an installation in the same bucket every time", func(t *testing.T) {
+		assert.Equal(t, rolloutBucket("installation", "v1.2.3"), rolloutBucket("installation", "v1.2.3"))
 	})
 
-	t.Run("should return if application version matchest latest from manifest", func(t *testing.T) {
+	t.Run("should spread installations evenly", func(t *testing.T) {
+		inCohort := 0
+		for i := range 1000 {
+			bucket := rolloutBucket(strconv.Itoa(i), "v1.2.3")
+			assert.GreaterOrEqual(t, bucket, float64(0))
+			assert.Less(t, bucket, float64(100))
+
+			if bucket < 25 {
+				inCohort++
+			}
+		}
+
+		assert.InDelta(t, 250, inCohort, 50, "about a quarter of installations should be in a 25% rollout")
+	})
+}
+
+func Test_Updater_runScheduled(t *testing.T) {
+	t.Run("should back off after consecutive failures", func(t *testing.T) {
+		oldNow := NowGenerator
+		defer func() {
+			NowGenerator = oldNow
+		}()
+		now := time.Date(2025, 3, 24, 12, 0, 0, 0, time.UTC)
+		NowGenerator = func() time.Time {
+			return now
+		}
+
+		up, _ := New(context.Background(), models.ApplicationMeta{}, func(newVersion *os.File, _ models.StagedRelease, _ *zerolog.Logger) {
+			assert.Fail(t, "should not call callback when manifest fetch fails")
+		})
+		up.ManifestFetcher = &ErrorFetcher{}
+		up.Config.FailureBackoffInitial = time.Minute
+		up.Config.FailureBackoffMax = time.Hour
+
+		var buf bytes.Buffer
+		logger := zerolog.New(&buf)
+		up.Logger = &logger
+
+		up.runScheduled()
+		assert.Equal(t, 1, up.Failures.Consecutive)
+		assert.WithinDuration(t, now.Add(time.Minute), up.Failures.RetryAt, 12*time.Second)
+		assert.Contains(t, buf.String(), "Update check failed, backing off")
+
+		buf.Reset()
+		up.runScheduled()
+		assert.Contains(t, buf.String(), "Skipping scheduled update check while backing off after failures")
+		assert.NotContains(t, buf.String(), "Running updater job")
+		assert.Equal(t, 1, up.Failures.Consecutive)
+
+		now = up.Failures.RetryAt
+		up.runScheduled()
+		assert.Equal(t, 2, up.Failures.Consecutive)
+		assert.WithinDuration(t, now.Add(2*time.Minute), up.Failures.RetryAt, 24*time.Second)
+	})
+
+	t.Run("should reset the backoff after a successful check", func(t *testing.T) {
 		up, _ := New(context.Background(), models.ApplicationMeta{
 			AuthorsPublicKey: assets.PublicKeyPEM,
 			Version:          "v1.2.3",
-		}, func(newVersion *os.File, _ *zerolog.Logger) {
-			assert.Fail(t, "should not call callback when version matches latest from manifest")
+		}, func(newVersion *os.File, _ models.StagedRelease, _ *zerolog.Logger) {
+			assert.Fail(t, "should not call callback when up to date")
 		})
+		up.Failures = failureBackoff{Consecutive: 3}
 
 		var buf bytes.Buffer
 		logger := zerolog.New(&buf)
 		up.Logger = &logger
 
-		up.Run()
-		assert.Contains(t, buf.String(), "No updates available. Current version is up to date.")
+		up.runScheduled()
+		assert.Equal(t, 0, up.Failures.Consecutive)
+		assert.Contains(t, buf.String(), "Update check succeeded, resetting failure backoff")
 	})
 
-	t.Run("should return if manifest fails to download", func(t *testing.T) {
-		old := ManifestFetcherFactory
+	t.Run("should wait a random jitter before checking", func(t *testing.T) {
+		oldJitter := JitterGenerator
 		defer func() {
-			ManifestFetcherFactory = old
+			JitterGenerator = oldJitter
 		}()
-		ManifestFetcherFactory = func(ctx context.Context, applicationMeta models.ApplicationMeta, logger *zerolog.Logger) (manifest.ManifestFetcher, error) {
-
-			return &ErrorFetcher{}, nil
+		var maxJitter time.Duration
+		JitterGenerator = func(max time.Duration) time.Duration {
+			maxJitter = max
+			return time.Millisecond
 		}
 
 		up, _ := New(context.Background(), models.ApplicationMeta{
 			AuthorsPublicKey: assets.PublicKeyPEM,
-			Version:          "v1.2.2",
-		}, func(newVersion *os.File, _ *zerolog.Logger) {
-			assert.Fail(t, "should not call callback when manifest fetch fails")
+			Version:          "v1.2.3",
+		}, func(newVersion *os.File, _ models.StagedRelease, _ *zerolog.Logger) {
+			assert.Fail(t, "should not call callback when up to date")
 		})
+		up.Config.Jitter = 30 * time.Second
 
 		var buf bytes.Buffer
 		logger := zerolog.New(&buf)
-		assert.NotNil(t, logger, "Logger should not be nil")
 		up.Logger = &logger
 
-		up.Run()
-		assert.Contains(t, buf.String(), "Failed to fetch manifest")
+		up.runScheduled()
+		assert.Equal(t, 30*time.Second, maxJitter)
+		assert.Contains(t, buf.String(), "Running updater job")
 	})
 
-	t.Run("should return if artifact fetch fails", func(t *testing.T) {
-		old := downloader.DownloadToTemporaryFile
+	t.Run("should not check once stopped while waiting out the jitter", func(t *testing.T) {
+		oldJitter := JitterGenerator
 		defer func() {
-			downloader.DownloadToTemporaryFile = old
+			JitterGenerator = oldJitter
 		}()
-		downloader.DownloadToTemporaryFile = func(ctx context.Context, url, _ string) (*os.File, error) {
-			return nil, errors.New("failed to fetch manifest")
+		JitterGenerator = func(max time.Duration) time.Duration {
+			return time.Hour
 		}
 
+		up, _ := New(context.Background(), models.ApplicationMeta{}, func(newVersion *os.File, _ models.StagedRelease, _ *zerolog.Logger) {
+			assert.Fail(t, "should not call callback when stopped")
+		})
+
+		var buf bytes.Buffer
+		logger := zerolog.New(&buf)
+		up.Logger = &logger
+
+		<-up.Stop().Done()
+		up.runScheduled()
+		assert.NotContains(t, buf.String(), "Running updater job")
+	})
+}
+
+func Test_Updater_mirrors(t *testing.T) {
+	t.Run("should fall back to a mirror when the manifest source fails", func(t *testing.T) {
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch r.URL.Path {
+			case "/self-updater/release.json":
+				_, _ = w.Write(fixtures.ReleaseFixture)
+			case "/self-updater/release.json.sig.base64":
+				_, _ = w.Write(fixtures.ReleaseSignatureFixture)
+			default:
+				w.WriteHeader(http.StatusNotFound)
+			}
+		}))
+		defer ts.Close()
+
 		up, _ := New(context.Background(), models.ApplicationMeta{
 			AuthorsPublicKey: assets.PublicKeyPEM,
-			Version:          "v1.2.2",
-		}, func(newVersion *os.File, _ *zerolog.Logger) {
-			assert.Fail(t, "should not call callback when manifest fetch fails")
+			Version:          "v1.2.3",
+		}, func(newVersion *os.File, _ models.StagedRelease, _ *zerolog.Logger) {
+			assert.Fail(t, "should not call callback when up to date")
 		})
+		up.ManifestFetcher = &ErrorFetcher{}
+		up.Mirrors = []string{ts.URL + "/missing", ts.URL + "/self-updater"}
 
 		var buf bytes.Buffer
 		logger := zerolog.New(&buf)
 		up.Logger = &logger
 
 		up.Run()
-		assert.Contains(t, buf.String(), "Failed to get artifact for platform from manifest")
+		assert.Contains(t, buf.String(), "Failed to fetch manifest from mirror")
+		assert.Contains(t, buf.String(), "Fetched manifest from mirror")
+		assert.Contains(t, buf.String(), "No updates available")
 	})
 
-	t.Run("should return if artifact digest does not match", func(t *testing.T) {
+	t.Run("should fall back to mirrors until an artifact matches its digest", func(t *testing.T) {
+		const binary = "new binary"
+		sum := sha256.Sum256([]byte(binary))
+
+		m := fixtureManifest(t)
+		for i, v := range m.Versions {
+			for j, a := range v.Artifacts {
+				if v.Version == "v1.2.3" && a.OS == "linux" && a.Arch == "amd64" {
+					m.Versions[i].Artifacts[j].Digest = hex.EncodeToString(sum[:])
+					m.Versions[i].Artifacts[j].Mirrors = []string{"https://unreachable.example.com/api-linux-amd64"}
+				}
+			}
+		}
+
 		oldDownload := downloader.DownloadToTemporaryFile
-		var fileName string
+		oldVerify := audit.VerifySignature
 		defer func() {
 			downloader.DownloadToTemporaryFile = oldDownload
+			audit.VerifySignature = oldVerify
 		}()
-		downloader.DownloadToTemporaryFile = func(ctx context.Context, url, _ string) (*os.File, error) {
+		var downloaded []string
+		downloader.DownloadToTemporaryFile = func(ctx context.Context, url, _ string) (*downloader.File, error) {
+			downloaded = append(downloaded, url)
+			contents := binary
+			switch url {
+			case "https://unreachable.example.com/api-linux-amd64":
+				return nil, errors.New("download boom")
+			case "https://mirror.example.com/api-linux-amd64":
+			default:
+				contents = "tampered binary"
+			}
+
 			file, _ := os.CreateTemp("", "artifact")
-			fileName = file.Name()
-			return file, nil
+			_, _ = file.WriteString(contents)
+			_, _ = file.Seek(0, 0)
+			return &downloader.File{File: file}, nil
+		}
+		audit.VerifySignature = func(publicKeyPEM []byte, digestHex, signatureBase64 string) (bool, error) {
+			return true, nil
 		}
 
+		var got string
 		up, _ := New(context.Background(), models.ApplicationMeta{
 			AuthorsPublicKey: assets.PublicKeyPEM,
 			Version:          "v1.2.2",
 			OS:               "linux",
 			Arch:             "amd64",
-		}, func(newVersion *os.File, _ *zerolog.Logger) {
-			assert.Fail(t, "should not call callback when artifact digest does not match")
+		}, func(newVersion *os.File, _ models.StagedRelease, _ *zerolog.Logger) {
+			contents, _ := io.ReadAll(newVersion)
+			got = string(contents)
+
+			_ = newVersion.Close()
+			_ = os.Remove(newVersion.Name())
 		})
+		up.ManifestFetcher = &StubFetcher{Manifest: m}
+		up.Mirrors = []string{"https://mirror.example.com"}
 
 		var buf bytes.Buffer
 		logger := zerolog.New(&buf)
 		up.Logger = &logger
 
 		up.Run()
+		assert.Len(t, downloaded, 3)
 		assert.Contains(t, buf.String(), "Artifact file digest does not match expected digest")
-		assert.NotEmpty(t, fileName, "Temporary artifact file should have been created")
-		assert.NoFileExists(t, fileName, "Temporary artifact file should not exist")
+		assert.Equal(t, binary, got)
 	})
+}
+
+func Test_Updater_delta(t *testing.T) {
+	running := bytes.Repeat([]byte("running binary "), 4096)
+	next := append([]byte("next "), running...)
+	nextSum := sha256.Sum256(next)
+	runningSum := sha256.Sum256(running)
+
+	const patchURL = "https://example.com/api-linux-amd64.from-v1.2.2.patch"
+	patch, err := delta.Diff(running, next)
+	assert.NoError(t, err)
+
+	setup := func(t *testing.T, patch []byte) (*Updater, *[]string, *[]byte) {
+		patchSum := sha256.Sum256(patch)
+		m := fixtureManifest(t)
+		for i, v := range m.Versions {
+			for j, a := range v.Artifacts {
+				if v.Version == "v1.2.3" && a.OS == "linux" && a.Arch == "amd64" {
+					m.Versions[i].Artifacts[j].Digest = hex.EncodeToString(nextSum[:])
+					m.Versions[i].Artifacts[j].Patches = []models.Patch{{
+						From:   "v1.2.2",
+						URL:    patchURL,
+						Digest: hex.EncodeToString(patchSum[:]),
+					}}
+				}
+			}
+		}
 
-	t.Run("should return if artifact signature verification fails", func(t *testing.T) {
 		oldDownload := downloader.DownloadToTemporaryFile
 		oldVerify := audit.VerifySignature
-		oldDigest := digest.DigestFile
-		var fileName string
-		defer func() {
+		t.Cleanup(func() {
 			downloader.DownloadToTemporaryFile = oldDownload
 			audit.VerifySignature = oldVerify
-			digest.DigestFile = oldDigest
-		}()
-		downloader.DownloadToTemporaryFile = func(ctx context.Context, url, _ string) (*os.File, error) {
-			file, _ := os.CreateTemp("", "artifact")
-			fileName = file.Name()
-			return file, nil
-		}
-		digest.DigestFile = func(filePath string) ([]byte, error) {
-			res, _ := hex.DecodeString("aaaa3333")
+		})
+		var downloaded []string
+		downloader.DownloadToTemporaryFile = func(ctx context.Context, url, _ string) (*downloader.File, error) {
+			downloaded = append(downloaded, url)
+			contents := next
+			if url == patchURL {
+				contents = patch
+			}
 
-			return res, nil
+			file, _ := os.CreateTemp(t.TempDir(), "download")
+			_, _ = file.Write(contents)
+			_, _ = file.Seek(0, io.SeekStart)
+			return &downloader.File{File: file}, nil
 		}
 		audit.VerifySignature = func(publicKeyPEM []byte, digestHex, signatureBase64 string) (bool, error) {
-			return false, nil
+			return true, nil
 		}
 
+		executablePath := filepath.Join(t.TempDir(), "api")
+		assert.NoError(t, os.WriteFile(executablePath, running, 0o700))
+
+		var got []byte
 		up, _ := New(context.Background(), models.ApplicationMeta{
 			AuthorsPublicKey: assets.PublicKeyPEM,
 			Version:          "v1.2.2",
 			OS:               "linux",
 			Arch:             "amd64",
-		}, func(newVersion *os.File, _ *zerolog.Logger) {
-			assert.Fail(t, "should not call callback when artifact signature verification fails")
+			ExecutablePath:   executablePath,
+			Digest:           runningSum[:],
+		}, func(newVersion *os.File, _ models.StagedRelease, _ *zerolog.Logger) {
+			got, _ = io.ReadAll(newVersion)
+
+			_ = newVersion.Close()
+			_ = os.Remove(newVersion.Name())
 		})
+		up.ManifestFetcher = &StubFetcher{Manifest: m}
+
+		return up, &downloaded, &got
+	}
+
+	t.Run("should patch the running binary instead of downloading the artifact", func(t *testing.T) {
+		up, downloaded, got := setup(t, patch)
 
 		var buf bytes.Buffer
 		logger := zerolog.New(&buf)
 		up.Logger = &logger
 
 		up.Run()
-		assert.Contains(t, buf.String(), "Artifact signature verification failed. Artifact did not come from authors")
-		assert.NotEmpty(t, fileName, "Temporary artifact file should have been created")
-		assert.NoFileExists(t, fileName, "Temporary artifact file should not exist")
+		assert.Equal(t, []string{patchURL}, *downloaded)
+		assert.Contains(t, buf.String(), "Patched running binary into artifact file")
+		assert.Equal(t, next, *got)
 	})
 
-	t.Run("should call callback with new version file", func(t *testing.T) {
+	t.Run("should download the full artifact when the patched binary does not match its digest", func(t *testing.T) {
+		wrongPatch, err := delta.Diff(running, []byte("something else"))
+		assert.NoError(t, err)
+		up, downloaded, got := setup(t, wrongPatch)
+
+		var buf bytes.Buffer
+		logger := zerolog.New(&buf)
+		up.Logger = &logger
+
+		up.Run()
+		assert.Len(t, *downloaded, 2)
+		assert.Contains(t, buf.String(), "Failed to update with delta patch, downloading the full artifact")
+		assert.Contains(t, buf.String(), "patched artifact digest")
+		assert.Equal(t, next, *got)
+	})
+
+	t.Run("should not patch a running binary that does not match its digest", func(t *testing.T) {
+		up, downloaded, got := setup(t, patch)
+		assert.NoError(t, os.WriteFile(up.Meta.ExecutablePath, []byte("tampered"), 0o700))
+
+		var buf bytes.Buffer
+		logger := zerolog.New(&buf)
+		up.Logger = &logger
+
+		up.Run()
+		assert.NotContains(t, *downloaded, patchURL)
+		assert.Contains(t, buf.String(), "running binary does not match its digest")
+		assert.Equal(t, next, *got)
+	})
+
+	t.Run("should download the full artifact when delta updates are disabled", func(t *testing.T) {
+		up, downloaded, got := setup(t, patch)
+		up.Config.DeltaUpdates = false
+
+		var buf bytes.Buffer
+		logger := zerolog.New(&buf)
+		up.Logger = &logger
+
+		up.Run()
+		assert.NotContains(t, *downloaded, patchURL)
+		assert.Equal(t, next, *got)
+	})
+}
+
+func Test_Updater_compression(t *testing.T) {
+	const binary = "new binary"
+	var compressed bytes.Buffer
+	zw := gzip.NewWriter(&compressed)
+	_, _ = zw.Write([]byte(binary))
+	_ = zw.Close()
+	binarySum := sha256.Sum256([]byte(binary))
+	compressedSum := sha256.Sum256(compressed.Bytes())
+
+	setup := func(t *testing.T, update func(a *models.Artifact)) (*Updater, *[]string, *string, *string) {
+		m := fixtureManifest(t)
+		for i, v := range m.Versions {
+			for j, a := range v.Artifacts {
+				if v.Version == "v1.2.3" && a.OS == "linux" && a.Arch == "amd64" {
+					artifact := &m.Versions[i].Artifacts[j]
+					artifact.Filename = "api-linux-amd64.gz"
+					artifact.Digest = hex.EncodeToString(binarySum[:])
+					artifact.Compression = compression.Gzip
+					artifact.CompressedDigest = hex.EncodeToString(compressedSum[:])
+					update(artifact)
+				}
+			}
+		}
+
 		oldDownload := downloader.DownloadToTemporaryFile
 		oldVerify := audit.VerifySignature
-		oldDigest := digest.DigestFile
-		var fileName string
-		defer func() {
+		t.Cleanup(func() {
 			downloader.DownloadToTemporaryFile = oldDownload
 			audit.VerifySignature = oldVerify
-			digest.DigestFile = oldDigest
-		}()
-		downloader.DownloadToTemporaryFile = func(ctx context.Context, url, _ string) (*os.File, error) {
-			file, _ := os.CreateTemp("", "artifact")
-			fileName = file.Name()
-			return file, nil
+		})
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, _ = w.Write(compressed.Bytes())
+		}))
+		t.Cleanup(ts.Close)
+		var downloaded []string
+		downloader.DownloadToTemporaryFile = func(ctx context.Context, url, pattern string) (*downloader.File, error) {
+			downloaded = append(downloaded, url)
+
+			// Decompressed as it is downloaded, like any artifact.
+			return oldDownload(ctx, ts.URL, pattern)
+		}
+		var verifiedDigest string
+		audit.VerifySignature = func(publicKeyPEM []byte, digestHex, signatureBase64 string) (bool, error) {
+			verifiedDigest = digestHex
+			return true, nil
 		}
-		digest.DigestFile = func(filePath string) ([]byte, error) {
-			res, _ := hex.DecodeString("aaaa3333")
 
-			return res, nil
+		var got string
+		up, _ := New(context.Background(), models.ApplicationMeta{
+			AuthorsPublicKey: assets.PublicKeyPEM,
+			Version:          "v1.2.2",
+			OS:               "linux",
+			Arch:             "amd64",
+		}, func(newVersion *os.File, _ models.StagedRelease, _ *zerolog.Logger) {
+			contents, _ := io.ReadAll(newVersion)
+			got = string(contents)
+
+			_ = newVersion.Close()
+			_ = os.Remove(newVersion.Name())
+		})
+		up.ManifestFetcher = &StubFetcher{Manifest: m}
+
+		return up, &downloaded, &verifiedDigest, &got
+	}
+
+	t.Run("should decompress the artifact and verify the signature of the executable", func(t *testing.T) {
+		up, _, verifiedDigest, got := setup(t, func(a *models.Artifact) {})
+
+		var buf bytes.Buffer
+		logger := zerolog.New(&buf)
+		up.Logger = &logger
+
+		up.Run()
+		assert.Contains(t, buf.String(), "Decompressed artifact file")
+		assert.Equal(t, hex.EncodeToString(binarySum[:]), *verifiedDigest)
+		assert.Equal(t, binary, *got)
+	})
+
+	t.Run("should not stage an artifact that decompresses to another executable", func(t *testing.T) {
+		up, _, _, got := setup(t, func(a *models.Artifact) {
+			a.Digest = "aaaa3333"
+		})
+
+		var buf bytes.Buffer
+		logger := zerolog.New(&buf)
+		up.Logger = &logger
+
+		up.Run()
+		assert.Contains(t, buf.String(), "decompressed artifact digest")
+		assert.Empty(t, *got)
+	})
+
+	t.Run("should not download artifacts with an unsupported compression", func(t *testing.T) {
+		up, downloaded, _, got := setup(t, func(a *models.Artifact) {
+			a.Compression = "brotli"
+		})
+
+		var buf bytes.Buffer
+		logger := zerolog.New(&buf)
+		up.Logger = &logger
+
+		up.Run()
+		assert.Empty(t, *downloaded)
+		assert.Contains(t, buf.String(), "unsupported compression `brotli`")
+		assert.Empty(t, *got)
+	})
+}
+
+func Test_Updater_bundle(t *testing.T) {
+	const binary = "new binary"
+	var archive bytes.Buffer
+	zw := gzip.NewWriter(&archive)
+	tw := tar.NewWriter(zw)
+	for name, body := range map[string]string{"bin/api": binary, "web/index.html": "<html></html>"} {
+		_ = tw.WriteHeader(&tar.Header{Name: name, Mode: 0o755, Size: int64(len(body)), Typeflag: tar.TypeReg})
+		_, _ = tw.Write([]byte(body))
+	}
+	_ = tw.Close()
+	_ = zw.Close()
+	binarySum := sha256.Sum256([]byte(binary))
+	archiveSum := sha256.Sum256(archive.Bytes())
+
+	setup := func(t *testing.T, update func(a *models.Artifact)) (*Updater, *[]string, *string, *models.StagedRelease, *map[string]string) {
+		m := fixtureManifest(t)
+		for i, v := range m.Versions {
+			for j, a := range v.Artifacts {
+				if v.Version == "v1.2.3" && a.OS == "linux" && a.Arch == "amd64" {
+					artifact := &m.Versions[i].Artifacts[j]
+					artifact.Filename = "api-linux-amd64.tar.gz"
+					artifact.Digest = hex.EncodeToString(archiveSum[:])
+					artifact.Bundle = bundle.FormatTarGz
+					artifact.Entrypoint = "bin/api"
+					update(artifact)
+				}
+			}
+		}
+
+		oldDownload := downloader.DownloadToTemporaryFile
+		oldVerify := audit.VerifySignature
+		t.Cleanup(func() {
+			downloader.DownloadToTemporaryFile = oldDownload
+			audit.VerifySignature = oldVerify
+		})
+		var downloaded []string
+		downloader.DownloadToTemporaryFile = func(ctx context.Context, url, _ string) (*downloader.File, error) {
+			downloaded = append(downloaded, url)
+
+			file, _ := os.CreateTemp(t.TempDir(), "download")
+			_, _ = file.Write(archive.Bytes())
+			_, _ = file.Seek(0, io.SeekStart)
+			return &downloader.File{File: file, SHA256: archiveSum[:]}, nil
 		}
+		var verifiedDigest string
 		audit.VerifySignature = func(publicKeyPEM []byte, digestHex, signatureBase64 string) (bool, error) {
+			verifiedDigest = digestHex
 			return true, nil
 		}
 
+		var staged models.StagedRelease
+		got := map[string]string{}
 		up, _ := New(context.Background(), models.ApplicationMeta{
 			AuthorsPublicKey: assets.PublicKeyPEM,
 			Version:          "v1.2.2",
 			OS:               "linux",
 			Arch:             "amd64",
-		}, func(newVersion *os.File, _ *zerolog.Logger) {
-			assert.NotNil(t, newVersion, "Callback should be called with new version file")
-			assert.Equal(t, fileName, newVersion.Name(), "Callback should receive the correct new version file")
-			assert.FileExists(t, fileName, "New version file should exist")
+		}, func(newVersion *os.File, release models.StagedRelease, _ *zerolog.Logger) {
+			staged = release
+			for _, name := range []string{"bin/api", "web/index.html"} {
+				contents, _ := os.ReadFile(filepath.Join(newVersion.Name(), name))
+				got[name] = string(contents)
+			}
 
 			_ = newVersion.Close()
-			_ = os.Remove(fileName)
+			_ = os.RemoveAll(newVersion.Name())
 		})
+		up.ManifestFetcher = &StubFetcher{Manifest: m}
+
+		return up, &downloaded, &verifiedDigest, &staged, &got
+	}
+
+	t.Run("should extract the bundle and stage its directory with the digest of the entrypoint", func(t *testing.T) {
+		up, _, verifiedDigest, staged, got := setup(t, func(a *models.Artifact) {})
 
 		var buf bytes.Buffer
 		logger := zerolog.New(&buf)
 		up.Logger = &logger
 
 		up.Run()
-		assert.Contains(t, buf.String(), "Downloaded artifact file")
+		assert.Contains(t, buf.String(), "Extracted bundle")
+		assert.Equal(t, hex.EncodeToString(archiveSum[:]), *verifiedDigest, "should verify the signature of the bundle")
+		assert.Equal(t, hex.EncodeToString(binarySum[:]), staged.Digest)
+		assert.Equal(t, "bin/api", staged.Entrypoint)
+		assert.Equal(t, map[string]string{"bin/api": binary, "web/index.html": "<html></html>"}, *got)
+	})
+
+	t.Run("should not stage a bundle without its entrypoint", func(t *testing.T) {
+		up, _, _, staged, _ := setup(t, func(a *models.Artifact) {
+			a.Entrypoint = "bin/server"
+		})
+
+		var buf bytes.Buffer
+		logger := zerolog.New(&buf)
+		up.Logger = &logger
+
+		up.Run()
+		assert.Contains(t, buf.String(), "bundle entrypoint `bin/server` is not a file in the bundle")
+		assert.Empty(t, staged.Version)
+	})
+
+	t.Run("should not download bundles in an unsupported format", func(t *testing.T) {
+		up, downloaded, _, staged, _ := setup(t, func(a *models.Artifact) {
+			a.Bundle = "rar"
+		})
+
+		var buf bytes.Buffer
+		logger := zerolog.New(&buf)
+		up.Logger = &logger
+
+		up.Run()
+		assert.Empty(t, *downloaded)
+		assert.Contains(t, buf.String(), "artifact is a bundle in unsupported format `rar`")
+		assert.Empty(t, staged.Version)
+	})
+}
+
+func Test_State(t *testing.T) {
+	t.Run("should create the state directory private to the updater", func(t *testing.T) {
+		stateDir := filepath.Join(t.TempDir(), "state")
+		assert.NoError(t, (&State{HighWaterMark: "v1.2.3"}).Save(stateDir))
+
+		info, err := os.Stat(stateDir)
+		assert.NoError(t, err)
+		assert.Equal(t, os.FileMode(0o700), info.Mode().Perm())
+
+		state, err := LoadState(stateDir)
+		assert.NoError(t, err)
+		assert.Equal(t, "v1.2.3", state.HighWaterMark)
+	})
+
+	t.Run("should start over without a state directory yet", func(t *testing.T) {
+		state, err := LoadState(filepath.Join(t.TempDir(), "state"))
+		assert.NoError(t, err)
+		assert.Empty(t, state.HighWaterMark)
+	})
+
+	t.Run("should refuse a state directory others can write to", func(t *testing.T) {
+		if runtime.GOOS == "windows" {
+			t.Skip("access to the state directory is left to its ACLs on windows")
+		}
+		stateDir := t.TempDir()
+		assert.NoError(t, (&State{HighWaterMark: "v1.2.3"}).Save(stateDir))
+		assert.NoError(t, os.Chmod(stateDir, 0o777))
+
+		_, err := LoadState(stateDir)
+		assert.ErrorContains(t, err, "is writable by others")
+
+		err = (&State{HighWaterMark: "v1.2.4"}).Save(stateDir)
+		assert.ErrorContains(t, err, "is writable by others")
+	})
+
+	t.Run("should refuse trusted keys others can write to", func(t *testing.T) {
+		if runtime.GOOS == "windows" {
+			t.Skip("access to the state file is left to the ACLs of its directory on windows")
+		}
+		stateDir := t.TempDir()
+		keys := models.KeyRingState{Version: 1, Keys: []string{string(assets.PublicKeyPEM)}}
+		assert.NoError(t, (&State{Keys: keys}).Save(stateDir))
+
+		state, err := LoadState(stateDir)
+		assert.NoError(t, err)
+		assert.Equal(t, keys, state.Keys)
+
+		assert.NoError(t, os.Chmod(filepath.Join(stateDir, stateFileName), 0o666))
+		_, err = LoadState(stateDir)
+		assert.ErrorContains(t, err, "state file")
+		assert.ErrorContains(t, err, "is writable by others")
 	})
 }
@@ -2,14 +2,18 @@ package updater
 
 import (
 	"context"
+	"fmt"
 
 	"github.com/rs/zerolog"
 
 	"github.com/danilevy1212/self-updater/internal/manifest"
 	"github.com/danilevy1212/self-updater/internal/models"
+	"github.com/danilevy1212/self-updater/internal/oci"
+	"github.com/danilevy1212/self-updater/internal/s3"
+	"github.com/danilevy1212/self-updater/internal/updater/config"
 )
 
-type ManifestFetcherFactoryFunc func(ctx context.Context, applicationMeta models.ApplicationMeta, logger *zerolog.Logger) (manifest.ManifestFetcher, error)
+type ManifestFetcherFactoryFunc func(ctx context.Context, applicationMeta models.ApplicationMeta, conf *config.Config, logger *zerolog.Logger) (manifest.ManifestFetcher, error)
 
 func createGithubManifestFetcher(ctx context.Context, applicationMeta models.ApplicationMeta, logger *zerolog.Logger) (manifest.ManifestFetcher, error) {
 	factoryCtx := manifest.SetGithubManifestFetcherLogger(ctx, logger)
@@ -20,4 +24,77 @@ func createGithubManifestFetcher(ctx context.Context, applicationMeta models.App
 	return ghmf, nil
 }
 
-var ManifestFetcherFactory ManifestFetcherFactoryFunc = createGithubManifestFetcher
+func createHTTPManifestFetcher(ctx context.Context, applicationMeta models.ApplicationMeta, conf *config.Config, logger *zerolog.Logger) (manifest.ManifestFetcher, error) {
+	factoryCtx := manifest.SetManifestFetcherLogger(ctx, logger)
+	hmf, err := manifest.NewHTTPManifestFetcher(factoryCtx, applicationMeta, conf.ManifestURL, conf.ManifestSignatureURL, conf.Channel)
+	if err != nil {
+		return nil, err
+	}
+
+	if conf.TimestampURL != "" {
+		hmf.TimestampURL, err = manifest.RenderURL(conf.TimestampURL, manifest.NewURLTemplateData(applicationMeta, conf.Channel))
+		if err != nil {
+			return nil, fmt.Errorf("invalid timestamp url: %w", err)
+		}
+	}
+	return hmf, nil
+}
+
+func createDirectoryManifestFetcher(ctx context.Context, applicationMeta models.ApplicationMeta, conf *config.Config, logger *zerolog.Logger) (manifest.ManifestFetcher, error) {
+	factoryCtx := manifest.SetManifestFetcherLogger(ctx, logger)
+	dmf, err := manifest.NewDirectoryManifestFetcher(factoryCtx, applicationMeta, conf.ManifestDirectory)
+	if err != nil {
+		return nil, err
+	}
+	return dmf, nil
+}
+
+func createS3ManifestFetcher(ctx context.Context, applicationMeta models.ApplicationMeta, conf *config.Config, logger *zerolog.Logger) (manifest.ManifestFetcher, error) {
+	client, err := s3.NewClient(conf.S3Endpoint, conf.S3Region, conf.S3PathStyle, s3.Credentials{
+		AccessKeyID:     conf.S3AccessKeyID,
+		SecretAccessKey: conf.S3SecretAccessKey,
+		SessionToken:    conf.S3SessionToken,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	factoryCtx := manifest.SetManifestFetcherLogger(ctx, logger)
+	smf, err := manifest.NewS3ManifestFetcher(factoryCtx, applicationMeta, client, conf.S3Bucket, conf.S3Prefix)
+	if err != nil {
+		return nil, err
+	}
+	return smf, nil
+}
+
+func createOCIManifestFetcher(ctx context.Context, applicationMeta models.ApplicationMeta, conf *config.Config, logger *zerolog.Logger) (manifest.ManifestFetcher, error) {
+	client, err := oci.NewClient(conf.OCIRegistry, conf.OCIRepository, conf.OCIUsername, conf.OCIPassword, conf.OCIPlainHTTP)
+	if err != nil {
+		return nil, err
+	}
+
+	factoryCtx := manifest.SetManifestFetcherLogger(ctx, logger)
+	omf, err := manifest.NewOCIManifestFetcher(factoryCtx, applicationMeta, client, conf.OCITag)
+	if err != nil {
+		return nil, err
+	}
+	return omf, nil
+}
+
+// createManifestFetcher picks the manifest fetcher for the configured UPDATER_MANIFEST_SOURCE.
+func createManifestFetcher(ctx context.Context, applicationMeta models.ApplicationMeta, conf *config.Config, logger *zerolog.Logger) (manifest.ManifestFetcher, error) {
+	switch conf.ManifestSource {
+	case config.ManifestSourceHTTP:
+		return createHTTPManifestFetcher(ctx, applicationMeta, conf, logger)
+	case config.ManifestSourceDirectory:
+		return createDirectoryManifestFetcher(ctx, applicationMeta, conf, logger)
+	case config.ManifestSourceS3:
+		return createS3ManifestFetcher(ctx, applicationMeta, conf, logger)
+	case config.ManifestSourceOCI:
+		return createOCIManifestFetcher(ctx, applicationMeta, conf, logger)
+	default:
+		return createGithubManifestFetcher(ctx, applicationMeta, logger)
+	}
+}
+
+var ManifestFetcherFactory ManifestFetcherFactoryFunc = createManifestFetcher
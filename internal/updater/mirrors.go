@@ -0,0 +1,271 @@
+package updater
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"github.com/danilevy1212/self-updater/internal/backoff"
+	"github.com/danilevy1212/self-updater/internal/bundle"
+	"github.com/danilevy1212/self-updater/internal/compression"
+	"github.com/danilevy1212/self-updater/internal/digest"
+	"github.com/danilevy1212/self-updater/internal/downloader"
+	"github.com/danilevy1212/self-updater/internal/httpclient"
+	"github.com/danilevy1212/self-updater/internal/manifest"
+	"github.com/danilevy1212/self-updater/internal/mirror"
+	"github.com/danilevy1212/self-updater/internal/models"
+)
+
+const (
+	manifestFetchTimeout  = 5 * time.Second
+	mirrorProbeTimeout    = 2 * time.Second
+	mirrorManifestFile    = "release.json"
+	mirrorSignatureSuffix = ".sig.base64"
+	mirrorTimestampFile   = "timestamp.json"
+)
+
+// renderMirrors renders the configured mirror base URLs, see manifest.URLTemplateData for the available fields.
+func renderMirrors(am models.ApplicationMeta, channel string, templates []string) ([]string, error) {
+	data := manifest.NewURLTemplateData(am, channel)
+
+	mirrors := make([]string, 0, len(templates))
+	for _, tmpl := range templates {
+		base, err := manifest.RenderURL(tmpl, data)
+		if err != nil {
+			return nil, fmt.Errorf("invalid mirror: %w", err)
+		}
+		mirrors = append(mirrors, strings.TrimSuffix(base, "/"))
+	}
+
+	return mirrors, nil
+}
+
+// requestContext is where requests to release hosts start from, they go through the configured http client.
+func (u *Updater) requestContext() context.Context {
+	return httpclient.WithClient(context.Background(), u.HTTPClient)
+}
+
+// rankMirrors orders urls by the configured mirror strategy.
+func (u *Updater) rankMirrors(urls []string) []string {
+	ctx, cancel := context.WithTimeout(u.requestContext(), mirrorProbeTimeout)
+	defer cancel()
+
+	return mirror.Rank(ctx, u.Config.MirrorStrategy, urls, func(url string) string {
+		return url
+	})
+}
+
+// fetchManifest falls back to the mirrors when the manifest source fails,
+// the manifest signature makes any of them safe to use.
+func (u *Updater) fetchManifest(logger *zerolog.Logger) (*models.ReleaseManifest, error) {
+	result, err := downloader.Retry(u.requestContext(), u.retryPolicy(), u.logRetry(logger), func(ctx context.Context) (*models.ReleaseManifest, error) {
+		ctx, cancel := context.WithTimeout(ctx, manifestFetchTimeout)
+		defer cancel()
+
+		return u.ManifestFetcher.FetchManifest(ctx)
+	})
+	if err == nil || len(u.Mirrors) == 0 {
+		return result, err
+	}
+
+	logger.Warn().
+		Err(err).
+		Msg("Failed to fetch manifest from source, trying mirrors")
+
+	errs := []error{err}
+	manifestURLs := make([]string, 0, len(u.Mirrors))
+	for _, base := range u.Mirrors {
+		manifestURLs = append(manifestURLs, base+"/"+mirrorManifestFile)
+	}
+
+	for _, manifestURL := range u.rankMirrors(manifestURLs) {
+		mf := &manifest.HTTPManifestFetcher{
+			ApplicationMeta: u.Meta,
+			Logger:          logger,
+			ManifestURL:     manifestURL,
+			SignatureURL:    manifestURL + mirrorSignatureSuffix,
+			TimestampURL:    manifest.SiblingURL(manifestURL, mirrorTimestampFile),
+			Freshness:       u.Freshness,
+			Keys:            u.Keys,
+		}
+
+		mirrorCtx, cancelMirror := context.WithTimeout(u.requestContext(), manifestFetchTimeout)
+		result, err := mf.FetchManifest(mirrorCtx)
+		cancelMirror()
+
+		if err == nil {
+			logger.Info().
+				Str("mirror", manifestURL).
+				Msg("Fetched manifest from mirror")
+
+			return result, nil
+		}
+
+		logger.Warn().
+			Err(err).
+			Str("mirror", manifestURL).
+			Msg("Failed to fetch manifest from mirror")
+
+		errs = append(errs, err)
+	}
+
+	return nil, fmt.Errorf("failed to fetch manifest from source and mirrors: %w", errors.Join(errs...))
+}
+
+// fetchArtifact fetches the artifact from its source, falling back to its mirrors in the manifest and then
+// the configured ones. A copy that doesn't match the digest in the manifest is discarded, so that a
+// tampered mirror can't hold back an update. Returns the artifact with its hex digest.
+func (u *Updater) fetchArtifact(logger *zerolog.Logger, version string, artifact *models.Artifact) (*os.File, string, error) {
+	defer u.setProgress(nil)
+
+	if !compression.Supported(artifact.Compression) {
+		return nil, "", fmt.Errorf("artifact is compressed with unsupported compression `%s`", artifact.Compression)
+	}
+	if artifact.Bundle != "" && !bundle.Supported(artifact.Bundle) {
+		return nil, "", fmt.Errorf("artifact is a bundle in unsupported format `%s`", artifact.Bundle)
+	}
+
+	mirrors := append([]string{}, artifact.Mirrors...)
+	for _, base := range u.Mirrors {
+		mirrors = append(mirrors, base+"/"+artifact.Filename)
+	}
+	mirrors = u.rankMirrors(mirrors)
+
+	var errs []error
+	// Compressed artifacts are decompressed as they are downloaded, the file is named after the executable.
+	pattern := strings.TrimSuffix(artifact.Filename, compression.Extensions[artifact.Compression]) + ".*"
+	for i, url := range append([]string{artifact.URL}, mirrors...) {
+		l := logger.With().
+			Str("url", url).
+			Logger()
+
+		file, err := downloader.Retry(u.requestContext(), u.retryPolicy(), u.logRetry(&l), func(ctx context.Context) (*downloader.File, error) {
+			ctx, cancel := u.downloadContext(ctx, &l, version, url, artifact.Size)
+			defer cancel()
+			ctx = downloader.WithCompression(ctx, artifact.Compression)
+
+			// The source may know how to resolve the artifact itself, e.g. from a mounted volume.
+			if af, ok := u.ManifestFetcher.(manifest.ArtifactFetcher); ok && i == 0 {
+				file, err := af.FetchArtifact(ctx, artifact)
+				if !errors.Is(err, manifest.ErrDownloadArtifact) {
+					return file, err
+				}
+			}
+
+			return u.download(ctx, url, pattern)
+		})
+		if err != nil {
+			l.Warn().
+				Err(err).
+				Str("error_kind", downloader.Kind(err)).
+				Bool("retryable", downloader.IsRetryable(err)).
+				Msg("Failed to download artifact file")
+
+			errs = append(errs, err)
+			continue
+		}
+
+		// Downloads are hashed as they are written, only artifacts from elsewhere have to be read again.
+		artifactDigest := file.DownloadSHA256()
+		if artifactDigest == nil {
+			artifactDigest, err = digest.DigestFile(file.Name())
+			if err != nil {
+				l.Warn().
+					Err(err).
+					Msg("Failed to calculate artifact file digest")
+
+				discardFile(file.File)
+				errs = append(errs, err)
+				continue
+			}
+		}
+
+		artifactDigestHex := hex.EncodeToString(artifactDigest)
+		if artifactDigestHex != artifact.DownloadDigest() {
+			l.Error().
+				Str("expected_digest", artifact.DownloadDigest()).
+				Str("actual_digest", artifactDigestHex).
+				Msg("Artifact file digest does not match expected digest")
+
+			discardFile(file.File)
+			errs = append(errs, fmt.Errorf("digest of artifact from %s does not match", url))
+			continue
+		}
+
+		l.Info().
+			Str("artifact_file", file.Name()).
+			Msg("Downloaded artifact file")
+
+		if artifact.Compression == compression.None {
+			return file.File, artifactDigestHex, nil
+		}
+
+		// The compressed file is what the mirrors serve, any of them would decompress the same way.
+		// The executable is held to the artifact digest, which its signature covers.
+		executableDigestHex := hex.EncodeToString(file.SHA256)
+		if file.CompressedSHA256 == nil || executableDigestHex != artifact.Digest {
+			discardFile(file.File)
+			return nil, "", fmt.Errorf("decompressed artifact digest %s does not match expected digest %s", executableDigestHex, artifact.Digest)
+		}
+
+		l.Info().
+			Str("artifact_file", file.Name()).
+			Str("compression", artifact.Compression).
+			Msg("Decompressed artifact file")
+
+		return file.File, executableDigestHex, nil
+	}
+
+	return nil, "", fmt.Errorf("failed to fetch artifact from source and mirrors: %w", errors.Join(errs...))
+}
+
+func (u *Updater) retryPolicy() downloader.RetryPolicy {
+	return downloader.RetryPolicy{
+		Retries: u.Config.DownloadRetries,
+		Backoff: backoff.Exponential{
+			Initial: u.Config.DownloadRetryBackoffInitial,
+			Max:     u.Config.DownloadRetryBackoffMax,
+			Jitter:  0.2,
+		},
+	}
+}
+
+func (u *Updater) logRetry(logger *zerolog.Logger) downloader.OnRetryFunc {
+	return func(err error, attempt int, delay time.Duration) {
+		logger.Warn().
+			Err(err).
+			Str("error_kind", downloader.Kind(err)).
+			Int("attempt", attempt).
+			Dur("delay", delay).
+			Msg("Download failed, retrying")
+	}
+}
+
+// downloadContext scopes a download of the given size to the configured timeout, rate limit and progress reports.
+func (u *Updater) downloadContext(ctx context.Context, logger *zerolog.Logger, version, url string, size int64) (context.Context, context.CancelFunc) {
+	ctx = downloader.WithExpectedSize(ctx, size)
+	ctx = downloader.WithRateLimit(ctx, int64(u.Config.DownloadRateLimit))
+	ctx = downloader.WithProgress(ctx, u.trackProgress(logger, version, url))
+
+	return context.WithTimeout(ctx, u.Config.DownloadTimeout)
+}
+
+// download resumes partial downloads left in the download directory by earlier runs, when there is one.
+func (u *Updater) download(ctx context.Context, url, pattern string) (*downloader.File, error) {
+	if u.Config.DownloadDirectory == "" {
+		return downloader.DownloadToTemporaryFile(ctx, url, pattern)
+	}
+
+	return downloader.DownloadResumable(ctx, u.Config.DownloadDirectory, url, pattern)
+}
+
+func discardFile(file *os.File) {
+	_ = file.Close()
+	_ = os.Remove(file.Name())
+}
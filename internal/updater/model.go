@@ -3,35 +3,74 @@ package updater
 import (
 	"context"
 	"fmt"
+	"net/http"
 	"os"
+	"sync"
+	"time"
 
+	"github.com/google/uuid"
 	"github.com/robfig/cron/v3"
 	"github.com/rs/zerolog"
 
+	"github.com/danilevy1212/self-updater/internal/audit"
+	"github.com/danilevy1212/self-updater/internal/httpclient"
+	"github.com/danilevy1212/self-updater/internal/identity"
 	"github.com/danilevy1212/self-updater/internal/logger"
+	"github.com/danilevy1212/self-updater/internal/maintenance"
 	"github.com/danilevy1212/self-updater/internal/manifest"
 	"github.com/danilevy1212/self-updater/internal/models"
 	"github.com/danilevy1212/self-updater/internal/updater/config"
 )
 
-type OnUpgradeReadyFunc func(newVersion *os.File, logger *zerolog.Logger)
+type OnUpgradeReadyFunc func(newVersion *os.File, release models.StagedRelease, logger *zerolog.Logger)
 
 type JobID int
 
+// For testing sake
+type NowFunc func() time.Time
+
+var NowGenerator NowFunc = time.Now
+
 type Updater struct {
 	Meta            models.ApplicationMeta
 	Cron            *cron.Cron
 	Config          *config.Config
 	Logger          *zerolog.Logger
 	ManifestFetcher manifest.ManifestFetcher
-	OnUpgradeReady  OnUpgradeReadyFunc
+	// Base URLs of fallback mirrors, tried when the manifest source or an artifact URL fails
+	Mirrors        []string
+	OnUpgradeReady OnUpgradeReadyFunc
+	State          *State
+	// Checks fetched manifests are neither stale nor rolled back, shared by the manifest fetcher and mirrors
+	Freshness *manifest.Freshness
+	// Keys trusted to sign releases, following the key rotations of fetched manifests
+	Keys *audit.KeyRing
+	// Client for requests to release hosts, mirrors and registries, see requestContext
+	HTTPClient *http.Client
+	// Stable identifier of this installation, used to place it in staged rollouts
+	InstallationID string
+	// When verified updates may be applied
+	Maintenance *maintenance.Schedule
+
+	// Consecutive failed checks, scheduled checks are skipped while backing off
+	Failures failureBackoff
+
+	// Done once the updater is stopped
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	stagedMu sync.Mutex
+	staged   *stagedUpdate
+
+	progressMu sync.Mutex
+	progress   *models.DownloadProgress
 }
 
 func (u *Updater) Start() (JobID, error) {
 	u.Logger.Info().
 		Msg("Starting updater job")
 
-	id, err := u.Cron.AddJob(u.Config.Schedule, u)
+	id, err := u.Cron.AddFunc(u.Config.Schedule, u.runScheduled)
 	if err != nil {
 		return 0, err
 	}
@@ -39,6 +78,7 @@ func (u *Updater) Start() (JobID, error) {
 	u.Logger.Info().
 		Int("job_id", int(id)).
 		Str("schedule", u.Config.Schedule).
+		Dur("jitter", u.Config.Jitter).
 		Msg("Updater job scheduled")
 
 	u.Cron.Start()
@@ -49,31 +89,106 @@ func (u *Updater) Start() (JobID, error) {
 	return JobID(id), nil
 }
 
+// Stop stops scheduling new runs, the returned context is done once a running job finishes.
+func (u *Updater) Stop() context.Context {
+	u.Logger.Info().
+		Msg("Stopping updater job")
+
+	// Don't let a run waiting out its jitter hold the shutdown.
+	u.cancel()
+
+	return u.Cron.Stop()
+}
+
 func New(ctx context.Context, am models.ApplicationMeta, onUpgradeReadyCallback OnUpgradeReadyFunc) (*Updater, error) {
 	conf, err := config.New(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("failed to load config: %w", err)
 	}
 
-	cr := cron.New()
-
 	l := logger.New(conf.IsDev).
 		With().
 		Str("app", "updater").
 		Logger()
+
+	// A check that outlasts its interval, e.g. jitter plus a slow download, must not overlap the next one.
+	cr := cron.New(cron.WithChain(cron.SkipIfStillRunning(cronLogger{logger: &l})))
 	mfl := l.With().Str("service", "manifest_fetcher").Logger()
 
-	mf, err := ManifestFetcherFactory(ctx, am, &mfl)
+	am.SourceInfo = conf.SourceInfo(am.SourceInfo)
+
+	client, err := httpclient.New(httpclient.Config{
+		ProxyURL:      conf.HTTPProxy,
+		CABundle:      conf.HTTPCABundle,
+		ClientCert:    conf.HTTPClientCert,
+		ClientKey:     conf.HTTPClientKey,
+		TLSMinVersion: conf.HTTPTLSMinVersion,
+		Timeout:       conf.HTTPTimeout,
+		UserAgent:     httpclient.UserAgent(am.SourceInfo.Name, am.Version, am.Commit, am.OS, am.Arch),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create http client: %w", err)
+	}
+
+	state, err := LoadState(conf.StateDirectory)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load state: %w", err)
+	}
+
+	freshness := manifest.NewFreshness(state.Metadata, conf.RequireFreshMetadata, func(versions models.MetadataVersions) error {
+		state.Metadata = versions
+		return state.Save(conf.StateDirectory)
+	})
+	freshness.Now = func() time.Time { return NowGenerator() }
+
+	keys := audit.NewKeyRing(am.AuthorsPublicKey, state.Keys, func(keys models.KeyRingState) error {
+		state.Keys = keys
+		return state.Save(conf.StateDirectory)
+	})
+
+	fetcherCtx := manifest.SetManifestFetcherKeyRing(manifest.SetManifestFetcherFreshness(ctx, freshness), keys)
+	fetcherCtx = manifest.SetManifestFetcherDownloadDirectory(fetcherCtx, conf.DownloadDirectory)
+	mf, err := ManifestFetcherFactory(fetcherCtx, am, conf, &mfl)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create manifest fetcher: %w", err)
 	}
 
+	mirrors, err := renderMirrors(am, conf.Channel, conf.Mirrors)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load mirrors: %w", err)
+	}
+
+	schedule, err := maintenance.NewSchedule(conf.MaintenanceWindows, conf.MaintenanceTimezone)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load maintenance windows: %w", err)
+	}
+
+	// Without a state directory, the installation gets a new identity on every start.
+	installationID := uuid.NewString()
+	if conf.StateDirectory != "" {
+		installationID, err = identity.LoadOrCreate(conf.StateDirectory)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load installation id: %w", err)
+		}
+	}
+
+	runCtx, cancel := context.WithCancel(ctx)
+
 	return &Updater{
 		Meta:            am,
 		Config:          conf,
 		Cron:            cr,
 		Logger:          &l,
 		ManifestFetcher: mf,
+		Mirrors:         mirrors,
 		OnUpgradeReady:  onUpgradeReadyCallback,
+		State:           state,
+		Freshness:       freshness,
+		Keys:            keys,
+		HTTPClient:      client,
+		InstallationID:  installationID,
+		Maintenance:     schedule,
+		ctx:             runCtx,
+		cancel:          cancel,
 	}, nil
 }
@@ -0,0 +1,84 @@
+package updater
+
+import (
+	"slices"
+
+	"github.com/rs/zerolog"
+
+	"github.com/danilevy1212/self-updater/internal/semver"
+	"github.com/danilevy1212/self-updater/internal/updater/config"
+)
+
+// targetVersion is the version this installation should run, the pinned one if any,
+// the latest of the channel otherwise.
+func (u *Updater) targetVersion(logger *zerolog.Logger, latest string) string {
+	pin := u.Config.PinVersion
+	if pin == "" {
+		return latest
+	}
+
+	if pin != latest {
+		logger.Info().
+			Str("pinned_version", pin).
+			Str("latest_version", latest).
+			Msg("Installation is pinned to a version, ignoring the latest version of the channel")
+	}
+
+	return pin
+}
+
+// allowedByPolicy tells if the update policy lets the candidate version replace the running one.
+func (u *Updater) allowedByPolicy(logger *zerolog.Logger, candidate string) bool {
+	l := logger.With().
+		Str("current_version", u.Meta.Version).
+		Str("candidate_version", candidate).
+		Logger()
+
+	if slices.Contains(u.Config.DenyVersions, candidate) {
+		l.Warn().
+			Strs("deny_versions", u.Config.DenyVersions).
+			Msg("Update skipped, candidate version is deny-listed by policy")
+
+		return false
+	}
+
+	candidateVersion, err := semver.Parse(candidate)
+	if err != nil {
+		// isUpgrade explains this one.
+		return true
+	}
+
+	if u.Config.MinVersion != "" {
+		minVersion, err := semver.Parse(u.Config.MinVersion)
+		if err == nil && candidateVersion.LessThan(minVersion) {
+			l.Warn().
+				Str("min_version", u.Config.MinVersion).
+				Msg("Update skipped, candidate version is below the minimum version allowed by policy")
+
+			return false
+		}
+	}
+
+	currentVersion, err := semver.Parse(u.Meta.Version)
+	if err != nil {
+		return true
+	}
+
+	exceeds := false
+	switch u.Config.AllowedBump {
+	case config.BumpMinor:
+		exceeds = candidateVersion.Major != currentVersion.Major
+	case config.BumpPatch:
+		exceeds = candidateVersion.Major != currentVersion.Major || candidateVersion.Minor != currentVersion.Minor
+	}
+
+	if exceeds {
+		l.Warn().
+			Str("allowed_bump", string(u.Config.AllowedBump)).
+			Msg("Update skipped, candidate version is a bigger bump than allowed by policy")
+
+		return false
+	}
+
+	return true
+}
@@ -0,0 +1,61 @@
+package updater
+
+import (
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"github.com/danilevy1212/self-updater/internal/downloader"
+	"github.com/danilevy1212/self-updater/internal/models"
+)
+
+// DownloadProgress returns the progress of the artifact being downloaded, nil when there is none.
+func (u *Updater) DownloadProgress() *models.DownloadProgress {
+	u.progressMu.Lock()
+	defer u.progressMu.Unlock()
+
+	if u.progress == nil {
+		return nil
+	}
+	p := *u.progress
+
+	return &p
+}
+
+func (u *Updater) setProgress(progress *models.DownloadProgress) {
+	u.progressMu.Lock()
+	defer u.progressMu.Unlock()
+
+	u.progress = progress
+}
+
+// trackProgress keeps the progress of a download for DownloadProgress, and logs it every UPDATER_PROGRESS_INTERVAL.
+func (u *Updater) trackProgress(logger *zerolog.Logger, version, url string) downloader.ProgressFunc {
+	startedAt := NowGenerator()
+	var loggedAt time.Time
+
+	return func(p downloader.Progress) {
+		u.setProgress(&models.DownloadProgress{
+			Version:        version,
+			URL:            url,
+			StartedAt:      startedAt,
+			BytesDone:      p.Done,
+			BytesTotal:     p.Total,
+			BytesPerSecond: p.Rate,
+			ETASeconds:     p.ETA.Seconds(),
+		})
+
+		interval := u.Config.ProgressInterval
+		if interval <= 0 || time.Since(loggedAt) < interval {
+			return
+		}
+		loggedAt = time.Now()
+
+		logger.Info().
+			Int64("bytes_done", p.Done).
+			Int64("bytes_total", p.Total).
+			Float64("bytes_per_second", p.Rate).
+			Dur("eta", p.ETA).
+			Msg("Downloading artifact")
+	}
+}
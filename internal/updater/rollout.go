@@ -0,0 +1,48 @@
+package updater
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"math"
+
+	"github.com/rs/zerolog"
+
+	"github.com/danilevy1212/self-updater/internal/models"
+)
+
+// rolloutBucket places an installation in [0, 100) for a given version. The same installation always
+// lands in the same bucket for a version, while different versions shuffle the fleet differently.
+func rolloutBucket(installationID, version string) float64 {
+	sum := sha256.Sum256([]byte(installationID + "/" + version))
+
+	return float64(binary.BigEndian.Uint64(sum[:8])) / float64(math.MaxUint64) * 100
+}
+
+// inRollout tells if this installation is part of the release's rollout cohort right now.
+func (u *Updater) inRollout(logger *zerolog.Logger, release *models.ReleaseInfo) bool {
+	if release.Rollout == nil {
+		return true
+	}
+
+	percentage := release.Rollout.PercentageAt(NowGenerator())
+	bucket := rolloutBucket(u.InstallationID, release.Version)
+
+	l := logger.With().
+		Str("version", release.Version).
+		Str("installation_id", u.InstallationID).
+		Float64("rollout_percentage", percentage).
+		Float64("rollout_bucket", bucket).
+		Logger()
+
+	if bucket >= percentage {
+		l.Info().
+			Msg("Installation is not part of the rollout for this version yet, skipping update")
+
+		return false
+	}
+
+	l.Info().
+		Msg("Installation is part of the rollout for this version")
+
+	return true
+}
@@ -0,0 +1,114 @@
+package updater
+
+import (
+	"math/rand/v2"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"github.com/danilevy1212/self-updater/internal/backoff"
+)
+
+// Randomizes the delay between failed checks a bit more, on top of UPDATER_JITTER.
+const failureBackoffJitter = 0.2
+
+// For testing sake
+type JitterFunc func(max time.Duration) time.Duration
+
+var JitterGenerator JitterFunc = func(max time.Duration) time.Duration {
+	if max <= 0 {
+		return 0
+	}
+
+	return rand.N(max)
+}
+
+// failureBackoff tracks consecutive failed checks, scheduled checks are skipped until RetryAt.
+type failureBackoff struct {
+	Consecutive int
+	RetryAt     time.Time
+	runFailed   bool
+}
+
+// runScheduled is the cron job: it waits a random jitter, so that a fleet sharing a schedule doesn't
+// hit the release host at the same second, and skips checks while backing off after failures.
+func (u *Updater) runScheduled() {
+	logger := u.Logger
+
+	if now := NowGenerator(); now.Before(u.Failures.RetryAt) {
+		logger.Info().
+			Int("consecutive_failures", u.Failures.Consecutive).
+			Time("retry_at", u.Failures.RetryAt).
+			Msg("Skipping scheduled update check while backing off after failures")
+
+		return
+	}
+
+	if jitter := JitterGenerator(u.Config.Jitter); jitter > 0 {
+		logger.Debug().
+			Dur("jitter", jitter).
+			Msg("Delaying scheduled update check")
+
+		select {
+		case <-time.After(jitter):
+		case <-u.ctx.Done():
+			return
+		}
+	}
+
+	u.Run()
+}
+
+// recordFailure marks the current run as failed, see settleFailures.
+func (u *Updater) recordFailure() {
+	u.Failures.runFailed = true
+}
+
+// settleFailures backs off after a failed run, and resets the backoff after a successful one.
+func (u *Updater) settleFailures(logger *zerolog.Logger) {
+	if !u.Failures.runFailed {
+		if u.Failures.Consecutive > 0 {
+			logger.Info().
+				Int("consecutive_failures", u.Failures.Consecutive).
+				Msg("Update check succeeded, resetting failure backoff")
+		}
+		u.Failures = failureBackoff{}
+
+		return
+	}
+
+	u.Failures.runFailed = false
+	u.Failures.Consecutive++
+
+	b := backoff.Exponential{
+		Initial: u.Config.FailureBackoffInitial,
+		Max:     u.Config.FailureBackoffMax,
+		Jitter:  failureBackoffJitter,
+	}
+	delay := b.Delay(u.Failures.Consecutive - 1)
+	u.Failures.RetryAt = NowGenerator().Add(delay)
+
+	logger.Warn().
+		Int("consecutive_failures", u.Failures.Consecutive).
+		Dur("backoff", delay).
+		Time("retry_at", u.Failures.RetryAt).
+		Msg("Update check failed, backing off")
+}
+
+// cronLogger reports what cron does with the job, e.g. runs skipped because the previous one is still going.
+type cronLogger struct {
+	logger *zerolog.Logger
+}
+
+func (c cronLogger) Info(msg string, keysAndValues ...any) {
+	c.logger.Debug().
+		Fields(keysAndValues).
+		Msg(msg)
+}
+
+func (c cronLogger) Error(err error, msg string, keysAndValues ...any) {
+	c.logger.Error().
+		Err(err).
+		Fields(keysAndValues).
+		Msg(msg)
+}
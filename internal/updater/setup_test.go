@@ -9,10 +9,11 @@ import (
 
 	"github.com/danilevy1212/self-updater/internal/manifest"
 	"github.com/danilevy1212/self-updater/internal/models"
+	"github.com/danilevy1212/self-updater/internal/updater/config"
 )
 
 func TestMain(m *testing.M) {
-	ManifestFetcherFactory = func(ctx context.Context, applicationMeta models.ApplicationMeta, logger *zerolog.Logger) (manifest.ManifestFetcher, error) {
+	ManifestFetcherFactory = func(ctx context.Context, applicationMeta models.ApplicationMeta, _ *config.Config, logger *zerolog.Logger) (manifest.ManifestFetcher, error) {
 		return manifest.NewStaticFetcher(), nil
 	}
 
@@ -0,0 +1,94 @@
+package updater
+
+import (
+	"os"
+
+	"github.com/rs/zerolog"
+
+	"github.com/danilevy1212/self-updater/internal/models"
+)
+
+// stagedUpdate is a downloaded and verified artifact, waiting for a maintenance window to be applied.
+// The file of a bundle is the directory it was extracted to.
+type stagedUpdate struct {
+	file     *os.File
+	release  models.StagedRelease
+	critical bool
+}
+
+// stage applies a verified update if allowed right now, otherwise keeps it until a later run finds
+// a maintenance window open.
+func (u *Updater) stage(logger *zerolog.Logger, update *stagedUpdate) {
+	u.stagedMu.Lock()
+	defer u.stagedMu.Unlock()
+
+	if u.staged != nil && u.staged.file != update.file {
+		u.discardStaged(logger)
+	}
+
+	now := NowGenerator()
+	if update.critical || u.Maintenance.IsOpen(now) {
+		u.staged = nil
+		u.apply(logger, update)
+
+		return
+	}
+
+	u.staged = update
+
+	logger.Info().
+		Str("version", update.release.Version).
+		Str("artifact_file", update.file.Name()).
+		Time("next_window", u.Maintenance.NextOpen(now)).
+		Msg("Update staged, waiting for a maintenance window to apply it")
+}
+
+// applyStaged applies the update already staged for release, if any, once allowed.
+// Returns true when release was staged, so there is nothing to download.
+func (u *Updater) applyStaged(logger *zerolog.Logger, release *models.ReleaseInfo) bool {
+	u.stagedMu.Lock()
+	staged := u.staged
+	u.stagedMu.Unlock()
+
+	if staged == nil || staged.release.Version != release.Version {
+		return false
+	}
+
+	// The release may have been marked critical since it was staged.
+	staged.critical = release.Critical
+	u.stage(logger, staged)
+
+	return true
+}
+
+// discardStagedUnless drops the staged update unless it is for version.
+func (u *Updater) discardStagedUnless(logger *zerolog.Logger, version string) {
+	u.stagedMu.Lock()
+	defer u.stagedMu.Unlock()
+
+	if u.staged == nil || u.staged.release.Version == version {
+		return
+	}
+
+	u.discardStaged(logger)
+}
+
+func (u *Updater) discardStaged(logger *zerolog.Logger) {
+	logger.Info().
+		Str("version", u.staged.release.Version).
+		Msg("Discarding staged update, it is no longer the update to apply")
+
+	_ = u.staged.file.Close()
+	_ = os.RemoveAll(u.staged.file.Name())
+	u.staged = nil
+}
+
+func (u *Updater) apply(logger *zerolog.Logger, update *stagedUpdate) {
+	l := logger.With().
+		Str("handler", "OnUpgradeReady").
+		Str("artifact_file", update.file.Name()).
+		Bool("critical", update.critical).
+		Logger()
+
+	u.OnUpgradeReady(update.file, update.release, &l)
+}
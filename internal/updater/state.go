@@ -0,0 +1,99 @@
+package updater
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/danilevy1212/self-updater/internal/models"
+)
+
+const stateFileName = "updater-state.json"
+
+// State is what the updater remembers across restarts.
+type State struct {
+	// Highest version run or staged by this installation, offered versions don't raise it.
+	// It is lowered to the running version again once the launcher rejects it, see UPDATER_REJECTED_VERSIONS.
+	HighWaterMark string `json:"highWaterMark,omitempty"`
+	// Highest metadata versions trusted, so that older manifests and timestamps are refused
+	Metadata models.MetadataVersions `json:"metadata,omitzero"`
+	// Signing keys trusted after the key rotations applied, the embedded keys are trusted until the first one
+	Keys models.KeyRingState `json:"keys,omitzero"`
+}
+
+// LoadState reads the state persisted in dir, an empty dir means the state is only kept in memory.
+// The state is refused when dir or its state file isn't private to the user running the updater.
+func LoadState(dir string) (*State, error) {
+	var s State
+	if dir == "" {
+		return &s, nil
+	}
+
+	if _, err := os.Stat(dir); errors.Is(err, os.ErrNotExist) {
+		return &s, nil
+	}
+	if err := checkStateDirectory(dir); err != nil {
+		return nil, err
+	}
+
+	path := filepath.Join(dir, stateFileName)
+	if _, err := os.Lstat(path); errors.Is(err, os.ErrNotExist) {
+		return &s, nil
+	}
+	// It holds the signing keys trusted, see checkStateFile.
+	if err := checkStateFile(path); err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read updater state: %w", err)
+	}
+
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal updater state: %w", err)
+	}
+
+	return &s, nil
+}
+
+// Save persists the state in dir, atomically replacing the previous one.
+func (s *State) Save(dir string) error {
+	if dir == "" {
+		return nil
+	}
+
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal updater state: %w", err)
+	}
+
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return fmt.Errorf("failed to create state directory: %w", err)
+	}
+	if err := checkStateDirectory(dir); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(dir, stateFileName+".*")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary state file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		return fmt.Errorf("failed to write temporary state file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to close temporary state file: %w", err)
+	}
+
+	if err := os.Rename(tmp.Name(), filepath.Join(dir, stateFileName)); err != nil {
+		return fmt.Errorf("failed to replace updater state: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,13 @@
+//go:build !unix
+
+package updater
+
+// checkStateDirectory leaves access to the state directory to its ACLs, set up with the installation.
+func checkStateDirectory(dir string) error {
+	return nil
+}
+
+// checkStateFile leaves access to the state file to the ACLs of its directory.
+func checkStateFile(path string) error {
+	return nil
+}
@@ -0,0 +1,50 @@
+//go:build unix
+
+package updater
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+)
+
+// checkStateDirectory refuses a state directory others could write to, e.g. to roll back the metadata
+// versions trusted: it must be owned by the user running the updater, and writable by it alone.
+func checkStateDirectory(dir string) error {
+	info, err := os.Stat(dir)
+	if err != nil {
+		return fmt.Errorf("failed to stat state directory: %w", err)
+	}
+
+	if !info.IsDir() {
+		return fmt.Errorf("state directory `%s` is not a directory", dir)
+	}
+
+	return checkPrivate("state directory", dir, info)
+}
+
+// checkStateFile refuses a state file others could write to, e.g. to trust a signing key of their own.
+func checkStateFile(path string) error {
+	info, err := os.Lstat(path)
+	if err != nil {
+		return fmt.Errorf("failed to stat state file: %w", err)
+	}
+
+	if !info.Mode().IsRegular() {
+		return fmt.Errorf("state file `%s` is not a regular file", path)
+	}
+
+	return checkPrivate("state file", path, info)
+}
+
+func checkPrivate(kind, path string, info os.FileInfo) error {
+	if stat, ok := info.Sys().(*syscall.Stat_t); ok && int(stat.Uid) != os.Getuid() {
+		return fmt.Errorf("%s `%s` is owned by uid %d, expected uid %d", kind, path, stat.Uid, os.Getuid())
+	}
+
+	if perm := info.Mode().Perm(); perm&0o022 != 0 {
+		return fmt.Errorf("%s `%s` is writable by others with mode %s, expected e.g. 0700", kind, path, perm)
+	}
+
+	return nil
+}
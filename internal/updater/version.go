@@ -0,0 +1,136 @@
+package updater
+
+import (
+	"github.com/rs/zerolog"
+
+	"github.com/danilevy1212/self-updater/internal/semver"
+)
+
+// raiseHighWaterMark remembers the highest of the given versions, run or staged by this installation,
+// so that an older manifest replayed later, even after a restart, can't roll this installation back.
+func (u *Updater) raiseHighWaterMark(logger *zerolog.Logger, versions ...string) {
+	highest, err := semver.Parse(u.State.HighWaterMark)
+	hasMark := err == nil
+	raised := false
+
+	for _, raw := range versions {
+		v, err := semver.Parse(raw)
+		if err != nil {
+			continue
+		}
+
+		if !hasMark || highest.LessThan(v) {
+			hasMark = true
+			highest = v
+			raised = true
+		}
+	}
+
+	if !raised {
+		return
+	}
+
+	u.State.HighWaterMark = highest.String()
+	if err := u.State.Save(u.Config.StateDirectory); err != nil {
+		logger.Error().
+			Err(err).
+			Str("high_water_mark", u.State.HighWaterMark).
+			Msg("Failed to persist version high-water mark")
+	}
+}
+
+// lowerRejectedHighWaterMark forgets a high-water mark the launcher rejected after a failed health check,
+// so that a release staged and rolled back doesn't hold back the fixes released below it.
+// The running version raises the mark again.
+func (u *Updater) lowerRejectedHighWaterMark(logger *zerolog.Logger) {
+	mark, err := semver.Parse(u.State.HighWaterMark)
+	if err != nil {
+		return
+	}
+
+	for _, raw := range u.Config.RejectedVersions {
+		v, err := semver.Parse(raw)
+		if err != nil || v.Compare(mark) != 0 {
+			continue
+		}
+
+		logger.Warn().
+			Str("high_water_mark", u.State.HighWaterMark).
+			Msg("High-water mark was rejected by the launcher, lowering it to the running version")
+
+		u.State.HighWaterMark = ""
+		if err := u.State.Save(u.Config.StateDirectory); err != nil {
+			logger.Error().
+				Err(err).
+				Msg("Failed to persist version high-water mark")
+		}
+
+		return
+	}
+}
+
+// isUpgrade tells if the candidate version should replace the running one.
+// A pinned version was chosen by the operator rather than the manifest, so it may go backwards.
+func (u *Updater) isUpgrade(logger *zerolog.Logger, candidate string) bool {
+	allowDowngrade := u.Config.AllowDowngrade || u.Config.PinVersion != ""
+
+	l := logger.With().
+		Str("current_version", u.Meta.Version).
+		Str("candidate_version", candidate).
+		Str("channel", u.Config.Channel).
+		Logger()
+
+	candidateVersion, err := semver.Parse(candidate)
+	if err != nil {
+		l.Error().
+			Err(err).
+			Msg("Candidate version is not a semantic version, skipping update")
+
+		return false
+	}
+
+	currentVersion, currentErr := semver.Parse(u.Meta.Version)
+
+	// Unless the mark is above the running version, comparing with the running version below says it better.
+	if !allowDowngrade && u.State.HighWaterMark != "" {
+		highWaterMark, err := semver.Parse(u.State.HighWaterMark)
+		isAboveCurrent := currentErr != nil || currentVersion.LessThan(highWaterMark)
+		if err == nil && isAboveCurrent && candidateVersion.LessThan(highWaterMark) {
+			l.Warn().
+				Str("high_water_mark", u.State.HighWaterMark).
+				Msg("Candidate version is older than the highest version seen, refusing to roll back")
+
+			return false
+		}
+	}
+
+	if currentErr != nil {
+		// Development builds are not versioned, any release is considered newer.
+		l.Warn().
+			Err(currentErr).
+			Msg("Current version is not a semantic version, treating candidate as an upgrade")
+
+		return true
+	}
+
+	switch c := candidateVersion.Compare(currentVersion); {
+	case c == 0:
+		l.Info().
+			Msg("No updates available. Current version is up to date.")
+
+		return false
+	case c < 0 && !allowDowngrade:
+		// e.g. after switching from beta to stable, wait for stable to catch up.
+		l.Warn().
+			Msg("Candidate version is older than the current version, refusing to downgrade until the channel catches up")
+
+		return false
+	case c < 0:
+		l.Warn().
+			Msg("Candidate version is older than the current version, downgrading as allowed by policy")
+
+		return true
+	default:
+		return true
+	}
+}
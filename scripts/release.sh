@@ -5,9 +5,34 @@ APP_NAME="api"
 BIN_DIR="bin"
 MANIFEST="internal/assets/release.json"
 SIGN_CMD="go run ./cmd/sign"
+DELTA_CMD="go run ./cmd/delta"
+
+# Name the signing key in signatures, only once no installation predating key IDs is left
+SIGN_KEY_ID="${SIGN_KEY_ID:-false}"
+if [[ "$SIGN_KEY_ID" == "true" ]]; then
+  SIGN_CMD="$SIGN_CMD -key-id"
+fi
 
 VERSION="${VERSION:-unknown}"
 COMMIT="${COMMIT:-unknown}"
+CHANNEL="${CHANNEL:-stable}"
+ROLLOUT_PERCENTAGE="${ROLLOUT_PERCENTAGE:-100}"
+CRITICAL="${CRITICAL:-false}"
+# How long installations accept the manifest, publish or roll out before then to keep them updating
+MANIFEST_EXPIRES="${MANIFEST_EXPIRES:-90 days}"
+# Where the artifacts of this version are downloaded from, GitHub releases by default
+ARTIFACT_BASE_URL="${ARTIFACT_BASE_URL:-$ARCHIVER_BASE_URL/$ARCHIVER_OWNER/$ARCHIVER_REPO/releases/download/$VERSION}"
+# Space separated base URLs of mirrors that also serve this version's artifacts
+ARTIFACT_MIRROR_BASE_URLS="${ARTIFACT_MIRROR_BASE_URLS:-}"
+# How artifacts are compressed, gzip, zstd, xz or empty to publish raw executables
+COMPRESSION="${COMPRESSION:-}"
+# Space separated earlier versions to publish patches from, their binaries are read from $DELTA_BASE_DIR/<version>/
+DELTA_FROM="${DELTA_FROM:-}"
+DELTA_BASE_DIR="${DELTA_BASE_DIR:-$BIN_DIR/previous}"
+# Archive format to bundle executables with companion files in, tar.gz or zip, empty to publish bare executables
+BUNDLE="${BUNDLE:-}"
+# Space separated files and directories shipped next to the executable in bundles, at the same relative paths
+BUNDLE_FILES="${BUNDLE_FILES:-}"
 SIGNING_KEY_ENV="${SIGNING_KEY_ENV:-SIGNING_KEY_PEM}"
 PUBLIC_KEY_ENV="${PUBLIC_KEY_ENV:-PUBLIC_KEY_PEM}"
 
@@ -15,6 +40,28 @@ SIGN_KEY_FILE="$(mktemp)"
 PUB_KEY_FILE="$(mktemp)"
 trap 'rm -f "$SIGN_KEY_FILE" "$PUB_KEY_FILE"' EXIT
 
+case "$COMPRESSION" in
+  "" | gzip | zstd | xz) ;;
+  *)
+    echo "FATAL: Unsupported COMPRESSION \"$COMPRESSION\", the updater can only decompress gzip, zstd and xz."
+    exit 1
+    ;;
+esac
+
+case "$BUNDLE" in
+  "" | tar.gz | zip) ;;
+  *)
+    echo "FATAL: Unsupported BUNDLE \"$BUNDLE\", the updater can only extract tar.gz and zip."
+    exit 1
+    ;;
+esac
+
+# Bundles are compressed archives already, and patches only turn one executable into another.
+if [[ -n "$BUNDLE" && ( -n "$COMPRESSION" || -n "$DELTA_FROM" ) ]]; then
+  echo "FATAL: BUNDLE can't be combined with COMPRESSION or DELTA_FROM."
+  exit 1
+fi
+
 if [[ -z "${!SIGNING_KEY_ENV:-}" ]]; then
   echo "FATAL: Env variable \$${SIGNING_KEY_ENV} is not set."
   exit 1
@@ -40,24 +87,94 @@ targets=(
 
 declare -A DIGESTS
 declare -A SIGS
+declare -A SIZES
+declare -A COMPRESSED_DIGESTS
+declare -A PATCHES
+
+# bundle archives the executable of a target as the entrypoint, along with $BUNDLE_FILES.
+bundle() {
+  local bin="$1" archive="$2" entrypoint="$APP_NAME"
+  if [[ "$bin" == *.exe ]]; then
+    entrypoint="$APP_NAME.exe"
+  fi
+
+  local stage
+  stage="$(mktemp -d)"
+  cp "$bin" "$stage/$entrypoint"
+  for file in $BUNDLE_FILES; do
+    mkdir -p "$stage/$(dirname "$file")"
+    cp -R "$file" "$stage/$file"
+  done
+
+  rm -f "$archive"
+  case "$BUNDLE" in
+    tar.gz) tar -czf "$archive" -C "$stage" . ;;
+    zip) (cd "$stage" && zip -qr --symlinks - .) > "$archive" ;;
+  esac
+  rm -rf "$stage"
+}
 
 for target in "${targets[@]}"; do
   bin="$BIN_DIR/$APP_NAME-$target"
-  digest=$(sha256sum "$bin" | cut -d ' ' -f1)
-  sig=$($SIGN_CMD "$SIGN_KEY_FILE" "$bin")
+  artifact="$bin"
+  # The digest and signature of a bundle are those of the archive, the updater extracts it once verified.
+  if [[ -n "$BUNDLE" ]]; then
+    artifact="$bin.$BUNDLE"
+    bundle "$bin" "$artifact"
+  fi
+  digest=$(sha256sum "$artifact" | cut -d ' ' -f1)
+  sig=$($SIGN_CMD "$SIGN_KEY_FILE" "$artifact")
   key="${target//[^a-zA-Z0-9]/_}"
   DIGESTS[$key]="$digest"
   SIGS[$key]="$sig"
+  SIZES[$key]=$(wc -c < "$artifact" | tr -d ' ')
+  COMPRESSED_DIGESTS[$key]=""
+
+  # The digest and signature stay those of the executable, the size is that of the download.
+  if [[ -n "$COMPRESSION" ]]; then
+    case "$COMPRESSION" in
+      gzip) compressed="$bin.gz"; gzip -9 -n -c "$bin" > "$compressed" ;;
+      zstd) compressed="$bin.zst"; zstd -19 -q -f -c "$bin" > "$compressed" ;;
+      xz) compressed="$bin.xz"; xz -9 -c "$bin" > "$compressed" ;;
+    esac
+    COMPRESSED_DIGESTS[$key]=$(sha256sum "$compressed" | cut -d ' ' -f1)
+    SIZES[$key]=$(wc -c < "$compressed" | tr -d ' ')
+  fi
+
+  patches="[]"
+  for from in $DELTA_FROM; do
+    old_bin="$DELTA_BASE_DIR/$from/$APP_NAME-$target"
+    if [[ ! -f "$old_bin" ]]; then
+      echo "WARN: $old_bin not found, not publishing a patch from $from for $target."
+      continue
+    fi
+
+    patch_file="$BIN_DIR/$APP_NAME-$target.from-$from.patch"
+    $DELTA_CMD "$old_bin" "$bin" "$patch_file"
+    patches=$(jq -c \
+      --arg from "$from" \
+      --arg url "$ARTIFACT_BASE_URL/$(basename "$patch_file")" \
+      --arg digest "$(sha256sum "$patch_file" | cut -d ' ' -f1)" \
+      --arg sig "$($SIGN_CMD "$SIGN_KEY_FILE" "$patch_file")" \
+      --arg size "$(wc -c < "$patch_file" | tr -d ' ')" \
+      '. + [{from: $from, url: $url, digest: $digest, signatureBase64: $sig, size: ($size | tonumber)}]' <<< "$patches")
+  done
+  PATCHES[$key]="$patches"
 done
 
 TMP_MANIFEST=$(mktemp)
 jq_args=(
   --arg version "$VERSION"
   --arg commit "$COMMIT"
+  --arg channel "$CHANNEL"
+  --arg rollout_percentage "$ROLLOUT_PERCENTAGE"
+  --arg critical "$CRITICAL"
   --arg pubkey "$(cat "$PUB_KEY_FILE")"
-  --arg archiver_base_url "$ARCHIVER_BASE_URL"
-  --arg archiver_owner "$ARCHIVER_OWNER"
-  --arg archiver_repo "$ARCHIVER_REPO"
+  --arg artifact_base_url "$ARTIFACT_BASE_URL"
+  --arg mirror_base_urls "$ARTIFACT_MIRROR_BASE_URLS"
+  --arg compression "$COMPRESSION"
+  --arg bundle "$BUNDLE"
+  --arg expires "$(date -u -d "+$MANIFEST_EXPIRES" +%Y-%m-%dT%H:%M:%SZ)"
 )
 
 for target in "${!DIGESTS[@]}"; do
@@ -65,11 +182,15 @@ for target in "${!DIGESTS[@]}"; do
   sig=${SIGS[$target]}
   jq_args+=(--arg "${target}_digest" "$digest")
   jq_args+=(--arg "${target}_sig" "$sig")
+  jq_args+=(--arg "${target}_size" "${SIZES[$target]}")
+  jq_args+=(--arg "${target}_compressed_digest" "${COMPRESSED_DIGESTS[$target]}")
+  jq_args+=(--argjson "${target}_patches" "${PATCHES[$target]}")
 done
 
 jq "${jq_args[@]}" -f scripts/merge_manifest.jq "$MANIFEST" > "$TMP_MANIFEST"
 mv "$TMP_MANIFEST" "$MANIFEST"
 
 $SIGN_CMD "$SIGN_KEY_FILE" "$MANIFEST" > "$MANIFEST.sig.base64"
+SIGNING_KEY_ENV="$SIGNING_KEY_ENV" ./scripts/timestamp.sh
 
-echo "Manifest and signature updated: $MANIFEST"
+echo "Manifest and signature updated: $MANIFEST (channel: $CHANNEL, rollout: $ROLLOUT_PERCENTAGE%)"
@@ -0,0 +1,52 @@
+#!/usr/bin/env bash
+set -euo pipefail
+
+MANIFEST="internal/assets/release.json"
+SIGN_CMD="go run ./cmd/sign"
+
+# Name the signing key in signatures, only once no installation predating key IDs is left
+SIGN_KEY_ID="${SIGN_KEY_ID:-false}"
+if [[ "$SIGN_KEY_ID" == "true" ]]; then
+  SIGN_CMD="$SIGN_CMD -key-id"
+fi
+
+VERSION="${VERSION:?VERSION must be set}"
+ROLLOUT_PERCENTAGE="${ROLLOUT_PERCENTAGE:?ROLLOUT_PERCENTAGE must be set}"
+SIGNING_KEY_ENV="${SIGNING_KEY_ENV:-SIGNING_KEY_PEM}"
+# How long installations accept the manifest, see release.sh
+MANIFEST_EXPIRES="${MANIFEST_EXPIRES:-90 days}"
+
+SIGN_KEY_FILE="$(mktemp)"
+trap 'rm -f "$SIGN_KEY_FILE"' EXIT
+
+if [[ -z "${!SIGNING_KEY_ENV:-}" ]]; then
+  echo "FATAL: Env variable \$${SIGNING_KEY_ENV} is not set."
+  exit 1
+fi
+
+printf "%b\n" "${!SIGNING_KEY_ENV}" > "$SIGN_KEY_FILE"
+
+if ! jq -e --arg version "$VERSION" '.versions | any(.version == $version)' "$MANIFEST" > /dev/null; then
+  echo "FATAL: Version $VERSION is not in $MANIFEST."
+  exit 1
+fi
+
+# 100% drops the rollout altogether, the version then goes to everyone.
+# Like any new manifest, it gets a higher metadata version and a new expiry.
+TMP_MANIFEST=$(mktemp)
+jq --arg version "$VERSION" --arg percentage "$ROLLOUT_PERCENTAGE" \
+  --arg expires "$(date -u -d "+$MANIFEST_EXPIRES" +%Y-%m-%dT%H:%M:%SZ)" '
+  .versions |= map(
+    if .version == $version then
+      if $percentage == "100" then del(.rollout) else .rollout.percentage = ($percentage | tonumber) end
+    else . end
+  )
+  | .metadataVersion = ((.metadataVersion // 0) + 1)
+  | .expires = $expires
+' "$MANIFEST" > "$TMP_MANIFEST"
+mv "$TMP_MANIFEST" "$MANIFEST"
+
+$SIGN_CMD "$SIGN_KEY_FILE" "$MANIFEST" > "$MANIFEST.sig.base64"
+SIGNING_KEY_ENV="$SIGNING_KEY_ENV" ./scripts/timestamp.sh
+
+echo "Rollout of $VERSION set to $ROLLOUT_PERCENTAGE%: $MANIFEST"
@@ -0,0 +1,76 @@
+#!/usr/bin/env bash
+set -euo pipefail
+
+MANIFEST="internal/assets/release.json"
+SIGN_CMD="go run ./cmd/sign"
+
+# Name the signing key in signatures, only once no installation predating key IDs is left
+SIGN_KEY_ID="${SIGN_KEY_ID:-false}"
+if [[ "$SIGN_KEY_ID" == "true" ]]; then
+  SIGN_CMD="$SIGN_CMD -key-id"
+fi
+
+# Space separated public keys trusted once rotated, the first one signs from now on
+KEYS="${KEYS:-internal/assets/public.pem}"
+# Space separated IDs of keys never to trust again, see `openssl pkey -pubin -in public.pem -outform DER | sha256sum`
+REVOKE="${REVOKE:-}"
+# How long installations accept the re-signed manifest, see release.sh
+MANIFEST_EXPIRES="${MANIFEST_EXPIRES:-90 days}"
+# The rotation is signed by the key trusted so far, the manifest and timestamp by the new one
+SIGNING_KEY_ENV="${SIGNING_KEY_ENV:-SIGNING_KEY_PEM}"
+NEW_SIGNING_KEY_ENV="${NEW_SIGNING_KEY_ENV:-NEW_SIGNING_KEY_PEM}"
+
+SIGN_KEY_FILE="$(mktemp)"
+ROTATION_FILE="$(mktemp)"
+trap 'rm -f "$SIGN_KEY_FILE" "$ROTATION_FILE"' EXIT
+
+for env in "$SIGNING_KEY_ENV" "$NEW_SIGNING_KEY_ENV"; do
+  if [[ -z "${!env:-}" ]]; then
+    echo "FATAL: Env variable \$${env} is not set."
+    exit 1
+  fi
+done
+
+if [[ ! -f "$MANIFEST" ]]; then
+  echo "FATAL: $MANIFEST not found, publish a release first."
+  exit 1
+fi
+
+read -r -a key_files <<< "$KEYS"
+if [[ ${#key_files[@]} -eq 0 ]]; then
+  echo "FATAL: KEYS is empty, a rotation must leave at least one trusted key."
+  exit 1
+fi
+
+printf "%b\n" "${!SIGNING_KEY_ENV}" > "$SIGN_KEY_FILE"
+
+# Rotations are only ever appended, installations apply those newer than the last one they applied.
+version=$(($(jq '.keyRotations // [] | length' "$MANIFEST") + 1))
+
+jq -n -c \
+  --argjson version "$version" \
+  --argjson keys "$(for f in "${key_files[@]}"; do jq -Rs . "$f"; done | jq -s .)" \
+  --arg revoke "$REVOKE" \
+  '{version: $version, keys: $keys, revoked: [$revoke | split(" ")[] | select(. != "")]}' > "$ROTATION_FILE"
+
+# Only installations that understand key IDs read rotations, their signatures always name the key.
+signature=$($SIGN_CMD -key-id "$SIGN_KEY_FILE" "$ROTATION_FILE")
+
+TMP_MANIFEST=$(mktemp)
+jq \
+  --arg document "$(base64 -w0 "$ROTATION_FILE")" \
+  --arg signature "$signature" \
+  --arg pubkey "$(cat "${key_files[0]}")" \
+  --arg expires "$(date -u -d "+$MANIFEST_EXPIRES" +%Y-%m-%dT%H:%M:%SZ)" '
+  .keyRotations = ((.keyRotations // []) + [{document: $document, signature: $signature}])
+  | .publicKey = $pubkey
+  | .metadataVersion = ((.metadataVersion // 0) + 1)
+  | .expires = $expires
+' "$MANIFEST" > "$TMP_MANIFEST"
+mv "$TMP_MANIFEST" "$MANIFEST"
+
+printf "%b\n" "${!NEW_SIGNING_KEY_ENV}" > "$SIGN_KEY_FILE"
+$SIGN_CMD "$SIGN_KEY_FILE" "$MANIFEST" > "$MANIFEST.sig.base64"
+SIGNING_KEY_ENV="$NEW_SIGNING_KEY_ENV" ./scripts/timestamp.sh
+
+echo "Key rotation $version appended and manifest re-signed: $MANIFEST"
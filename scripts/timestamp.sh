@@ -0,0 +1,50 @@
+#!/usr/bin/env bash
+set -euo pipefail
+
+MANIFEST="internal/assets/release.json"
+TIMESTAMP="internal/assets/timestamp.json"
+SIGN_CMD="go run ./cmd/sign"
+
+# Name the signing key in signatures, only once no installation predating key IDs is left
+SIGN_KEY_ID="${SIGN_KEY_ID:-false}"
+if [[ "$SIGN_KEY_ID" == "true" ]]; then
+  SIGN_CMD="$SIGN_CMD -key-id"
+fi
+
+# How long installations accept the timestamp, re-sign it well before then, e.g. from a scheduled job
+TIMESTAMP_EXPIRES="${TIMESTAMP_EXPIRES:-1 day}"
+SIGNING_KEY_ENV="${SIGNING_KEY_ENV:-SIGNING_KEY_PEM}"
+
+SIGN_KEY_FILE="$(mktemp)"
+trap 'rm -f "$SIGN_KEY_FILE"' EXIT
+
+if [[ -z "${!SIGNING_KEY_ENV:-}" ]]; then
+  echo "FATAL: Env variable \$${SIGNING_KEY_ENV} is not set."
+  exit 1
+fi
+
+if [[ ! -f "$MANIFEST" ]]; then
+  echo "FATAL: $MANIFEST not found, publish a release first."
+  exit 1
+fi
+
+printf "%b\n" "${!SIGNING_KEY_ENV}" > "$SIGN_KEY_FILE"
+
+# Installations refuse timestamps with a lower version than one they already trusted.
+version=1
+if [[ -f "$TIMESTAMP" ]]; then
+  version=$(($(jq '.version // 0' "$TIMESTAMP") + 1))
+fi
+
+TMP_TIMESTAMP=$(mktemp)
+jq -n \
+  --argjson version "$version" \
+  --arg expires "$(date -u -d "+$TIMESTAMP_EXPIRES" +%Y-%m-%dT%H:%M:%SZ)" \
+  --argjson manifest_version "$(jq '.metadataVersion // 0' "$MANIFEST")" \
+  --arg manifest_digest "$(sha256sum "$MANIFEST" | cut -d ' ' -f1)" \
+  '{version: $version, expires: $expires, manifest: {version: $manifest_version, digest: $manifest_digest}}' > "$TMP_TIMESTAMP"
+mv "$TMP_TIMESTAMP" "$TIMESTAMP"
+
+$SIGN_CMD "$SIGN_KEY_FILE" "$TIMESTAMP" > "$TIMESTAMP.sig.base64"
+
+echo "Timestamp $version and signature updated: $TIMESTAMP (expires in $TIMESTAMP_EXPIRES)"